@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"path"
+	"slices"
+	"strings"
 	"time"
 
 	vlc "github.com/adrg/libvlc-go/v3"
@@ -14,21 +18,82 @@ import (
 	"gitlab.com/gomidi/midi/v2/drivers/rtmididrv"
 	"go.uber.org/zap"
 
+	"github.com/markus-wa/vlc-sampler/features/audio"
+	"github.com/markus-wa/vlc-sampler/features/bus"
 	"github.com/markus-wa/vlc-sampler/features/cliui"
 	"github.com/markus-wa/vlc-sampler/features/hud"
+	"github.com/markus-wa/vlc-sampler/features/hud/fbhud"
+	"github.com/markus-wa/vlc-sampler/features/hud/glhud"
+	"github.com/markus-wa/vlc-sampler/features/hud/raster"
+	"github.com/markus-wa/vlc-sampler/features/hud/vlchud"
 	"github.com/markus-wa/vlc-sampler/features/input"
+	"github.com/markus-wa/vlc-sampler/features/menu"
 	"github.com/markus-wa/vlc-sampler/features/midictl"
+	"github.com/markus-wa/vlc-sampler/features/modes"
 	"github.com/markus-wa/vlc-sampler/features/sampler"
+	"github.com/markus-wa/vlc-sampler/features/sampler/gamepad"
+	"github.com/markus-wa/vlc-sampler/features/sampler/playlist"
+	"github.com/markus-wa/vlc-sampler/features/sampler/state"
 )
 
 type UI interface {
+	menu.UI
 	SendText(string)
+	SendError(string)
+	SendMIDIState(midictl.State)
+	SendSamplerState(state.Sampler)
+	// Start shows the UI until the user quits or it is closed. It blocks.
 	Start()
+	Close() error
 }
 
-var uiFlag = flag.String("ui", "cli", "UI to use (cli, hud)")
+var errQuit = errors.New("quit")
+
+var (
+	uiFlag        = flag.String("ui", "cli", "UI to use (cli, hud, overlay, headless)")
+	hudConfigFlag = flag.String("hud-config", "", "path to a JSON HUD config file (font, colours, layout, message lifetimes)")
+	hudDumpFlag   = flag.String("hud-dump", "", "write the HUD to this PNG file whenever it changes, for remote debugging")
+	previewFlag   = flag.String("preview", "", "comma separated framebuffer devices of preview panels, e.g. /dev/fb1,/dev/fb2")
+	remoteFlag    = flag.String("remote", "", "listen for remote control commands on this address, e.g. :7000")
+	midiInFlag    = flag.String("midi-in", "", "listen to the first MIDI input port whose name contains this, e.g. \"CH345\"")
+	audioFlag     = flag.String("audio", "", "analyse audio from this ALSA device, e.g. hw:1,0, for modulation")
+	routesFlag    = flag.String("audio-routes", "bass=cc 0,envelope=cc 1,onset=gate 4,beat=gate 5", "comma separated source=target routes of the audio modulation")
+	orderFlag     = flag.String("order", "listed", "order the clips of a playlist are played in (listed, name, shuffle)")
+	pinFlag       = flag.String("pin", "", "comma separated gamepad=mode pairs keeping gamepads in a mode, e.g. \"8BitDo SN30 Pro=midi\"")
+)
+
+// options are the flags that need parsing. main parses them once, so invalid flags fail instead of run being retried.
+type options struct {
+	pins   map[string]string
+	order  playlist.Order
+	routes []audio.Route
+}
+
+func parseOptions() (options, error) {
+	var (
+		opts options
+		err  error
+	)
+
+	opts.pins, err = parsePins(*pinFlag)
+	if err != nil {
+		return opts, fmt.Errorf("invalid -pin: %w", err)
+	}
+
+	opts.order, err = playlist.ParseOrder(*orderFlag)
+	if err != nil {
+		return opts, fmt.Errorf("invalid -order: %w", err)
+	}
+
+	opts.routes, err = audio.ParseRoutes(*routesFlag)
+	if err != nil {
+		return opts, fmt.Errorf("invalid -audio-routes: %w", err)
+	}
+
+	return opts, nil
+}
 
-func run() error {
+func run(opts options) error {
 	err := vlc.Init("--no-autoscale")
 	if err != nil {
 		return fmt.Errorf("failed to initialize libvlc: %w", err)
@@ -36,19 +101,32 @@ func run() error {
 
 	defer vlc.Release()
 
-	var ui UI
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("could not get user $HOME dir: %w", err)
+	}
 
-	switch *uiFlag {
-	case "cli":
-		ui = cliui.UI{}
+	playlistDir := path.Join(home, "Playlists")
 
-	case "hud":
-		ui, err = hud.NewHud()
+	smplr, err := sampler.New(playlistDir, opts.order)
+	if err != nil {
+		return fmt.Errorf("could not initialize sampler: %w", err)
+	}
+	defer smplr.Close()
 
-	default:
-		return fmt.Errorf("unknown UI: %s", *uiFlag)
+	ui, err := newUI(smplr)
+	if err != nil {
+		return fmt.Errorf("could not initialize UI: %w", err)
 	}
 
+	// closed before the sampler, the video overlay draws into its players
+	defer func() {
+		err := ui.Close()
+		if err != nil {
+			zap.S().Errorw("failed to close UI", "error", err)
+		}
+	}()
+
 	drv, err := rtmididrv.New()
 	if err != nil {
 		return fmt.Errorf("could not initialize MIDI driver: %w", err)
@@ -70,88 +148,374 @@ func run() error {
 	}
 	defer midiSvc.Close()
 
-	midiCtl, err := midictl.NewController(midiSvc, ui)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	menus := menu.NewNavigator(ui)
+
+	midiCtl, err := midictl.NewController(ctx, midiSvc, ui, menus)
 	if err != nil {
 		return fmt.Errorf("could not initialize MIDI controller: %w", err)
 	}
+	defer midiCtl.Close()
 
-	home, err := os.UserHomeDir()
+	samplerCtrl, err := sampler.NewController(smplr, ui, menus)
 	if err != nil {
-		return fmt.Errorf("could not get user $HOME dir: %w", err)
+		return fmt.Errorf("could not initialize sampler controller: %w", err)
 	}
 
-	smplr, err := sampler.New(path.Join(home, "Playlists"))
+	// all inputs publish actions on the bus, the controllers perform them
+	b := bus.New()
+	b.Subscribe(samplerCtrl.Handle)
+	b.Subscribe(midiCtl.Handle)
+
+	mappings, err := midictl.LoadMappings(path.Join(playlistDir, midictl.MappingFileName))
 	if err != nil {
-		return fmt.Errorf("could not initialize sampler: %w", err)
+		return fmt.Errorf("could not load MIDI mappings: %w", err)
 	}
 
-	samplerCtrl, err := sampler.NewController(smplr, ui)
+	midiIn, err := midictl.NewInput(*midiInFlag, mappings, smplr.Params(), b, ui, menus)
 	if err != nil {
-		return fmt.Errorf("could not initialize sampler controller: %w", err)
+		return fmt.Errorf("could not initialize MIDI input: %w", err)
+	}
+	defer midiIn.Close()
+
+	b.Subscribe(midiIn.Handle)
+
+	if *remoteFlag != "" {
+		ln, err := net.Listen("tcp", *remoteFlag)
+		if err != nil {
+			return fmt.Errorf("could not listen for remote control: %w", err)
+		}
+
+		go func() {
+			err := bus.Serve(ctx, ln, b)
+			if err != nil {
+				zap.S().Errorw("remote control failed", "error", err)
+			}
+		}()
+	}
+
+	if *audioFlag != "" {
+		// the parameters are only known once the sampler exists, without them the capture isn't worth starting
+		err := audio.CheckParams(opts.routes, smplr.Params())
+		if err != nil {
+			zap.S().Errorw("invalid -audio-routes, not capturing audio", "error", err)
+			ui.SendError(fmt.Sprintf("audio error: %v", err))
+		} else {
+			go listenAudio(ctx, *audioFlag, opts.routes, b, ui)
+		}
+	}
+
+	if h, ok := ui.(*hud.Hud); ok && *previewFlag != "" {
+		go updatePreview(ctx, smplr, h)
+	}
+
+	if cli, ok := ui.(*cliui.UI); ok {
+		go cli.Control(ctx, menus, b)
+	}
+
+	go sampler.PublishState(ctx, smplr, ui)
+
+	mgr, err := modes.NewManager(b, ui, globalBindings, gamepadModes()...)
+	if err != nil {
+		return fmt.Errorf("could not initialize modes: %w", err)
 	}
 
-	go ui.Start()
+	b.Subscribe(mgr.Handle)
 
-	ctx := context.Background()
+	var pinned []string
 
-	for range time.Tick(time.Second) {
-		err := pollDefaultGamepad(ctx, samplerCtrl, midiCtl, ui)
+	for name, mode := range opts.pins {
+		err := mgr.Pin(name, mode)
 		if err != nil {
-			zap.S().Errorw("pollDefaultGamepad failed", err)
+			return fmt.Errorf("could not pin %q: %w", name, err)
 		}
+
+		pinned = append(pinned, name)
+
+		go pollGamepads(ctx, func(ctx context.Context) (*evdev.Evdev, error) {
+			return input.PollNamed(ctx, name)
+		}, mgr, menus, ui)
 	}
 
-	return nil
+	go func() {
+		ui.Start()
+		cancel(errQuit)
+	}()
+
+	pollGamepads(ctx, func(ctx context.Context) (*evdev.Evdev, error) {
+		return input.PollDefault(ctx, pinned...)
+	}, mgr, menus, ui)
+
+	return context.Cause(ctx)
 }
 
-func pollDefaultGamepad(ctx context.Context, samplerCtrl *sampler.Controller, midiCtl *midictl.Controller, ui UI) error {
-	gamepad, err := input.PollDefault(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to poll device: %w", err)
+// gamepadModes are the modes of the gamepads, in the order Z+Mode cycles through them.
+func gamepadModes() []modes.Mode {
+	return []modes.Mode{
+		{Name: "sampler", Layout: gamepad.Layout()},
+		{Name: "midi", Layout: midictl.Layout()},
+		{Name: "fx", Layout: gamepad.FxLayout()},
 	}
+}
 
-	defer gamepad.Close()
+// globalBindings are the controls of every mode: Z+Mode switches to the next mode.
+var globalBindings = []input.Binding{
+	{Buttons: []input.Button{input.Btn(evdev.BtnZ), input.Btn(evdev.BtnMode)}, Action: bus.NextMode{}},
+	{Buttons: []input.Button{input.Btn(evdev.AbsoluteZ), input.Btn(evdev.BtnMode)}, Action: bus.NextMode{}}, // 8bitdo TL2
+}
 
-	fmt.Printf("Device Name: %s\n", gamepad.Name())
+// listenAudio publishes the audio modulation until ctx is done, starting the capture again a second after it fails,
+// e.g. while the USB audio interface is unplugged.
+func listenAudio(ctx context.Context, device string, routes []audio.Route, pub bus.Publisher, ui UI) {
+	var last string
 
-	mode := 0
-	modeModifier := false
+	for {
+		err := audio.Listen(ctx, device, routes, pub)
 
-	for event := range gamepad.Poll(ctx) {
-		if fmt.Sprint(event.Type) == "Report" {
-			continue
+		// the same error every second isn't news
+		if err != nil && err.Error() != last {
+			zap.S().Errorw("audio capture failed", "device", device, "error", err)
+			ui.SendError(fmt.Sprintf("audio error: %v", err))
+
+			last = err.Error()
 		}
 
-		ui.SendText(fmt.Sprintf("%s (%d) %d", event.Type, event.Code, event.Value))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
 
-		if modeModifier && event.Type == evdev.BtnMode && event.Value != 0 {
-			mode++
+// parsePins parses comma separated device=mode pairs of the gamepad modes.
+func parsePins(s string) (map[string]string, error) {
+	pins := map[string]string{}
 
-			log.Println("mode changed to", mode%2)
+	if s == "" {
+		return pins, nil
+	}
 
-			continue
+	for _, pin := range strings.Split(s, ",") {
+		device, mode, ok := strings.Cut(pin, "=")
+		if !ok || device == "" || mode == "" {
+			return nil, fmt.Errorf("want device=mode, got %q", pin)
 		}
 
-		if event.Type == evdev.BtnZ || event.Type == evdev.AbsoluteZ {
-			modeModifier = event.Value != 0
+		if !slices.ContainsFunc(gamepadModes(), func(m modes.Mode) bool { return m.Name == mode }) {
+			return nil, fmt.Errorf("unknown mode %q", mode)
 		}
 
-		switch mode % 2 {
-		case 0:
-			err := samplerCtrl.HandleEvent(event)
+		pins[device] = mode
+	}
+
+	return pins, nil
+}
+
+// pollGamepads passes the events of the gamepad returned by open to the modes until ctx is done,
+// opening it again every second while it's missing.
+func pollGamepads(ctx context.Context, open func(context.Context) (*evdev.Evdev, error), mgr *modes.Manager, menus *menu.Navigator, ui UI) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		dev, err := open(ctx)
+		if err != nil {
+			zap.S().Debugw("no gamepad", "error", err)
+
+			continue
+		}
+
+		pollGamepad(ctx, dev, mgr, menus, ui)
+
+		dev.Close()
+	}
+}
+
+func pollGamepad(ctx context.Context, dev *evdev.Evdev, mgr *modes.Manager, menus *menu.Navigator, ui UI) {
+	ui.SendText(fmt.Sprintf("gamepad %s connected", dev.Name()))
+
+	d := mgr.Device(dev.Name())
+
+	events := dev.Poll(ctx)
+
+	tick := time.NewTicker(input.TickInterval)
+	defer tick.Stop()
+
+	for {
+		var event *evdev.EventEnvelope
+
+		select {
+		case <-tick.C:
+			err := d.Tick()
 			if err != nil {
 				log.Println("failed to handle event:", err)
+				ui.SendError(err.Error())
 			}
 
-		case 1:
-			err := midiCtl.HandleEvent(event)
+			continue
+
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			event = e
+		}
+
+		if fmt.Sprint(event.Type) == "Report" {
+			continue
+		}
+
+		handled, err := menus.HandleEvent(event)
+		if err != nil {
+			log.Println("failed to handle menu event:", err)
+			ui.SendError(err.Error())
+		}
+
+		if handled {
+			continue
+		}
+
+		err = d.HandleEvent(event)
+		if err != nil {
+			log.Println("failed to handle event:", err)
+			ui.SendError(err.Error())
+		}
+	}
+}
+
+// size of the headless HUD, the default HUD config is made for full HD
+const (
+	dumpWidth  = 1920
+	dumpHeight = 1080
+)
+
+func newUI(smplr *sampler.Sampler) (UI, error) {
+	if *uiFlag == "cli" {
+		if *previewFlag != "" {
+			return nil, fmt.Errorf("preview panels need a HUD UI (hud, overlay or headless)")
+		}
+
+		cli := cliui.New()
+
+		// logs would garble the full-screen UI, so they go into its log pane
+		zap.ReplaceGlobals(cli.Logger())
+		log.SetOutput(cli)
+
+		return cli, nil
+	}
+
+	cfg := hud.DefaultConfig()
+
+	if *hudConfigFlag != "" {
+		var err error
+
+		cfg, err = hud.LoadConfig(*hudConfigFlag)
+		if err != nil {
+			return nil, fmt.Errorf("could not load HUD config: %w", err)
+		}
+	}
+
+	var (
+		backend hud.Backend
+		dump    *raster.Headless
+	)
+
+	if *hudDumpFlag != "" || *uiFlag == "headless" {
+		var err error
+
+		dump, err = raster.NewHeadless(cfg, dumpWidth, dumpHeight, *hudDumpFlag)
+		if err != nil {
+			return nil, fmt.Errorf("could not initialize headless HUD: %w", err)
+		}
+	}
+
+	switch *uiFlag {
+	case "hud":
+		backend = glhud.New()
+
+	case "headless":
+		backend = dump
+
+	case "overlay":
+		players, err := smplr.Players()
+		if err != nil {
+			return nil, fmt.Errorf("could not get sampler players: %w", err)
+		}
+
+		backend, err = vlchud.New(players...)
+		if err != nil {
+			return nil, fmt.Errorf("could not initialize video overlay: %w", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown UI: %s", *uiFlag)
+	}
+
+	backends := []hud.Backend{backend}
+
+	if dump != nil && dump != backend {
+		backends = append(backends, dump)
+	}
+
+	if *previewFlag != "" {
+		for _, dev := range strings.Split(*previewFlag, ",") {
+			panel, err := fbhud.New(cfg, dev, fbhud.Options{})
 			if err != nil {
-				log.Println("failed to handle event:", err)
+				return nil, fmt.Errorf("could not initialize preview panel %s: %w", dev, err)
 			}
+
+			backends = append(backends, panel)
 		}
 	}
 
-	return nil
+	h, err := hud.NewHud(cfg, backends...)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize HUD: %w", err)
+	}
+
+	return h, nil
+}
+
+// preview thumbnails are small, the panels are only a few hundred pixels wide
+const thumbnailWidth = 320
+
+// updatePreview keeps the clip name and thumbnail shown on the preview panels up to date.
+func updatePreview(ctx context.Context, smplr *sampler.Sampler, h *hud.Hud) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		clip, err := smplr.Clip()
+		if err != nil {
+			zap.S().Debugw("failed to get clip for preview", "error", err)
+		}
+
+		img, err := smplr.Snapshot(thumbnailWidth)
+		if err != nil {
+			zap.S().Debugw("failed to get thumbnail for preview", "error", err)
+		}
+
+		h.SetPreview("sampler.preview", hud.Preview{
+			Title: clip,
+			Image: img,
+		})
+	}
 }
 
 func main() {
@@ -166,8 +530,17 @@ func main() {
 
 	zap.ReplaceGlobals(logger)
 
+	opts, err := parseOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	for range time.Tick(1 * time.Second) {
-		err := run()
+		err := run(opts)
+		if errors.Is(err, errQuit) {
+			return
+		}
+
 		if err != nil {
 			zap.S().Errorw("run failed", "error", err)
 		}
@@ -1,22 +1,46 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"math"
 	"time"
 
 	"github.com/markus-wa/vlc-sampler/features/hud"
+	"github.com/markus-wa/vlc-sampler/features/hud/glhud"
 )
 
 func main() {
-	h, err := hud.NewHud()
+	h, err := hud.NewHud(hud.DefaultConfig(), glhud.New())
 	if err != nil {
 		log.Fatalln("failed to initialize glfw:", err)
 	}
 
 	go h.Start()
 
+	h.SetList("playlist", hud.List{
+		Title: "Playlist",
+		Items: []string{"intro.mp4", "loop-a.mp4", "loop-b.mp4"},
+	})
+
+	i := 0
+
 	for range time.Tick(time.Second) {
 		h.SendText("Hello, World!")
+
+		v := float32(math.Sin(float64(i)/4)+1) / 2
+
+		h.SetMeter("demo.h", hud.Meter{Label: "Horizontal", Value: v})
+		h.SetMeter("demo.v", hud.Meter{Label: "V", Value: 1 - v, Orientation: hud.Vertical})
+		h.SetLamp("demo.lamp", hud.Lamp{Label: "Lamp", On: i%2 == 0})
+		h.SetList("playlist", hud.List{
+			Title:   "Playlist",
+			Items:   []string{"intro.mp4", "loop-a.mp4", "loop-b.mp4"},
+			Current: i % 3,
+		})
+		h.SetStatus(fmt.Sprintf("tick %d", i))
+
+		i++
 	}
 
 	<-make(chan struct{})
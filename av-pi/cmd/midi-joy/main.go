@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -10,10 +11,15 @@ import (
 	_ "gitlab.com/gomidi/midi/v2/drivers/rtmididrv"
 	"go.uber.org/zap"
 
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/cliui"
 	"github.com/markus-wa/vlc-sampler/features/input"
+	"github.com/markus-wa/vlc-sampler/features/menu"
 	"github.com/markus-wa/vlc-sampler/features/midictl"
 )
 
+var errQuit = errors.New("quit")
+
 func run() error {
 	for i, port := range midi.GetOutPorts() {
 		zap.S().Infow("MIDI Port", "index", i, "name", port.String())
@@ -25,12 +31,31 @@ func run() error {
 	}
 	defer midiSvc.Close()
 
-	midiCtl, err := midictl.NewController(midiSvc, nil)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	ui := cliui.New()
+	menus := menu.NewNavigator(ui)
+
+	// logs would garble the full-screen UI, so they go into its log pane
+	zap.ReplaceGlobals(ui.Logger())
+	log.SetOutput(ui)
+
+	go func() {
+		ui.Start()
+		cancel(errQuit)
+	}()
+
+	midiCtl, err := midictl.NewController(ctx, midiSvc, ui, menus)
 	if err != nil {
 		return fmt.Errorf("could not initialize MIDI controller: %w", err)
 	}
+	defer midiCtl.Close()
 
-	ctx := context.Background()
+	b := bus.New()
+	b.Subscribe(midiCtl.Handle)
+
+	pad := midictl.NewGamepad(b)
 
 	gamepad, err := input.PollDefault(ctx)
 	if err != nil {
@@ -41,14 +66,42 @@ func run() error {
 
 	zap.S().Infow("starting", "gamepad", gamepad.Name())
 
-	for event := range gamepad.Poll(ctx) {
-		err := midiCtl.HandleEvent(event)
-		if err != nil {
-			log.Println("failed to handle event:", err)
+	events := gamepad.Poll(ctx)
+
+	tick := time.NewTicker(input.TickInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			err := pad.Tick()
+			if err != nil {
+				log.Println("failed to handle event:", err)
+			}
+
+			continue
+
+		case event, ok := <-events:
+			if !ok {
+				return context.Cause(ctx)
+			}
+
+			handled, err := menus.HandleEvent(event)
+			if err != nil {
+				log.Println("failed to handle menu event:", err)
+			}
+
+			if handled {
+				continue
+			}
+
+			err = pad.HandleEvent(event)
+			if err != nil {
+				log.Println("failed to handle event:", err)
+			}
 		}
 	}
-
-	return nil
+	return context.Cause(ctx)
 }
 
 func main() {
@@ -65,6 +118,10 @@ func main() {
 
 	for range t.C {
 		err := run()
+		if errors.Is(err, errQuit) {
+			return
+		}
+
 		if err != nil {
 			zap.S().Errorw("run failed", "error", err)
 		}
@@ -0,0 +1,58 @@
+// Package atomicfile replaces files through a temporary file next to them, so neither readers nor a crash
+// ever see a half written file.
+package atomicfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Write creates a temporary file in the directory of path, calls write with it and renames it to path.
+// If write fails path is left as it was.
+func Write(path string, write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	defer os.Remove(f.Name())
+
+	err = write(f)
+	if err != nil {
+		f.Close()
+
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// WriteJSON writes v as indented JSON, so the file can be edited by hand.
+func WriteJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", filepath.Base(path), err)
+	}
+
+	return Write(path, func(w io.Writer) error {
+		_, err := w.Write(append(b, '\n'))
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+
+		return nil
+	})
+}
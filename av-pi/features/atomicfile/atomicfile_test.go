@@ -0,0 +1,66 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cues.json")
+
+	err := WriteJSON(path, map[string]int{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "{\n  \"a\": 1\n}\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+}
+
+func TestWriteFailureKeepsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "midi-map.json")
+
+	err := os.WriteFile(path, []byte("old"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errWrite := errors.New("disk full")
+
+	err = Write(path, func(w io.Writer) error {
+		_, _ = w.Write([]byte("new"))
+
+		return errWrite
+	})
+	if !errors.Is(err, errWrite) {
+		t.Fatalf("got %v, want %v", err, errWrite)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "old" {
+		t.Errorf("file changed to %q", b)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+}
@@ -0,0 +1,266 @@
+// Package audio analyses an audio input and turns it into modulation: the level of the signal and of a few
+// frequency bands, onsets and beats, routed to MIDI CCs and gates or to sampler actions.
+package audio
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// WindowSize is how many samples each spectrum is computed from, Hop how many samples are between two frames.
+	WindowSize = 1024
+	Hop        = 512
+
+	// Floor is the level in dBFS that maps to 0, 0 dBFS maps to 1.
+	Floor = -60.0
+
+	envelopeAttack  = 5 * time.Millisecond
+	envelopeRelease = 150 * time.Millisecond
+
+	// minOnsetGap and minBeatGap are the shortest times between two onsets and two beats, 240 BPM at most
+	minOnsetGap = 50 * time.Millisecond
+	minBeatGap  = 250 * time.Millisecond
+	// fluxHistory is how long the average the flux has to exceed is taken over
+	fluxHistory = 500 * time.Millisecond
+	fluxRatio   = 1.5
+	// beatIntervals is how many intervals between beats the tempo is the median of
+	beatIntervals = 8
+)
+
+// Band is a frequency band.
+type Band int
+
+const (
+	Bass Band = iota
+	Mid
+	Treble
+	BandMax
+)
+
+var bandNames = [BandMax]string{"bass", "mid", "treble"}
+
+func (b Band) String() string {
+	return bandNames[b]
+}
+
+// bandEdges are the lower and upper frequencies of the bands in Hz.
+var bandEdges = [BandMax][2]float64{
+	Bass:   {20, 250},
+	Mid:    {250, 2000},
+	Treble: {2000, 8000},
+}
+
+// Frame is the analysis of one hop of audio. The levels go from 0 (Floor or quieter) to 1 (full scale).
+type Frame struct {
+	Envelope float64
+	Bands    [BandMax]float64
+	// Onset is set when a sound starts, Beat when a bass sound starts.
+	Onset bool
+	Beat  bool
+	// BPM is the tempo of the recent beats, zero until there have been enough.
+	BPM float64
+}
+
+// Analyzer turns samples into frames. It is not safe for concurrent use.
+type Analyzer struct {
+	rate   int
+	window []float64
+
+	buf      []float64 // the last WindowSize samples, a ring starting at position % WindowSize
+	pending  int       // samples since the last frame
+	position int       // samples since the start
+
+	envelope       float64
+	attack, decay  float64
+	spectrum, prev []float64
+	spec           []complex128
+
+	onsets, beats detector
+	lastBeat      int
+	intervals     []int // samples between beats
+}
+
+// detector finds peaks of a flux that exceed its recent average.
+type detector struct {
+	history []float64
+	size    int
+	last    int // position of the last peak
+	gap     int
+}
+
+func (d *detector) detect(flux float64, position int) bool {
+	avg := 0.0
+
+	for _, f := range d.history {
+		avg += f
+	}
+
+	if len(d.history) > 0 {
+		avg /= float64(len(d.history))
+	}
+
+	d.history = append(d.history, flux)
+	if len(d.history) > d.size {
+		d.history = d.history[1:]
+	}
+
+	// a tiny floor, so noise after silence doesn't count
+	if flux <= avg*fluxRatio || flux < 1e-3 || position-d.last < d.gap {
+		return false
+	}
+
+	d.last = position
+
+	return true
+}
+
+func NewAnalyzer(sampleRate int) *Analyzer {
+	samples := func(d time.Duration) int {
+		return int(d.Seconds() * float64(sampleRate))
+	}
+
+	frames := samples(fluxHistory) / Hop
+
+	return &Analyzer{
+		rate:     sampleRate,
+		window:   hann(WindowSize),
+		buf:      make([]float64, WindowSize),
+		attack:   math.Exp(-1 / (envelopeAttack.Seconds() * float64(sampleRate))),
+		decay:    math.Exp(-1 / (envelopeRelease.Seconds() * float64(sampleRate))),
+		spectrum: make([]float64, WindowSize/2),
+		prev:     make([]float64, WindowSize/2),
+		spec:     make([]complex128, WindowSize),
+		onsets:   detector{size: frames, gap: samples(minOnsetGap), last: -samples(time.Second)},
+		beats:    detector{size: frames, gap: samples(minBeatGap), last: -samples(time.Second)},
+		lastBeat: -1,
+	}
+}
+
+// Write analyses samples from -1 to 1 and returns a frame for every Hop samples completed.
+func (a *Analyzer) Write(samples []float64) []Frame {
+	var frames []Frame
+
+	for _, s := range samples {
+		// follow peaks quickly and let go slowly
+		r := math.Abs(s)
+		if r > a.envelope {
+			a.envelope = r + a.attack*(a.envelope-r)
+		} else {
+			a.envelope = r + a.decay*(a.envelope-r)
+		}
+
+		a.buf[a.position%WindowSize] = s
+		a.position++
+		a.pending++
+
+		if a.pending == Hop {
+			a.pending = 0
+
+			frames = append(frames, a.frame())
+		}
+	}
+
+	return frames
+}
+
+func (a *Analyzer) frame() Frame {
+	for i := range a.buf {
+		a.spec[i] = complex(a.buf[(a.position+i)%WindowSize]*a.window[i], 0)
+	}
+
+	fft(a.spec)
+
+	a.prev, a.spectrum = a.spectrum, a.prev
+
+	for i := range a.spectrum {
+		re, im := real(a.spec[i]), imag(a.spec[i])
+		a.spectrum[i] = math.Sqrt(re*re + im*im)
+	}
+
+	f := Frame{Envelope: level(a.envelope)}
+
+	binWidth := float64(a.rate) / WindowSize
+
+	var flux, bassFlux float64
+
+	for b, edges := range bandEdges {
+		lo := max(int(edges[0]/binWidth), 1)
+		hi := min(int(edges[1]/binWidth), len(a.spectrum)-1)
+
+		var power float64
+
+		for i := lo; i <= hi; i++ {
+			power += a.spectrum[i] * a.spectrum[i]
+
+			rise := max(a.spectrum[i]-a.prev[i], 0)
+			flux += rise
+
+			if Band(b) == Bass {
+				bassFlux += rise
+			}
+		}
+
+		// the peak amplitude of a sine with this power, undoing the window's gain
+		f.Bands[b] = level(math.Sqrt(power) * 4 / WindowSize)
+	}
+
+	scale := 4.0 / WindowSize
+	f.Onset = a.onsets.detect(flux*scale, a.position)
+	f.Beat = a.beats.detect(bassFlux*scale, a.position)
+
+	if f.Beat {
+		a.beat()
+	}
+
+	f.BPM = a.bpm()
+
+	return f
+}
+
+func (a *Analyzer) beat() {
+	if a.lastBeat >= 0 {
+		a.intervals = append(a.intervals, a.position-a.lastBeat)
+
+		if len(a.intervals) > beatIntervals {
+			a.intervals = a.intervals[1:]
+		}
+	}
+
+	a.lastBeat = a.position
+}
+
+// bpm returns the tempo of the median interval between beats, folded into 80 to 160 BPM,
+// so beats on every other count don't halve it.
+func (a *Analyzer) bpm() float64 {
+	if len(a.intervals) < 3 {
+		return 0
+	}
+
+	sorted := slices.Clone(a.intervals)
+	slices.Sort(sorted)
+
+	bpm := 60 * float64(a.rate) / float64(sorted[len(sorted)/2])
+
+	for bpm < 80 {
+		bpm *= 2
+	}
+
+	for bpm >= 160 {
+		bpm /= 2
+	}
+
+	return math.Round(bpm*10) / 10
+}
+
+// level maps an amplitude to 0 (Floor) to 1 (full scale) on a dB scale.
+func level(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return 0
+	}
+
+	db := 20 * math.Log10(amplitude)
+
+	return min(max((db-Floor)/-Floor, 0), 1)
+}
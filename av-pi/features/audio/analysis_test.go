@@ -0,0 +1,93 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+const rate = 44100
+
+func sine(freq, amplitude float64, n int) []float64 {
+	s := make([]float64, n)
+
+	for i := range s {
+		s[i] = amplitude * math.Sin(2*math.Pi*freq*float64(i)/rate)
+	}
+
+	return s
+}
+
+func TestBands(t *testing.T) {
+	a := NewAnalyzer(rate)
+
+	frames := a.Write(sine(100, 0.5, rate/2))
+	if len(frames) != rate/2/Hop {
+		t.Fatalf("got %d frames, want %d", len(frames), rate/2/Hop)
+	}
+
+	f := frames[len(frames)-1]
+
+	// -6 dBFS
+	if math.Abs(f.Bands[Bass]-0.9) > 0.03 {
+		t.Errorf("bass %.3f, want 0.9", f.Bands[Bass])
+	}
+
+	if f.Bands[Treble] > 0.3 {
+		t.Errorf("treble %.3f for a bass tone", f.Bands[Treble])
+	}
+
+	if math.Abs(f.Envelope-0.9) > 0.03 {
+		t.Errorf("envelope %.3f, want 0.9", f.Envelope)
+	}
+}
+
+func TestBeats(t *testing.T) {
+	a := NewAnalyzer(rate)
+
+	// a kick drum at 120 BPM: a decaying 60 Hz tone every half second
+	var samples []float64
+
+	for range 8 {
+		kick := sine(60, 0.8, rate/2)
+
+		for i := range kick {
+			kick[i] *= math.Exp(-float64(i) / (rate * 0.05))
+		}
+
+		samples = append(samples, kick...)
+	}
+
+	var beats int
+
+	var last Frame
+
+	for _, f := range a.Write(samples) {
+		if f.Beat {
+			beats++
+
+			if !f.Onset {
+				t.Error("a beat that isn't an onset")
+			}
+		}
+
+		last = f
+	}
+
+	if beats != 8 {
+		t.Errorf("got %d beats, want 8", beats)
+	}
+
+	if math.Abs(last.BPM-120) > 1 {
+		t.Errorf("tempo %g, want 120", last.BPM)
+	}
+}
+
+func TestSilence(t *testing.T) {
+	a := NewAnalyzer(rate)
+
+	for _, f := range a.Write(make([]float64, rate)) {
+		if f.Onset || f.Beat || f.Envelope != 0 || f.Bands != [BandMax]float64{} {
+			t.Fatalf("silence gave %+v", f)
+		}
+	}
+}
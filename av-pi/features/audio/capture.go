@@ -0,0 +1,111 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+)
+
+const (
+	// SampleRate is the rate audio is captured at, in mono.
+	SampleRate = 44100
+
+	// queueSize is how many actions wait to be published before further ones are dropped,
+	// so a slow action doesn't hold up the capture
+	queueSize = 256
+)
+
+// Listen captures from an ALSA device, e.g. "default" or "hw:1,0", and publishes the actions of the routes
+// until ctx is done or the capture fails. It records with arecord from alsa-utils.
+func Listen(ctx context.Context, device string, routes []Route, pub bus.Publisher) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "arecord", "-q", "-D", device,
+		"-f", "S16_LE", "-c", "1", "-r", strconv.Itoa(SampleRate), "-t", "raw")
+	cmd.Stderr = &stderr
+
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get arecord output: %w", err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start arecord: %w", err)
+	}
+
+	actions := make(chan bus.Action, queueSize)
+	published := make(chan struct{})
+
+	go func() {
+		defer close(published)
+
+		for a := range actions {
+			err := pub.Publish(a)
+			if err != nil {
+				zap.S().Debugw("failed to perform audio action", "action", a.String(), "error", err)
+			}
+		}
+	}()
+
+	err = analyse(out, NewAnalyzer(SampleRate), NewRouter(routes), actions)
+
+	close(actions)
+	<-published
+
+	waitErr := cmd.Wait()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	if waitErr != nil {
+		return fmt.Errorf("arecord failed: %w: %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+
+	return err
+}
+
+// analyse reads signed 16-bit little endian samples from r until it ends.
+func analyse(r io.Reader, a *Analyzer, router *Router, actions chan<- bus.Action) error {
+	buf := make([]byte, 2*Hop)
+	samples := make([]float64, Hop)
+
+	var bpm float64
+
+	for {
+		_, err := io.ReadFull(r, buf)
+		if err != nil {
+			return fmt.Errorf("failed to read audio: %w", err)
+		}
+
+		for i := range samples {
+			samples[i] = float64(int16(binary.LittleEndian.Uint16(buf[2*i:]))) / 32768
+		}
+
+		for _, f := range a.Write(samples) {
+			if f.BPM != 0 && f.BPM != bpm {
+				bpm = f.BPM
+
+				zap.S().Debugw("audio tempo", "bpm", bpm)
+			}
+
+			for _, action := range router.Route(f) {
+				select {
+				case actions <- action:
+				default:
+					zap.S().Debugw("dropped audio action", "action", action.String())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+)
+
+// Source is what a route takes from the frames.
+type Source int
+
+const (
+	Envelope Source = iota
+	BassLevel
+	MidLevel
+	TrebleLevel
+	Onset
+	Beat
+	SourceMax
+)
+
+var sourceNames = [SourceMax]string{"envelope", "bass", "mid", "treble", "onset", "beat"}
+
+func (s Source) String() string {
+	return sourceNames[s]
+}
+
+// Trigger reports whether the source fires, rather than being a level.
+func (s Source) Trigger() bool {
+	return s == Onset || s == Beat
+}
+
+func (s Source) level(f Frame) float64 {
+	switch s {
+	case Envelope:
+		return f.Envelope
+	case BassLevel:
+		return f.Bands[Bass]
+	case MidLevel:
+		return f.Bands[Mid]
+	case TrebleLevel:
+		return f.Bands[Treble]
+	}
+
+	return 0
+}
+
+func (s Source) fired(f Frame) bool {
+	return s == Onset && f.Onset || s == Beat && f.Beat
+}
+
+// the MIDI controller has CCs 0 to 3 and a gate per channel
+const (
+	maxCC       = 3
+	numChannels = 16
+)
+
+// Route sends a source to a MIDI CC or a sampler parameter, or publishes an action when it fires.
+type Route struct {
+	Source Source
+	// Param is the sampler parameter a level goes to, if any.
+	Param string
+	// set returns the action for a level
+	set func(v float64) bus.Action
+	// Action is published when a trigger fires, Release one frame later, e.g. to end a gate.
+	Action, Release bus.Action
+}
+
+// ParseRoutes parses comma separated source=target routes. Levels (envelope, bass, mid, treble) go to
+// "cc <0-3>" or "param <name>", triggers (onset, beat) to "gate <channel>", which is pulsed, or any action,
+// e.g. "beat=next-clip".
+func ParseRoutes(s string) ([]Route, error) {
+	var routes []Route
+
+	for _, r := range strings.Split(s, ",") {
+		if strings.TrimSpace(r) == "" {
+			continue
+		}
+
+		src, target, ok := strings.Cut(r, "=")
+		if !ok {
+			return nil, fmt.Errorf("want source=target, got %q", r)
+		}
+
+		i := slices.Index(sourceNames[:], strings.TrimSpace(src))
+		if i < 0 {
+			return nil, fmt.Errorf("unknown source %q, want one of %s", src, strings.Join(sourceNames[:], ", "))
+		}
+
+		route, err := parseTarget(Source(i), strings.Fields(target))
+		if err != nil {
+			return nil, fmt.Errorf("invalid target for %s: %w", Source(i), err)
+		}
+
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
+
+func parseTarget(src Source, target []string) (Route, error) {
+	r := Route{Source: src}
+
+	if !src.Trigger() {
+		if len(target) != 2 {
+			return r, fmt.Errorf("want \"cc <cc>\" or \"param <name>\", got %q", strings.Join(target, " "))
+		}
+
+		switch target[0] {
+		case "cc":
+			var cc int
+
+			_, err := fmt.Sscan(target[1], &cc)
+			if err != nil {
+				return r, fmt.Errorf("invalid CC: %w", err)
+			}
+
+			if cc < 0 || cc > maxCC {
+				return r, fmt.Errorf("no CC %d, want 0-%d", cc, maxCC)
+			}
+
+			r.set = func(v float64) bus.Action {
+				return bus.SetCC{CC: cc, Value: uint8(math.Round(v * 127))}
+			}
+		case "param":
+			name := target[1]
+			r.Param = name
+
+			r.set = func(v float64) bus.Action {
+				return bus.SetParam{Name: name, Value: v}
+			}
+		default:
+			return r, fmt.Errorf("want cc or param, got %q", target[0])
+		}
+
+		return r, nil
+	}
+
+	// a gate is pulsed rather than switched on
+	if len(target) == 2 && target[0] == "gate" {
+		var ch uint8
+
+		_, err := fmt.Sscan(target[1], &ch)
+		if err != nil {
+			return r, fmt.Errorf("invalid gate: %w", err)
+		}
+
+		if ch >= numChannels {
+			return r, fmt.Errorf("no gate %d, want 0-%d", ch, numChannels-1)
+		}
+
+		r.Action, r.Release = bus.Gate{Channel: ch, On: true}, bus.Gate{Channel: ch}
+
+		return r, nil
+	}
+
+	a, err := bus.Parse(strings.Join(target, " "))
+	if err != nil {
+		return r, err
+	}
+
+	r.Action = a
+
+	return r, nil
+}
+
+// CheckParams returns an error if a route goes to a parameter that isn't one of names.
+func CheckParams(routes []Route, names []string) error {
+	for _, r := range routes {
+		if r.Param != "" && !slices.Contains(names, r.Param) {
+			return fmt.Errorf("unknown parameter %q for %s, want one of %s", r.Param, r.Source, strings.Join(names, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Router turns frames into the actions of its routes. It is not safe for concurrent use.
+type Router struct {
+	routes []Route
+	// last is the level each route sent last, quantised to 7 bits, -1 before the first
+	last    []int
+	release []bus.Action
+}
+
+func NewRouter(routes []Route) *Router {
+	last := make([]int, len(routes))
+	for i := range last {
+		last[i] = -1
+	}
+
+	return &Router{routes: routes, last: last}
+}
+
+// Route returns the actions for f: the levels that changed, the releases of the last frame's triggers and
+// the actions of the triggers that fired.
+func (r *Router) Route(f Frame) []bus.Action {
+	actions := r.release
+	r.release = nil
+
+	for i, route := range r.routes {
+		if !route.Source.Trigger() {
+			// MIDI has 7 bits, finer changes aren't worth publishing
+			v := int(math.Round(route.Source.level(f) * 127))
+			if v != r.last[i] {
+				r.last[i] = v
+				actions = append(actions, route.set(float64(v)/127))
+			}
+
+			continue
+		}
+
+		if route.Source.fired(f) {
+			actions = append(actions, route.Action)
+
+			if route.Release != nil {
+				r.release = append(r.release, route.Release)
+			}
+		}
+	}
+
+	return actions
+}
@@ -0,0 +1,68 @@
+package audio
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+)
+
+func TestRouter(t *testing.T) {
+	routes, err := ParseRoutes("bass=cc 0, envelope=param brightness, beat=gate 5, onset=next-clip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRouter(routes)
+
+	tests := []struct {
+		frame Frame
+		want  []bus.Action
+	}{
+		{Frame{}, []bus.Action{bus.SetCC{CC: 0}, bus.SetParam{Name: "brightness"}}},
+		{Frame{}, nil},
+		{Frame{Bands: [BandMax]float64{Bass: 1}, Envelope: 0.001, Beat: true, Onset: true}, []bus.Action{
+			bus.SetCC{CC: 0, Value: 127}, bus.Gate{Channel: 5, On: true}, bus.NextClip{},
+		}},
+		{Frame{Bands: [BandMax]float64{Bass: 1}}, []bus.Action{bus.Gate{Channel: 5}}},
+	}
+
+	for i, tt := range tests {
+		if got := r.Route(tt.frame); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("frame %d: got %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestParseRoutesErrors(t *testing.T) {
+	for _, s := range []string{
+		"bass",
+		"kick=cc 0",
+		"bass=gate 5",
+		"bass=cc x",
+		"bass=cc 7",
+		"bass=cc -1",
+		"beat=gate 200",
+		"beat=gate 16",
+		"beat=jump",
+	} {
+		if _, err := ParseRoutes(s); err == nil {
+			t.Errorf("parsed %q", s)
+		}
+	}
+}
+
+func TestCheckParams(t *testing.T) {
+	routes, err := ParseRoutes("bass=param zoom, beat=next-clip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckParams(routes, []string{"hue", "zoom"}); err != nil {
+		t.Errorf("known parameter: %v", err)
+	}
+
+	if err := CheckParams(routes, []string{"hue"}); err == nil {
+		t.Error("unknown parameter passed")
+	}
+}
@@ -0,0 +1,512 @@
+package bus
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Action is a command for one of the services. Inputs publish actions, the services subscribe to them.
+// String returns the action in the text form understood by Parse.
+type Action interface {
+	fmt.Stringer
+	action()
+}
+
+// Sampler actions.
+type (
+	PrevClip       struct{}
+	NextClip       struct{}
+	PrevPlaylist   struct{}
+	NextPlaylist   struct{}
+	TogglePlay     struct{}
+	ToggleRecord   struct{}
+	ToggleMode     struct{}
+	RecordingsMenu struct{}
+
+	// ToggleDeck switches which deck the clip, playlist and mode actions control.
+	ToggleDeck     struct{}
+	Take           struct{}
+	NextTransition struct{}
+
+	// Crossfade sets the crossfader from 0 (deck A) to 1 (deck B).
+	Crossfade struct {
+		Position float64
+	}
+
+	// MoveCrossfader sets how fast the crossfader moves, -32767 to 32767 like a stick axis.
+	MoveCrossfader struct {
+		Speed int32
+	}
+
+	// SetIn marks the in point of the selected deck's clip, which Stutter jumps back to.
+	SetIn struct{}
+	// SetOut marks the out point and loops from the in point.
+	SetOut     struct{}
+	ToggleLoop struct{}
+	ClearLoop  struct{}
+	Stutter    struct{}
+
+	// LoopBeats loops Beats beats from the in point at the tempo.
+	LoopBeats struct {
+		Beats int
+	}
+
+	SetTempo struct {
+		BPM float64
+	}
+
+	// SetRate sets the playback rate of the selected deck, e.g. 0.5 for slow motion.
+	SetRate struct {
+		Rate float64
+	}
+
+	// Jog sets how fast the selected deck's clip is scrubbed, -32767 (backwards) to 32767 like a stick axis.
+	Jog struct {
+		Speed int32
+	}
+
+	// ToggleFreeze holds the current frame of the selected deck.
+	ToggleFreeze struct{}
+
+	// SetCue puts hot cue N (from 1) of the selected deck's clip where it is.
+	SetCue struct {
+		N int
+	}
+
+	JumpToCue struct {
+		N int
+	}
+
+	DeleteCue struct {
+		N int
+	}
+
+	// SetEffect sets a video effect parameter of the selected deck, e.g. hue to 90.
+	SetEffect struct {
+		Param string
+		Value float64
+	}
+
+	// MoveEffect sets how fast an effect parameter moves, -32767 to 32767 like a stick axis.
+	MoveEffect struct {
+		Param string
+		Speed int32
+	}
+
+	// ToggleFilter turns a video filter of the selected deck on or off, e.g. invert.
+	ToggleFilter struct {
+		Filter string
+	}
+
+	ResetEffects struct{}
+
+	// SetParam sets a parameter of the sampler's registry, e.g. hue, from 0 (its minimum) to 1 (its maximum).
+	SetParam struct {
+		Name  string
+		Value float64
+	}
+
+	// SelectClip plays clip N (from 1) of the selected deck's playlist.
+	SelectClip struct {
+		N int
+	}
+
+	// SelectPlaylist plays playlist N (from 1) on the selected deck.
+	SelectPlaylist struct {
+		N int
+	}
+
+	// TapTempo taps the tempo, the first tap after a pause starts a bar.
+	TapTempo struct{}
+
+	// SetQuantize makes clip and playlist switches wait for the next beat or bar, Mode is off, beat or bar.
+	SetQuantize struct {
+		Mode string
+	}
+
+	NextQuantize struct{}
+
+	// ClockBeat and ClockStart lock the beat grid to an external clock, e.g. MIDI clock or detected beats.
+	ClockBeat  struct{}
+	ClockStart struct{}
+)
+
+// MIDI actions.
+type (
+	// SetCC sets CC (0-3) to Value.
+	SetCC struct {
+		CC    int
+		Value uint8
+	}
+
+	// MoveCC sets how fast CC (0-3) moves, -32767 to 32767 like a stick axis.
+	MoveCC struct {
+		CC    int
+		Speed int32
+	}
+
+	Gate struct {
+		Channel uint8
+		On      bool
+	}
+
+	ToggleGate struct {
+		Channel uint8
+	}
+
+	IncStepSize struct{}
+	DecStepSize struct{}
+	PrevPort    struct{}
+	NextPort    struct{}
+	PortMenu    struct{}
+
+	// PortMode reports whether an input's Start/Select currently change the MIDI port instead of the step size.
+	PortMode struct {
+		On bool
+	}
+
+	// Learn maps the next control moved on the MIDI input to the sampler parameter Param.
+	Learn struct {
+		Param string
+	}
+
+	// Unlearn removes the mappings of a sampler parameter.
+	Unlearn struct {
+		Param string
+	}
+
+	LearnMenu struct{}
+)
+
+// App actions.
+type (
+	// NextMode switches to the next application mode, e.g. from the sampler to MIDI.
+	NextMode struct{}
+
+	SetMode struct {
+		Name string
+	}
+)
+
+// Macro runs its actions in order.
+type Macro []Action
+
+func (PrevClip) action()       {}
+func (NextClip) action()       {}
+func (PrevPlaylist) action()   {}
+func (NextPlaylist) action()   {}
+func (TogglePlay) action()     {}
+func (ToggleRecord) action()   {}
+func (ToggleMode) action()     {}
+func (RecordingsMenu) action() {}
+func (ToggleDeck) action()     {}
+func (Take) action()           {}
+func (NextTransition) action() {}
+func (Crossfade) action()      {}
+func (MoveCrossfader) action() {}
+func (SetIn) action()          {}
+func (SetOut) action()         {}
+func (ToggleLoop) action()     {}
+func (ClearLoop) action()      {}
+func (Stutter) action()        {}
+func (LoopBeats) action()      {}
+func (SetTempo) action()       {}
+func (SetRate) action()        {}
+func (Jog) action()            {}
+func (ToggleFreeze) action()   {}
+func (SetCue) action()         {}
+func (JumpToCue) action()      {}
+func (DeleteCue) action()      {}
+func (SetEffect) action()      {}
+func (MoveEffect) action()     {}
+func (ToggleFilter) action()   {}
+func (ResetEffects) action()   {}
+func (SetParam) action()       {}
+func (SelectClip) action()     {}
+func (SelectPlaylist) action() {}
+func (TapTempo) action()       {}
+func (SetQuantize) action()    {}
+func (NextQuantize) action()   {}
+func (ClockBeat) action()      {}
+func (ClockStart) action()     {}
+func (SetCC) action()          {}
+func (MoveCC) action()         {}
+func (Gate) action()           {}
+func (ToggleGate) action()     {}
+func (IncStepSize) action()    {}
+func (DecStepSize) action()    {}
+func (PrevPort) action()       {}
+func (NextPort) action()       {}
+func (PortMenu) action()       {}
+func (PortMode) action()       {}
+func (Learn) action()          {}
+func (Unlearn) action()        {}
+func (LearnMenu) action()      {}
+func (NextMode) action()       {}
+func (SetMode) action()        {}
+func (Macro) action()          {}
+
+func (PrevClip) String() string         { return "prev-clip" }
+func (NextClip) String() string         { return "next-clip" }
+func (PrevPlaylist) String() string     { return "prev-playlist" }
+func (NextPlaylist) String() string     { return "next-playlist" }
+func (TogglePlay) String() string       { return "play" }
+func (ToggleRecord) String() string     { return "record" }
+func (ToggleMode) String() string       { return "mode" }
+func (RecordingsMenu) String() string   { return "recordings" }
+func (ToggleDeck) String() string       { return "deck" }
+func (Take) String() string             { return "take" }
+func (NextTransition) String() string   { return "next-transition" }
+func (a Crossfade) String() string      { return fmt.Sprintf("crossfade %g", a.Position) }
+func (a MoveCrossfader) String() string { return fmt.Sprintf("move-crossfader %d", a.Speed) }
+func (SetIn) String() string            { return "set-in" }
+func (SetOut) String() string           { return "set-out" }
+func (ToggleLoop) String() string       { return "loop" }
+func (ClearLoop) String() string        { return "clear-loop" }
+func (Stutter) String() string          { return "stutter" }
+func (a LoopBeats) String() string      { return fmt.Sprintf("loop-beats %d", a.Beats) }
+func (a SetTempo) String() string       { return fmt.Sprintf("tempo %g", a.BPM) }
+func (a SetRate) String() string        { return fmt.Sprintf("rate %g", a.Rate) }
+func (a Jog) String() string            { return fmt.Sprintf("jog %d", a.Speed) }
+func (ToggleFreeze) String() string     { return "freeze" }
+func (a SetCue) String() string         { return fmt.Sprintf("set-cue %d", a.N) }
+func (a JumpToCue) String() string      { return fmt.Sprintf("cue %d", a.N) }
+func (a DeleteCue) String() string      { return fmt.Sprintf("delete-cue %d", a.N) }
+func (a SetEffect) String() string      { return fmt.Sprintf("set-fx %s %g", a.Param, a.Value) }
+func (a MoveEffect) String() string     { return fmt.Sprintf("move-fx %s %d", a.Param, a.Speed) }
+func (a ToggleFilter) String() string   { return "filter " + a.Filter }
+func (ResetEffects) String() string     { return "reset-fx" }
+func (a SetParam) String() string       { return fmt.Sprintf("param %s %g", a.Name, a.Value) }
+func (a SelectClip) String() string     { return fmt.Sprintf("clip %d", a.N) }
+func (a SelectPlaylist) String() string { return fmt.Sprintf("playlist %d", a.N) }
+func (TapTempo) String() string         { return "tap" }
+func (a SetQuantize) String() string    { return "quantize " + a.Mode }
+func (NextQuantize) String() string     { return "next-quantize" }
+func (ClockBeat) String() string        { return "clock-beat" }
+func (ClockStart) String() string       { return "clock-start" }
+func (a SetCC) String() string          { return fmt.Sprintf("set-cc %d %d", a.CC, a.Value) }
+func (a MoveCC) String() string         { return fmt.Sprintf("move-cc %d %d", a.CC, a.Speed) }
+func (a Gate) String() string           { return fmt.Sprintf("gate %d %s", a.Channel, onOff(a.On)) }
+func (a ToggleGate) String() string     { return fmt.Sprintf("toggle-gate %d", a.Channel) }
+func (IncStepSize) String() string      { return "step-up" }
+func (DecStepSize) String() string      { return "step-down" }
+func (PrevPort) String() string         { return "prev-port" }
+func (NextPort) String() string         { return "next-port" }
+func (PortMenu) String() string         { return "port-menu" }
+func (a PortMode) String() string       { return "port-mode " + onOff(a.On) }
+func (a Learn) String() string          { return "learn " + a.Param }
+func (a Unlearn) String() string        { return "unlearn " + a.Param }
+func (LearnMenu) String() string        { return "learn-menu" }
+func (NextMode) String() string         { return "next-mode" }
+func (a SetMode) String() string        { return "set-mode " + a.Name }
+
+func (m Macro) String() string {
+	s := make([]string, len(m))
+
+	for i, a := range m {
+		s[i] = a.String()
+	}
+
+	return strings.Join(s, "; ")
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+
+	return "off"
+}
+
+// simple are the actions without arguments, by name.
+var simple = map[string]Action{}
+
+func init() {
+	for _, a := range []Action{
+		PrevClip{}, NextClip{}, PrevPlaylist{}, NextPlaylist{}, TogglePlay{}, ToggleRecord{}, ToggleMode{}, RecordingsMenu{},
+		ToggleDeck{}, Take{}, NextTransition{}, SetIn{}, SetOut{}, ToggleLoop{}, ClearLoop{}, Stutter{}, ToggleFreeze{}, ResetEffects{},
+		TapTempo{}, NextQuantize{}, ClockBeat{}, ClockStart{},
+		IncStepSize{}, DecStepSize{}, PrevPort{}, NextPort{}, PortMenu{}, LearnMenu{}, NextMode{},
+	} {
+		simple[a.String()] = a
+	}
+}
+
+// Parse reads an action in its text form, e.g. "next-clip" or "gate 5 on".
+// Several actions separated by ";" are returned as a Macro.
+func Parse(s string) (Action, error) {
+	if strings.Contains(s, ";") {
+		var m Macro
+
+		for _, part := range strings.Split(s, ";") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+
+			a, err := Parse(part)
+			if err != nil {
+				return nil, err
+			}
+
+			m = append(m, a)
+		}
+
+		return m, nil
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty action")
+	}
+
+	name, args := fields[0], fields[1:]
+
+	if a, ok := simple[name]; ok {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("%s takes no arguments", name)
+		}
+
+		return a, nil
+	}
+
+	var (
+		a   Action
+		err error
+	)
+
+	switch name {
+	case "crossfade":
+		var v Crossfade
+		err = scan(args, &v.Position)
+		a = v
+	case "move-crossfader":
+		var v MoveCrossfader
+		err = scan(args, &v.Speed)
+		a = v
+	case "loop-beats":
+		var v LoopBeats
+		err = scan(args, &v.Beats)
+		a = v
+	case "tempo":
+		var v SetTempo
+		err = scan(args, &v.BPM)
+		a = v
+	case "rate":
+		var v SetRate
+		err = scan(args, &v.Rate)
+		a = v
+	case "jog":
+		var v Jog
+		err = scan(args, &v.Speed)
+		a = v
+	case "set-cue":
+		var v SetCue
+		err = scan(args, &v.N)
+		a = v
+	case "cue":
+		var v JumpToCue
+		err = scan(args, &v.N)
+		a = v
+	case "delete-cue":
+		var v DeleteCue
+		err = scan(args, &v.N)
+		a = v
+	case "set-fx":
+		var v SetEffect
+		err = scan(args, &v.Param, &v.Value)
+		a = v
+	case "move-fx":
+		var v MoveEffect
+		err = scan(args, &v.Param, &v.Speed)
+		a = v
+	case "filter":
+		var v ToggleFilter
+		err = scan(args, &v.Filter)
+		a = v
+	case "param":
+		var v SetParam
+		err = scan(args, &v.Name, &v.Value)
+		a = v
+	case "clip":
+		var v SelectClip
+		err = scan(args, &v.N)
+		a = v
+	case "playlist":
+		var v SelectPlaylist
+		err = scan(args, &v.N)
+		a = v
+	case "quantize":
+		var v SetQuantize
+		err = scan(args, &v.Mode)
+		a = v
+	case "set-cc":
+		var v SetCC
+		err = scan(args, &v.CC, &v.Value)
+		a = v
+	case "move-cc":
+		var v MoveCC
+		err = scan(args, &v.CC, &v.Speed)
+		a = v
+	case "gate":
+		var v Gate
+		err = scan(args, &v.Channel, &v.On)
+		a = v
+	case "toggle-gate":
+		var v ToggleGate
+		err = scan(args, &v.Channel)
+		a = v
+	case "port-mode":
+		var v PortMode
+		err = scan(args, &v.On)
+		a = v
+	case "learn":
+		var v Learn
+		err = scan(args, &v.Param)
+		a = v
+	case "unlearn":
+		var v Unlearn
+		err = scan(args, &v.Param)
+		a = v
+	case "set-mode":
+		var v SetMode
+		err = scan(args, &v.Name)
+		a = v
+	default:
+		return nil, fmt.Errorf("unknown action %q", name)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments for %s: %w", name, err)
+	}
+
+	return a, nil
+}
+
+// scan parses one argument per target, accepting on/off for bools.
+func scan(args []string, targets ...any) error {
+	if len(args) != len(targets) {
+		return fmt.Errorf("want %d arguments, got %d", len(targets), len(args))
+	}
+
+	for i, t := range targets {
+		if b, ok := t.(*bool); ok {
+			switch args[i] {
+			case "on":
+				*b = true
+			case "off":
+				*b = false
+			default:
+				return fmt.Errorf("want on or off, got %q", args[i])
+			}
+
+			continue
+		}
+
+		_, err := fmt.Sscan(args[i], t)
+		if err != nil {
+			return fmt.Errorf("argument %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+// Package bus decouples the inputs (gamepad, keyboard, network, ...) from the services they control.
+// Inputs publish typed actions, services subscribe and handle the ones they know.
+package bus
+
+import (
+	"errors"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Handler handles an action. It returns nil for actions it doesn't know.
+type Handler func(Action) error
+
+type Publisher interface {
+	Publish(a Action) error
+}
+
+// Bus delivers every published action to all subscribers. It is safe for concurrent use.
+type Bus struct {
+	mu     sync.Mutex
+	nextID int
+	subs   []subscription
+}
+
+type subscription struct {
+	id int
+	h  Handler
+}
+
+func New() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds h, which is called for every action published from then on until unsubscribe is called.
+func (b *Bus) Subscribe(h Handler) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+
+	b.subs = append(b.subs, subscription{id: id, h: h})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		for i, s := range b.subs {
+			if s.id == id {
+				b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+
+				return
+			}
+		}
+	}
+}
+
+// Publish passes a to the subscribers in the order they subscribed and waits until all have handled it.
+// Macros are expanded into their actions. The subscribers' errors are joined.
+// Handlers may publish further actions.
+func (b *Bus) Publish(a Action) error {
+	if m, ok := a.(Macro); ok {
+		var errs []error
+
+		for _, a := range m {
+			errs = append(errs, b.Publish(a))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	zap.S().Debugw("action", "action", a.String())
+
+	b.mu.Lock()
+	subs := b.subs
+	b.mu.Unlock()
+
+	var errs []error
+
+	for _, s := range subs {
+		errs = append(errs, s.h(a))
+	}
+
+	return errors.Join(errs...)
+}
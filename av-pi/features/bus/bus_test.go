@@ -0,0 +1,204 @@
+package bus
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestPublish(t *testing.T) {
+	b := New()
+
+	var got []string
+
+	b.Subscribe(func(a Action) error {
+		got = append(got, "1 "+a.String())
+
+		return nil
+	})
+
+	unsubscribe := b.Subscribe(func(a Action) error {
+		got = append(got, "2 "+a.String())
+
+		return nil
+	})
+
+	err := b.Publish(Macro{NextClip{}, Gate{Channel: 5, On: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsubscribe()
+
+	err = b.Publish(ToggleRecord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"1 next-clip", "2 next-clip", "1 gate 5 on", "2 gate 5 on", "1 record"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPublishJoinsErrors(t *testing.T) {
+	b := New()
+
+	errA, errB := errors.New("a"), errors.New("b")
+	called := 0
+
+	b.Subscribe(func(Action) error { called++; return errA })
+	b.Subscribe(func(Action) error { called++; return errB })
+
+	err := b.Publish(NextClip{})
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("got %v, want both errors", err)
+	}
+
+	if called != 2 {
+		t.Errorf("a failing subscriber stopped delivery, %d called", called)
+	}
+}
+
+func TestHandlerMayPublish(t *testing.T) {
+	b := New()
+
+	var got []Action
+
+	b.Subscribe(func(a Action) error {
+		got = append(got, a)
+
+		if _, ok := a.(ToggleMode); ok {
+			return b.Publish(NextPlaylist{})
+		}
+
+		return nil
+	})
+
+	err := b.Publish(ToggleMode{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, []Action{ToggleMode{}, NextPlaylist{}}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	actions := []Action{
+		PrevClip{}, NextClip{}, PrevPlaylist{}, NextPlaylist{}, TogglePlay{}, ToggleRecord{}, ToggleMode{}, RecordingsMenu{},
+		ToggleDeck{}, Take{}, NextTransition{}, Crossfade{Position: 0.25}, MoveCrossfader{Speed: -100},
+		SetIn{}, SetOut{}, ToggleLoop{}, ClearLoop{}, Stutter{}, LoopBeats{Beats: 4}, SetTempo{BPM: 127.5},
+		SetRate{Rate: 0.5}, Jog{Speed: -32767}, ToggleFreeze{}, SetCue{N: 1}, JumpToCue{N: 2}, DeleteCue{N: 4},
+		SetEffect{Param: "hue", Value: -90}, MoveEffect{Param: "zoom", Speed: 32767}, ToggleFilter{Filter: "invert"}, ResetEffects{},
+		SetParam{Name: "rate", Value: 0.75}, SelectClip{N: 3}, SelectPlaylist{N: 1},
+		TapTempo{}, SetQuantize{Mode: "bar"}, NextQuantize{}, ClockBeat{}, ClockStart{},
+		SetCC{CC: 2, Value: 127}, MoveCC{CC: 1, Speed: -32767}, Gate{Channel: 15}, Gate{Channel: 4, On: true},
+		ToggleGate{Channel: 8}, IncStepSize{}, DecStepSize{}, PrevPort{}, NextPort{}, PortMenu{}, PortMode{On: true},
+		Learn{Param: "hue"}, Unlearn{Param: "zoom"}, LearnMenu{},
+		NextMode{}, SetMode{Name: "midi"},
+		Macro{NextPlaylist{}, ToggleRecord{}},
+	}
+
+	for _, a := range actions {
+		got, err := Parse(a.String())
+		if err != nil {
+			t.Errorf("Parse(%q): %v", a, err)
+
+			continue
+		}
+
+		if !reflect.DeepEqual(got, a) {
+			t.Errorf("Parse(%q) = %#v, want %#v", a, got, a)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{"", "dance", "next-clip 1", "gate 5", "gate 5 maybe", "set-cc 0 300", "toggle-gate x", "crossfade left"} {
+		_, err := Parse(s)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded", s)
+		}
+	}
+}
+
+type recorder struct {
+	mu      sync.Mutex
+	actions []Action
+}
+
+func (r *recorder) Publish(a Action) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.actions = append(r.actions, a)
+
+	if _, ok := a.(PortMenu); ok {
+		return fmt.Errorf("no ports")
+	}
+
+	return nil
+}
+
+func TestServe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("can't listen:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pub := &recorder{}
+	done := make(chan error)
+
+	go func() {
+		done <- Serve(ctx, ln, pub)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+
+	for _, tt := range []struct{ line, reply string }{
+		{"next-clip", "ok"},
+		{"gate 5 on; record", "ok"},
+		{"dance", `error: unknown action "dance"`},
+		{"port-menu", "error: no ports"},
+	} {
+		fmt.Fprintln(conn, tt.line)
+
+		reply, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if reply != tt.reply+"\n" {
+			t.Errorf("%q: got reply %q, want %q", tt.line, reply, tt.reply)
+		}
+	}
+
+	cancel()
+
+	err = <-done
+	if err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+
+	want := []Action{NextClip{}, Macro{Gate{Channel: 5, On: true}, ToggleRecord{}}, PortMenu{}}
+
+	if !reflect.DeepEqual(pub.actions, want) {
+		t.Errorf("published %v, want %v", pub.actions, want)
+	}
+}
@@ -0,0 +1,76 @@
+package bus
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Serve accepts connections on ln until ctx is done, e.g. for remote control with netcat.
+// Every line received is parsed with Parse and published to pub, and answered with "ok" or "error: ...".
+func Serve(ctx context.Context, ln net.Listener, pub Publisher) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		go serveConn(ctx, conn, pub)
+	}
+}
+
+func serveConn(ctx context.Context, conn net.Conn, pub Publisher) {
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	zap.S().Infow("remote control connected", "addr", conn.RemoteAddr().String())
+
+	sc := bufio.NewScanner(conn)
+
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		reply := "ok"
+
+		a, err := Parse(line)
+		if err == nil {
+			err = pub.Publish(a)
+		}
+
+		if err != nil {
+			reply = "error: " + strings.ReplaceAll(err.Error(), "\n", "; ")
+		}
+
+		_, err = fmt.Fprintln(conn, reply)
+		if err != nil {
+			break
+		}
+	}
+
+	if err := sc.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		zap.S().Warnw("remote control connection failed", "error", err)
+	}
+
+	zap.S().Infow("remote control disconnected", "addr", conn.RemoteAddr().String())
+}
@@ -1,14 +1,445 @@
 package cliui
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 
+	"github.com/eiannone/keyboard"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/sys/unix"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/menu"
+	"github.com/markus-wa/vlc-sampler/features/midictl"
+	"github.com/markus-wa/vlc-sampler/features/sampler/state"
+)
+
+const (
+	maxLogLines     = 500
+	refreshInterval = 100 * time.Millisecond
+)
+
+// UI is a full-screen terminal UI, e.g. for SSH sessions. It shows the sampler and MIDI state,
+// the open menu and a log pane, and passes key presses on to Keys.
+// Until Start is called, or if there is no terminal, it just prints lines of text.
 type UI struct {
+	out  io.Writer
+	keys chan keyboard.KeyEvent
+
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	tui     bool
+	status  string
+	midi    *midictl.State
+	sampler *state.Sampler
+	menu    *menu.View
+	log     []string
+}
+
+func New() *UI {
+	return &UI{
+		out:  os.Stdout,
+		keys: make(chan keyboard.KeyEvent, 16),
+		stop: make(chan struct{}),
+	}
+}
+
+// Keys returns the key presses, except for q and Ctrl+C which quit.
+// Keys are dropped if they aren't read.
+func (ui *UI) Keys() <-chan keyboard.KeyEvent {
+	return ui.keys
+}
+
+// Start shows the full-screen UI until q or Ctrl+C is pressed or the UI is closed. It blocks.
+// Without a terminal the UI keeps printing lines and Start returns when it is closed.
+func (ui *UI) Start() {
+	keys, err := keyboard.GetKeys(16)
+	if err != nil {
+		zap.S().Warnw("no terminal, using plain output", "error", err)
+
+		<-ui.stop
+
+		return
+	}
+
+	defer keyboard.Close()
+
+	ui.mu.Lock()
+	ui.tui = true
+	ui.mu.Unlock()
+
+	// alternate screen, hide cursor
+	fmt.Fprint(ui.out, "\033[?1049h\033[?25l")
+
+	defer func() {
+		ui.mu.Lock()
+		ui.tui = false
+		ui.mu.Unlock()
+
+		fmt.Fprint(ui.out, "\033[?25h\033[?1049l")
+	}()
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	var last string
+
+	for {
+		select {
+		case <-ui.stop:
+			return
+
+		case key := <-keys:
+			if key.Err != nil {
+				zap.S().Errorw("failed to read keyboard", "error", key.Err)
+
+				return
+			}
+
+			if key.Key == keyboard.KeyCtrlC || key.Rune == 'q' {
+				return
+			}
+
+			select {
+			case ui.keys <- key:
+			default:
+			}
+
+		case <-ticker.C:
+		}
+
+		width, height := terminalSize()
+
+		screen := ui.render(width, height, time.Now())
+		if screen == last {
+			continue
+		}
+
+		last = screen
+
+		fmt.Fprint(ui.out, "\033[H"+screen+"\033[J")
+	}
+}
+
+// Close makes Start return.
+func (ui *UI) Close() error {
+	ui.closeOnce.Do(func() {
+		close(ui.stop)
+	})
+
+	return nil
+}
+
+// KeyAction returns the action bound to a key, see the help line.
+func KeyAction(key keyboard.KeyEvent) (bus.Action, bool) {
+	switch key.Key {
+	case keyboard.KeyArrowLeft:
+		return bus.PrevClip{}, true
+	case keyboard.KeyArrowRight:
+		return bus.NextClip{}, true
+	case keyboard.KeyArrowUp:
+		return bus.PrevPlaylist{}, true
+	case keyboard.KeyArrowDown:
+		return bus.NextPlaylist{}, true
+	}
+
+	switch unicode.ToLower(key.Rune) {
+	case 'p':
+		return bus.TogglePlay{}, true
+	case 'r':
+		return bus.ToggleRecord{}, true
+	case 'm':
+		return bus.ToggleMode{}, true
+	case 'l':
+		return bus.RecordingsMenu{}, true
+	case 'd':
+		return bus.ToggleDeck{}, true
+	case 't':
+		return bus.Take{}, true
+	case 'f':
+		return bus.NextTransition{}, true
+	}
+
+	return nil, false
+}
+
+// Control publishes the actions bound to the keys until ctx is done. While a menu is open the keys navigate it.
+func (ui *UI) Control(ctx context.Context, menus *menu.Navigator, pub bus.Publisher) {
+	for {
+		var key keyboard.KeyEvent
+
+		select {
+		case <-ctx.Done():
+			return
+		case key = <-ui.keys:
+		}
+
+		handled, err := menus.HandleKey(key)
+
+		if a, ok := KeyAction(key); ok && !handled && err == nil {
+			err = pub.Publish(a)
+		}
+
+		if err != nil {
+			zap.S().Errorw("failed to handle key", "error", err)
+			ui.SendError(err.Error())
+		}
+	}
+}
+
+// SendText adds the text to the log pane.
+func (ui *UI) SendText(text string) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.appendLog(time.Now().Format("15:04:05 ") + text)
+
+	if !ui.tui {
+		fmt.Fprintf(ui.out, "\r\033[Kcliui: %s\n%s", text, ui.status)
+	}
+}
+
+// Write adds log output to the log pane, so it doesn't garble the screen.
+func (ui *UI) Write(p []byte) (int, error) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if !ui.tui {
+		return os.Stderr.Write(p)
+	}
+
+	for _, l := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		ui.appendLog(l)
+	}
+
+	return len(p), nil
+}
+
+// Logger returns a development logger writing into the log pane.
+func (ui *UI) Logger() *zap.Logger {
+	return zap.New(zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.AddSync(ui),
+		zap.DebugLevel,
+	))
+}
+
+func (ui *UI) appendLog(line string) {
+	ui.log = append(ui.log, line)
+
+	if len(ui.log) > maxLogLines {
+		ui.log = ui.log[len(ui.log)-maxLogLines:]
+	}
+}
+
+func (ui *UI) SendError(text string) {
+	ui.SendText("error: " + text)
+}
+
+// SendMIDIState updates the MIDI panel.
+func (ui *UI) SendMIDIState(st midictl.State) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.midi = &st
+	ui.status = st.String()
+
+	if !ui.tui {
+		fmt.Fprintf(ui.out, "\r\033[K%s", ui.status)
+	}
+}
+
+// SendSamplerState updates the sampler panel.
+func (ui *UI) SendSamplerState(st state.Sampler) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.sampler = &st
+}
+
+// ShowMenu shows the menu between the panels and the log, marking the current item.
+// Without the full-screen UI it is printed again whenever the selection changes.
+func (ui *UI) ShowMenu(v menu.View) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.menu = &v
+
+	if !ui.tui {
+		fmt.Fprintf(ui.out, "\r\033[K%s\n%s", strings.Join(menuLines(v), "\n"), ui.status)
+	}
+}
+
+func (ui *UI) HideMenu() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.menu = nil
+}
+
+func terminalSize() (int, int) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 {
+		return 80, 24
+	}
+
+	return int(ws.Col), int(ws.Row)
+}
+
+const (
+	reverse = "\033[7m"
+	red     = "\033[31m"
+	reset   = "\033[0m"
+)
+
+const help = "←→ clip  ↑↓ list  p play  r rec  m mode  l recs  d deck  t take  f fade  q quit"
+
+// render draws the whole screen, one line per terminal row.
+func (ui *UI) render(width, height int, now time.Time) string {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	var lines []string
+
+	add := func(format string, args ...any) {
+		lines = append(lines, fit(fmt.Sprintf(format, args...), width))
+	}
+
+	lines = append(lines, reverse+fit(" av-pi", width)+reset)
+
+	add("Sampler")
+
+	if s := ui.sampler; s != nil {
+		playing := "stopped"
+		if s.Playing {
+			playing = "playing"
+		}
+
+		if s.Deck != "" {
+			add("  deck      %-12s on air %s, xfade %3.0f%% %s", s.Deck, s.OnAir, s.Crossfader*100, s.Transition)
+		}
+
+		add("  mode      %-12s %s", s.Mode, playing)
+		add("  playlist  %s", orDash(s.Playlist))
+		add("  clip      %s", orDash(s.Clip))
+
+		if s.Quantizing() || s.Pending != "" {
+			add("  quantize  %-12s %g BPM, pending %s", s.Quantize, s.BPM, orDash(s.Pending))
+		}
+
+		if s.Recording() {
+			lines = append(lines, red+fit("  record    ● REC "+state.FormatElapsed(s.Elapsed(now)), width)+reset)
+		} else {
+			add("  record    -")
+		}
+	} else {
+		add("  -")
+	}
+
+	add("MIDI")
+
+	if m := ui.midi; m != nil {
+		add("  port      %-12s mode %s, step size %d", orDash(m.Port), m.Mode, m.StepSize)
+
+		barW := min(max(width-16, 4), 40)
+
+		for i, cc := range m.CCs {
+			filled := int(cc) * barW / 127
+
+			add("  CC%d       %s%s %3d", i, strings.Repeat("█", filled), strings.Repeat("░", barW-filled), cc)
+		}
+
+		var gates strings.Builder
+
+		// channels below 4 carry the CCs
+		for ch := 4; ch < len(m.Gates); ch++ {
+			mark := "○"
+			if m.Gates[ch] {
+				mark = "●"
+			}
+
+			fmt.Fprintf(&gates, "%d%s ", ch, mark)
+		}
+
+		add("  gates     %s", gates.String())
+	} else {
+		add("  -")
+	}
+
+	if ui.menu != nil {
+		add("")
+
+		for _, l := range menuLines(*ui.menu) {
+			add("%s", l)
+		}
+	}
+
+	add("── log " + strings.Repeat("─", max(width-7, 0)))
+
+	// the log fills what is left above the help line, newest at the bottom
+	logH := max(height-len(lines)-1, 0)
+	first := max(len(ui.log)-logH, 0)
+
+	for _, l := range ui.log[first:] {
+		add("%s", l)
+	}
+
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+
+	lines = lines[:max(height-1, 0)]
+
+	lines = append(lines, reverse+fit(" "+help, width)+reset)
+
+	return strings.Join(lines, "\033[K\r\n")
+}
+
+func menuLines(v menu.View) []string {
+	lines := []string{"[ " + v.Title + " ]"}
+
+	for i, item := range v.Items {
+		prefix := "  "
+		if i == v.Current {
+			prefix = "> "
+		}
+
+		lines = append(lines, prefix+item)
+	}
+
+	return lines
 }
 
-func (ui UI) Start() {
-	// NOOP
+// fit cuts s to width runes and pads it, replacing control characters so they can't break the layout.
+func fit(s string, width int) string {
+	r := []rune(strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return ' '
+		}
+
+		return r
+	}, s))
+
+	if len(r) > width {
+		r = r[:width]
+	}
+
+	return string(r) + strings.Repeat(" ", width-len(r))
 }
 
-func (ui UI) SendText(text string) {
-	fmt.Println("cliui:", text)
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
 }
@@ -0,0 +1,220 @@
+package cliui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eiannone/keyboard"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/menu"
+	"github.com/markus-wa/vlc-sampler/features/midictl"
+	"github.com/markus-wa/vlc-sampler/features/sampler/state"
+)
+
+func newTestUI() (*UI, *bytes.Buffer) {
+	var out bytes.Buffer
+
+	ui := New()
+	ui.out = &out
+
+	return ui, &out
+}
+
+func TestRender(t *testing.T) {
+	ui, _ := newTestUI()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	ui.SendSamplerState(state.Sampler{
+		Deck:           "A",
+		OnAir:          "B",
+		Crossfader:     0.75,
+		Transition:     "timed-fade",
+		Mode:           "playlists",
+		Playlist:       "techno",
+		Clip:           "loop.mp4",
+		Playing:        true,
+		Quantize:       "bar",
+		BPM:            120,
+		Pending:        "next clip",
+		RecordingSince: now.Add(-83 * time.Second),
+	})
+
+	st := midictl.State{Port: "CH345", StepSize: 8, CCs: [4]uint8{0, 127, 63, 0}}
+	st.Gates[5] = true
+
+	ui.SendMIDIState(st)
+	ui.ShowMenu(menu.View{Title: "MIDI port", Items: []string{"default", "CH345"}, Current: 1})
+
+	screen := ui.render(80, 30, now)
+	lines := strings.Split(screen, "\033[K\r\n")
+
+	if len(lines) != 30 {
+		t.Errorf("got %d lines, want 30", len(lines))
+	}
+
+	for _, want := range []string{"techno", "loop.mp4", "REC 00:01:23", "on air B, xfade  75% timed-fade", "bar          120 BPM, pending next clip", "CH345", "5● ", "4○ ", "> CH345", "q quit"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen doesn't contain %q:\n%s", want, screen)
+		}
+	}
+}
+
+func TestRenderLogShowsNewestLines(t *testing.T) {
+	ui, _ := newTestUI()
+
+	for i := range 100 {
+		ui.SendText(fmt.Sprintf("line %d", i))
+	}
+
+	screen := ui.render(40, 20, time.Now())
+
+	if !strings.Contains(screen, "line 99") {
+		t.Error("newest line missing")
+	}
+
+	if strings.Contains(screen, "line 50 ") {
+		t.Error("old lines should have scrolled away")
+	}
+
+	if n := len(strings.Split(screen, "\033[K\r\n")); n != 20 {
+		t.Errorf("got %d lines, want 20", n)
+	}
+}
+
+func TestRenderSmallTerminal(t *testing.T) {
+	ui, _ := newTestUI()
+
+	ui.SendMIDIState(midictl.State{})
+	ui.SendText("a very long line that is much wider than the terminal\nwith a newline")
+
+	screen := ui.render(20, 5, time.Now())
+
+	for _, l := range strings.Split(screen, "\033[K\r\n") {
+		plain := strings.NewReplacer(reverse, "", red, "", reset, "").Replace(l)
+
+		if n := len([]rune(plain)); n != 20 {
+			t.Errorf("line %q is %d wide, want 20", plain, n)
+		}
+	}
+}
+
+func TestPlainOutputWithoutTerminal(t *testing.T) {
+	ui, out := newTestUI()
+
+	ui.SendText("hello")
+
+	if !strings.Contains(out.String(), "cliui: hello") {
+		t.Errorf("got %q", out.String())
+	}
+}
+
+func TestCloseStopsStartWithoutTerminal(t *testing.T) {
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		tty.Close()
+		t.Skip("running in a terminal")
+	}
+
+	ui, _ := newTestUI()
+
+	done := make(chan struct{})
+
+	go func() {
+		ui.Start()
+		close(done)
+	}()
+
+	err := ui.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start didn't return")
+	}
+}
+
+type recorder struct {
+	mu      sync.Mutex
+	actions []bus.Action
+}
+
+func (r *recorder) Publish(a bus.Action) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.actions = append(r.actions, a)
+
+	return nil
+}
+
+func (r *recorder) published() []bus.Action {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]bus.Action(nil), r.actions...)
+}
+
+func TestControl(t *testing.T) {
+	ui, _ := newTestUI()
+	menus := menu.NewNavigator(ui)
+	pub := &recorder{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go ui.Control(ctx, menus, pub)
+
+	ui.keys <- keyboard.KeyEvent{Key: keyboard.KeyArrowRight}
+	ui.keys <- keyboard.KeyEvent{Rune: 'R'}
+	ui.keys <- keyboard.KeyEvent{Rune: 'x'}
+
+	want := []bus.Action{bus.NextClip{}, bus.ToggleRecord{}}
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for !reflect.DeepEqual(pub.published(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("published %v, want %v", pub.published(), want)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// keys navigate an open menu instead
+	menus.Open(menu.Confirm("Delete?", nil))
+
+	ui.keys <- keyboard.KeyEvent{Key: keyboard.KeyArrowDown}
+
+	deadline = time.Now().Add(2 * time.Second)
+
+	for {
+		ui.mu.Lock()
+		current := ui.menu.Current
+		ui.mu.Unlock()
+
+		if current == 1 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("↓ didn't move in the menu")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := pub.published(); len(got) != 2 {
+		t.Errorf("↓ was published while a menu was open: %v", got)
+	}
+}
@@ -0,0 +1,232 @@
+package hud
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"time"
+
+	"github.com/golang/freetype/truetype"
+)
+
+//go:embed Roboto-Regular.ttf
+var robotoRegular []byte
+
+type Anchor string
+
+const (
+	AnchorTopLeft     Anchor = "top-left"
+	AnchorTopRight    Anchor = "top-right"
+	AnchorBottomLeft  Anchor = "bottom-left"
+	AnchorBottomRight Anchor = "bottom-right"
+	AnchorCenter      Anchor = "center"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+// Color is an RGBA color with components between 0 and 1.
+// In config files it is written as "#rrggbb" or "#rrggbbaa".
+type Color struct {
+	R, G, B, A float32
+}
+
+func (c Color) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("#%02x%02x%02x%02x", toByte(c.R), toByte(c.G), toByte(c.B), toByte(c.A))), nil
+}
+
+func (c *Color) UnmarshalText(text []byte) error {
+	var r, g, b, a uint8 = 0, 0, 0, 255
+
+	var err error
+
+	switch len(text) {
+	case 7:
+		_, err = fmt.Sscanf(string(text), "#%02x%02x%02x", &r, &g, &b)
+	case 9:
+		_, err = fmt.Sscanf(string(text), "#%02x%02x%02x%02x", &r, &g, &b, &a)
+	default:
+		err = fmt.Errorf("expected #rrggbb or #rrggbbaa")
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid color %q: %w", text, err)
+	}
+
+	*c = Color{float32(r) / 255, float32(g) / 255, float32(b) / 255, float32(a) / 255}
+
+	return nil
+}
+
+func (c Color) NRGBA() color.NRGBA {
+	return color.NRGBA{R: toByte(c.R), G: toByte(c.G), B: toByte(c.B), A: toByte(c.A)}
+}
+
+func toByte(v float32) uint8 {
+	return uint8(clamp01(v)*255 + 0.5)
+}
+
+// Duration is a time.Duration written as e.g. "2s" in config files.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", text, err)
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+// Style is how messages of one severity are shown.
+type Style struct {
+	Color    Color    `json:"color"`
+	Lifetime Duration `json:"lifetime"`
+}
+
+type Config struct {
+	// FontFile is a TrueType font, the embedded Roboto is used if empty.
+	FontFile string `json:"fontFile"`
+	FontSize int    `json:"fontSize"`
+
+	// Anchor is where the messages are placed, Margin is the distance to the screen edges.
+	Anchor  Anchor `json:"anchor"`
+	MarginX int    `json:"marginX"`
+	MarginY int    `json:"marginY"`
+
+	// Background is the opacity of the backdrop while messages are shown.
+	Background float32 `json:"background"`
+	MaxLines   int     `json:"maxLines"`
+
+	Info    Style `json:"info"`
+	Warning Style `json:"warning"`
+	Error   Style `json:"error"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		FontSize:   52,
+		Anchor:     AnchorTopLeft,
+		MarginX:    100,
+		MarginY:    100,
+		Background: 0.5,
+		MaxLines:   8,
+		Info: Style{
+			Color:    Color{1, 1, 1, 0.5},
+			Lifetime: Duration(2 * time.Second),
+		},
+		Warning: Style{
+			Color:    Color{1, 0.8, 0.2, 0.8},
+			Lifetime: Duration(4 * time.Second),
+		},
+		Error: Style{
+			Color:    Color{1, 0.25, 0.2, 0.9},
+			Lifetime: Duration(8 * time.Second),
+		},
+	}
+}
+
+// LoadConfig reads a JSON config file. Settings missing from the file keep their defaults.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read HUD config: %w", err)
+	}
+
+	err = json.Unmarshal(b, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to parse HUD config %q: %w", path, err)
+	}
+
+	err = cfg.validate()
+	if err != nil {
+		return cfg, fmt.Errorf("invalid HUD config %q: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+func (c Config) validate() error {
+	switch c.Anchor {
+	case AnchorTopLeft, AnchorTopRight, AnchorBottomLeft, AnchorBottomRight, AnchorCenter:
+	default:
+		return fmt.Errorf("unknown anchor %q", c.Anchor)
+	}
+
+	if c.FontSize <= 0 {
+		return fmt.Errorf("font size must be positive, got %d", c.FontSize)
+	}
+
+	if c.MaxLines <= 0 {
+		return fmt.Errorf("max lines must be positive, got %d", c.MaxLines)
+	}
+
+	if c.Background < 0 || c.Background > 1 {
+		return fmt.Errorf("background must be between 0 and 1, got %g", c.Background)
+	}
+
+	styles := []struct {
+		name  string
+		style Style
+	}{{"info", c.Info}, {"warning", c.Warning}, {"error", c.Error}}
+
+	for _, s := range styles {
+		if s.style.Lifetime <= 0 {
+			return fmt.Errorf("%s lifetime must be positive, got %s", s.name, time.Duration(s.style.Lifetime))
+		}
+	}
+
+	// the backends only load the font when they start, a bad one would crash them
+	b, err := c.Font()
+	if err != nil {
+		return err
+	}
+
+	_, err = truetype.Parse(b)
+	if err != nil {
+		return fmt.Errorf("failed to parse font %q: %w", c.FontFile, err)
+	}
+
+	return nil
+}
+
+// Style returns the style for messages of the given severity.
+func (c Config) Style(sev Severity) Style {
+	switch sev {
+	case SeverityWarning:
+		return c.Warning
+	case SeverityError:
+		return c.Error
+	default:
+		return c.Info
+	}
+}
+
+// Font returns the configured TrueType font file, or the embedded Roboto if none is set.
+func (c Config) Font() ([]byte, error) {
+	if c.FontFile == "" {
+		return robotoRegular, nil
+	}
+
+	b, err := os.ReadFile(c.FontFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font: %w", err)
+	}
+
+	return b, nil
+}
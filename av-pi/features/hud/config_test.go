@@ -0,0 +1,55 @@
+package hud
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	notAFont := filepath.Join(dir, "font.ttf")
+
+	err := os.WriteFile(notAFont, []byte("not a font"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, json string
+		ok         bool
+	}{
+		{"defaults", `{}`, true},
+		{"settings", `{"anchor": "center", "background": 1, "info": {"lifetime": "500ms"}}`, true},
+		{"anchor", `{"anchor": "middle"}`, false},
+		{"font size", `{"fontSize": 0}`, false},
+		{"max lines", `{"maxLines": -1}`, false},
+		{"background below 0", `{"background": -0.1}`, false},
+		{"background above 1", `{"background": 1.5}`, false},
+		{"zero lifetime", `{"warning": {"lifetime": "0s"}}`, false},
+		{"negative lifetime", `{"error": {"lifetime": "-2s"}}`, false},
+		{"missing font", `{"fontFile": "` + filepath.Join(dir, "missing.ttf") + `"}`, false},
+		{"invalid font", `{"fontFile": "` + notAFont + `"}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "hud.json")
+
+			err := os.WriteFile(path, []byte(tt.json), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = LoadConfig(path)
+			if tt.ok && err != nil {
+				t.Errorf("got %v", err)
+			}
+
+			if !tt.ok && err == nil {
+				t.Error("loaded")
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+// Package fbhud drives small preview LCDs through Linux framebuffer devices.
+package fbhud
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/markus-wa/vlc-sampler/features/hud"
+	"github.com/markus-wa/vlc-sampler/features/hud/raster"
+)
+
+const refreshInterval = 200 * time.Millisecond
+
+// Format is the pixel layout of a display buffer.
+type Format string
+
+const (
+	// FormatRGB565 is 16 bit little endian, common for SPI displays.
+	FormatRGB565 Format = "rgb565"
+	// FormatXRGB8888 is 32 bit little endian, i.e. B, G, R, X in memory.
+	FormatXRGB8888 Format = "xrgb8888"
+)
+
+// Options describe the display buffer. Zero values are read from sysfs,
+// which only works for framebuffer devices.
+type Options struct {
+	Width  int
+	Height int
+	// Stride is the length of a row in bytes, if it is longer than the visible pixels.
+	Stride int
+	Format Format
+}
+
+// Panel is a HUD backend showing a compact preview (clip, thumbnail, meters) on a
+// framebuffer device like /dev/fb1, or any other file taking raw pixels, e.g. an SPI display buffer.
+type Panel struct {
+	device   string
+	opts     Options
+	renderer *raster.Renderer
+	stop     chan struct{}
+}
+
+func New(cfg hud.Config, device string, opts Options) (*Panel, error) {
+	opts, err := fillOptions(device, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := raster.NewRenderer(cfg, opts.Width, opts.Height)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create renderer: %w", err)
+	}
+
+	return &Panel{
+		device:   device,
+		opts:     opts,
+		renderer: r,
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+func (p *Panel) Run(h *hud.Hud) {
+	f, err := os.OpenFile(p.device, os.O_WRONLY, 0)
+	if err != nil {
+		zap.S().Errorw("failed to open preview panel", "device", p.device, "error", err)
+
+		return
+	}
+
+	defer f.Close()
+
+	cfg := h.Config()
+	buf := make([]byte, p.opts.Stride*p.opts.Height)
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	var last *hud.Frame
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		frame := h.Frame()
+
+		if last != nil && reflect.DeepEqual(frame, *last) {
+			continue
+		}
+
+		last = &frame
+
+		encode(buf, p.render(cfg, frame), p.opts)
+
+		_, err := f.WriteAt(buf, 0)
+		if err != nil {
+			zap.S().Errorw("failed to write preview panel", "device", p.device, "error", err)
+		}
+	}
+}
+
+// Close stops Run.
+func (p *Panel) Close() {
+	close(p.stop)
+}
+
+func (p *Panel) render(cfg hud.Config, frame hud.Frame) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, p.opts.Width, p.opts.Height))
+
+	p.renderer.DrawList(img, hud.PreviewLayout(cfg, frame, p.opts.Width, p.opts.Height, p.renderer.Measure))
+
+	return img
+}
+
+func bytesPerPixel(f Format) int {
+	if f == FormatRGB565 {
+		return 2
+	}
+
+	return 4
+}
+
+// encode converts img into the buffer layout. The preview is opaque, so alpha is dropped.
+func encode(dst []byte, img *image.RGBA, opts Options) {
+	bpp := bytesPerPixel(opts.Format)
+
+	for y := 0; y < opts.Height; y++ {
+		row := dst[y*opts.Stride:]
+		src := img.Pix[y*img.Stride:]
+
+		for x := 0; x < opts.Width; x++ {
+			r, g, b := src[x*4], src[x*4+1], src[x*4+2]
+			px := row[x*bpp:]
+
+			switch opts.Format {
+			case FormatRGB565:
+				v := uint16(r>>3)<<11 | uint16(g>>2)<<5 | uint16(b>>3)
+
+				px[0], px[1] = byte(v), byte(v>>8)
+			default:
+				px[0], px[1], px[2], px[3] = b, g, r, 0xff
+			}
+		}
+	}
+}
+
+func fillOptions(device string, opts Options) (Options, error) {
+	sys := filepath.Join("/sys/class/graphics", filepath.Base(device))
+
+	if opts.Width == 0 || opts.Height == 0 {
+		size, err := readSysfs(sys, "virtual_size")
+		if err != nil {
+			return opts, err
+		}
+
+		_, err = fmt.Sscanf(size, "%d,%d", &opts.Width, &opts.Height)
+		if err != nil {
+			return opts, fmt.Errorf("failed to parse size of %s %q: %w", device, size, err)
+		}
+	}
+
+	if opts.Format == "" {
+		bits, err := readSysfs(sys, "bits_per_pixel")
+		if err != nil {
+			return opts, err
+		}
+
+		switch bits {
+		case "16":
+			opts.Format = FormatRGB565
+		case "32":
+			opts.Format = FormatXRGB8888
+		default:
+			return opts, fmt.Errorf("unsupported pixel depth of %s: %s bits", device, bits)
+		}
+	}
+
+	switch opts.Format {
+	case FormatRGB565, FormatXRGB8888:
+	default:
+		return opts, fmt.Errorf("unknown pixel format %q", opts.Format)
+	}
+
+	if opts.Stride == 0 {
+		opts.Stride = opts.Width * bytesPerPixel(opts.Format)
+
+		// sysfs knows about padding, but isn't there for other buffers
+		stride, err := readSysfs(sys, "stride")
+		if err == nil {
+			s, err := strconv.Atoi(stride)
+			if err == nil && s > opts.Stride {
+				opts.Stride = s
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+func readSysfs(dir, name string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read framebuffer info: %w", err)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
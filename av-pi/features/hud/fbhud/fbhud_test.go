@@ -0,0 +1,96 @@
+package fbhud
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/markus-wa/vlc-sampler/features/hud"
+)
+
+func TestEncode(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{R: 0xff, A: 0xff})
+	img.Set(1, 0, color.RGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff})
+
+	tests := []struct {
+		name string
+		opts Options
+		want []byte
+	}{
+		{"rgb565", Options{Width: 2, Height: 1, Stride: 6, Format: FormatRGB565}, []byte{0x00, 0xf8, 0xaa, 0x11, 0, 0}},
+		{"xrgb8888", Options{Width: 2, Height: 1, Stride: 8, Format: FormatXRGB8888}, []byte{0, 0, 0xff, 0xff, 0x56, 0x34, 0x12, 0xff}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := make([]byte, tt.opts.Stride)
+
+			encode(buf, img, tt.opts)
+
+			if string(buf) != string(tt.want) {
+				t.Errorf("got % x, want % x", buf, tt.want)
+			}
+		})
+	}
+}
+
+func TestPanelWritesBuffer(t *testing.T) {
+	device := filepath.Join(t.TempDir(), "fb")
+
+	err := os.WriteFile(device, nil, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := hud.DefaultConfig()
+
+	p, err := New(cfg, device, Options{Width: 160, Height: 128, Format: FormatRGB565})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := hud.NewHud(cfg, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.SetPreview("preview", hud.Preview{Title: "loop.mp4"})
+	h.SetMeter("cc0", hud.Meter{Label: "CC0", Value: 0.5})
+
+	done := make(chan struct{})
+
+	go func() {
+		h.Start()
+		close(done)
+	}()
+
+	want := int64(160 * 128 * 2)
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		fi, err := os.Stat(device)
+		if err == nil && fi.Size() == want {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("buffer not written")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	p.Close()
+	<-done
+}
+
+func TestFillOptionsRejectsUnknownFormat(t *testing.T) {
+	_, err := fillOptions("/dev/null", Options{Width: 1, Height: 1, Format: "yuv"})
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,98 @@
+package glhud
+
+import (
+	"log"
+	"runtime"
+
+	"github.com/go-gl/gl/all-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/nullboundary/glfont"
+
+	"github.com/markus-wa/vlc-sampler/features/hud"
+)
+
+func init() {
+	runtime.LockOSThread()
+}
+
+// Window is a HUD backend drawing into a transparent, floating fullscreen window.
+// It relies on the compositor to show it on top of the video.
+type Window struct{}
+
+func New() *Window {
+	return &Window{}
+}
+
+func (*Window) Run(h *hud.Hud) {
+	runtime.LockOSThread()
+
+	cfg := h.Config()
+
+	err := glfw.Init()
+	if err != nil {
+		log.Fatalln("failed to initialize glfw:", err)
+	}
+
+	defer glfw.Terminate()
+
+	mon := glfw.GetPrimaryMonitor()
+	mode := mon.GetVideoMode()
+
+	glfw.WindowHint(glfw.Resizable, glfw.False)
+	glfw.WindowHint(glfw.ContextVersionMajor, 3)
+	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+	glfw.WindowHint(glfw.TransparentFramebuffer, glfw.True)
+	glfw.WindowHint(glfw.Floating, glfw.True)
+	glfw.WindowHint(glfw.RedBits, mode.RedBits)
+	glfw.WindowHint(glfw.GreenBits, mode.GreenBits)
+	glfw.WindowHint(glfw.BlueBits, mode.BlueBits)
+	glfw.WindowHint(glfw.RefreshRate, mode.RefreshRate)
+
+	window, err := glfw.CreateWindow(mode.Width, mode.Height, "HUD", nil, nil)
+	if err != nil {
+		log.Panicf("glfw.CreateWindow: %v", err)
+	}
+
+	x, _ := window.GetPos()
+	window.SetPos(x, 0)
+	window.MakeContextCurrent()
+	glfw.SwapInterval(1)
+
+	err = gl.Init()
+	if err != nil {
+		log.Panicf("gl.Init: %v", err)
+	}
+
+	fontB, err := cfg.Font()
+	if err != nil {
+		log.Panicf("failed to load font: %v", err)
+	}
+
+	font, err := glfont.LoadFontBytes(fontB, int32(cfg.FontSize), mode.Width, mode.Height)
+	if err != nil {
+		log.Panicf("glfont.LoadFont: %v", err)
+	}
+
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+	gl.ClearColor(0.0, 0.0, 0.0, 0.8)
+
+	r := renderer{
+		font:   font,
+		width:  mode.Width,
+		height: mode.Height,
+	}
+
+	for !window.ShouldClose() {
+		frame := h.Frame()
+
+		gl.ClearColor(0.0, 0.0, 0.0, 0.0)
+		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+		r.draw(hud.Layout(cfg, frame, r.width, r.height, r.textWidth))
+
+		window.SwapBuffers()
+		glfw.PollEvents()
+	}
+}
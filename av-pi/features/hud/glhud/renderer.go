@@ -0,0 +1,49 @@
+package glhud
+
+import (
+	"log"
+
+	"github.com/go-gl/gl/all-core/gl"
+	"github.com/nullboundary/glfont"
+
+	"github.com/markus-wa/vlc-sampler/features/hud"
+)
+
+// renderer draws display lists with scissored clears for the rectangles and glfont for the text.
+// All rectangles of a frame must be drawn before any text, as clearing ignores blending.
+type renderer struct {
+	font   *glfont.Font
+	width  int
+	height int
+}
+
+func (r renderer) draw(d hud.DisplayList) {
+	for _, rect := range d.Rects {
+		r.fillRect(rect.X, rect.Y, rect.W, rect.H, rect.Color)
+	}
+
+	for _, t := range d.Texts {
+		r.print(t.X, t.Y, t.Scale, t.Color, t.Text)
+	}
+}
+
+func (r renderer) fillRect(x, y, w, h int, c hud.Color) {
+	gl.Enable(gl.SCISSOR_TEST)
+	gl.Scissor(int32(x), int32(r.height-y-h), int32(w), int32(h))
+	gl.ClearColor(c.R, c.G, c.B, c.A)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+	gl.Disable(gl.SCISSOR_TEST)
+}
+
+func (r renderer) textWidth(scale float32, text string) int {
+	return int(r.font.Width(scale, "%s", text))
+}
+
+func (r renderer) print(x, y int, scale float32, c hud.Color, text string) {
+	r.font.SetColor(c.R, c.G, c.B, c.A)
+
+	err := r.font.Printf(float32(x), float32(y), scale, "%s", text)
+	if err != nil {
+		log.Println("font.Printf:", err)
+	}
+}
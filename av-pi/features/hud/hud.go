@@ -1,117 +1,202 @@
 package hud
 
 import (
-	_ "embed"
-	"log"
-	"runtime"
-	"slices"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/go-gl/gl/all-core/gl"
-	"github.com/go-gl/glfw/v3.3/glfw"
-	"github.com/nullboundary/glfont"
+	"github.com/markus-wa/vlc-sampler/features/menu"
+	"github.com/markus-wa/vlc-sampler/features/midictl"
+	"github.com/markus-wa/vlc-sampler/features/sampler/state"
 )
 
-func init() {
-	runtime.LockOSThread()
+// Backend draws the frames of a Hud somewhere, e.g. into a window or the video output.
+type Backend interface {
+	// Run renders frames until the backend is closed. It blocks.
+	Run(h *Hud)
 }
 
-type Text struct {
-	text      string
-	expiresAt time.Time
+// Hud holds the messages and widgets to be shown, its backends take care of the drawing.
+type Hud struct {
+	cfg      Config
+	backends []Backend
+
+	mu       sync.Mutex
+	messages messages
+	widgets  widgets
+	menu     *menu.View
+	sampler  *state.Sampler
 }
 
-type Hud struct {
-	texts []Text
+// Frame is a snapshot of everything a backend has to draw.
+type Frame struct {
+	Messages []Message
+	Widgets  []Widget
+	Status   string
+	// Menu is the open menu, if any.
+	Menu *menu.View
 }
 
-//go:embed Roboto-Regular.ttf
-var robotoRegularB []byte
+func NewHud(cfg Config, backends ...Backend) (*Hud, error) {
+	err := cfg.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid HUD config: %w", err)
+	}
 
-func (h *Hud) Start() {
-	runtime.LockOSThread()
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no HUD backend")
+	}
 
-	err := glfw.Init()
-	if err != nil {
-		log.Fatalln("failed to initialize glfw:", err)
+	hud := &Hud{
+		cfg:      cfg,
+		backends: backends,
+		messages: messages{
+			maxLines: cfg.MaxLines,
+		},
+		widgets: newWidgets(),
 	}
 
-	defer glfw.Terminate()
+	return hud, nil
+}
 
-	mon := glfw.GetPrimaryMonitor()
-	mode := mon.GetVideoMode()
+// Start runs all backends, the first one on the calling goroutine. It blocks.
+func (h *Hud) Start() {
+	for _, b := range h.backends[1:] {
+		go b.Run(h)
+	}
 
-	glfw.WindowHint(glfw.Resizable, glfw.False)
-	glfw.WindowHint(glfw.ContextVersionMajor, 3)
-	glfw.WindowHint(glfw.ContextVersionMinor, 1)
-	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
-	glfw.WindowHint(glfw.TransparentFramebuffer, glfw.True)
-	glfw.WindowHint(glfw.Floating, glfw.True)
-	glfw.WindowHint(glfw.RedBits, mode.RedBits)
-	glfw.WindowHint(glfw.GreenBits, mode.GreenBits)
-	glfw.WindowHint(glfw.BlueBits, mode.BlueBits)
-	glfw.WindowHint(glfw.RefreshRate, mode.RefreshRate)
+	h.backends[0].Run(h)
+}
 
-	window, err := glfw.CreateWindow(mode.Width, mode.Height, "HUD", nil, nil)
-	if err != nil {
-		log.Panicf("glfw.CreateWindow: %v", err)
+// Close stops the backends that can be stopped, e.g. the video overlay before the players it draws into are released.
+func (h *Hud) Close() error {
+	var errs []error
+
+	for _, b := range h.backends {
+		switch b := b.(type) {
+		case interface{ Close() error }:
+			errs = append(errs, b.Close())
+		case interface{ Close() }:
+			b.Close()
+		}
 	}
 
-	x, _ := window.GetPos()
-	window.SetPos(x, 0)
-	window.MakeContextCurrent()
-	glfw.SwapInterval(1)
+	return errors.Join(errs...)
+}
+
+func (h *Hud) Config() Config {
+	return h.cfg
+}
 
-	err = gl.Init()
-	if err != nil {
-		log.Panicf("gl.Init: %v", err)
+// Frame expires old messages and returns what is currently visible.
+func (h *Hud) Frame() Frame {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+
+	h.messages.expire(now)
+
+	status := h.widgets.status
+
+	// the sampler's status includes the recording time, so it is rendered per frame
+	if h.sampler != nil {
+		status = strings.TrimPrefix(status+" | "+h.sampler.Status(now), " | ")
 	}
 
-	font, err := glfont.LoadFontBytes(robotoRegularB, int32(52), mode.Width, mode.Height)
-	if err != nil {
-		log.Panicf("glfont.LoadFont: %v", err)
+	return Frame{
+		Messages: h.messages.snapshot(),
+		Widgets:  h.widgets.snapshot(),
+		Status:   status,
+		Menu:     h.menu,
 	}
+}
 
-	gl.Enable(gl.DEPTH_TEST)
-	gl.DepthFunc(gl.LESS)
-	gl.ClearColor(0.0, 0.0, 0.0, 0.8)
+// Send shows a message styled and timed according to its severity. It is safe for concurrent use.
+func (h *Hud) Send(sev Severity, txt string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	for !window.ShouldClose() {
-		h.texts = slices.DeleteFunc(h.texts, func(t Text) bool {
-			return t.expiresAt.Before(time.Now())
-		})
+	h.messages.push(txt, sev, time.Duration(h.cfg.Style(sev).Lifetime), time.Now())
+}
 
-		if len(h.texts) > 0 {
-			gl.ClearColor(0.0, 0.0, 0.0, 0.5)
-		} else {
-			gl.ClearColor(0.0, 0.0, 0.0, 0.0)
-		}
+func (h *Hud) SendText(txt string) {
+	h.Send(SeverityInfo, txt)
+}
 
-		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+func (h *Hud) SendWarning(txt string) {
+	h.Send(SeverityWarning, txt)
+}
 
-		font.SetColor(1.0, 1.0, 1.0, 0.5)
+func (h *Hud) SendError(txt string) {
+	h.Send(SeverityError, txt)
+}
 
-		for i, t := range h.texts {
-			err = font.Printf(100, 100+float32(i*52), 1.0, t.text)
-			if err != nil {
-				log.Println("font.Printf:", err)
-			}
-		}
+// SendMIDIState shows the CCs as meters, the gates as lamps and the port in the status bar.
+func (h *Hud) SendMIDIState(state midictl.State) {
+	for i, cc := range state.CCs {
+		h.SetMeter(fmt.Sprintf("midi.cc%d", i), Meter{
+			Label:       fmt.Sprintf("CC%d", i),
+			Value:       float32(cc) / 127,
+			Orientation: Vertical,
+		})
+	}
+
+	// channels below 4 carry the CCs
+	for ch := 4; ch < len(state.Gates); ch++ {
+		h.SetLamp(fmt.Sprintf("midi.gate%d", ch), Lamp{
+			Label: fmt.Sprintf("G%d", ch),
+			On:    state.Gates[ch],
+		})
+	}
 
-		window.SwapBuffers()
-		glfw.PollEvents()
+	port := state.Port
+	if port == "" {
+		port = "no port"
 	}
+
+	h.SetStatus(fmt.Sprintf("MIDI %s | mode %s | step size %d", port, state.Mode, state.StepSize))
 }
 
-func (h *Hud) SendText(txt string) {
-	h.texts = append(h.texts, Text{
-		text:      txt,
-		expiresAt: time.Now().Add(2 * time.Second),
+// SendSamplerState adds the sampler's mode, clip and recording time to the status bar, lights the REC lamp
+// and lists the playlists and the clips of the playing one.
+func (h *Hud) SendSamplerState(st state.Sampler) {
+	h.SetLamp("sampler.rec", Lamp{
+		Label: "REC",
+		On:    st.Recording(),
 	})
+
+	if len(st.Playlists) > 0 {
+		h.SetList("sampler.playlists", List{Title: "Playlists", Items: st.Playlists, Current: st.PlaylistIndex})
+	} else {
+		h.RemoveWidget("sampler.playlists")
+	}
+
+	if len(st.Clips) > 0 {
+		h.SetList("sampler.clips", List{Title: st.Playlist, Items: st.Clips, Current: st.ClipIndex})
+	} else {
+		h.RemoveWidget("sampler.clips")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sampler = &st
 }
 
-func NewHud() (*Hud, error) {
-	hud := &Hud{}
+// ShowMenu shows a menu in the middle of the screen until HideMenu is called.
+func (h *Hud) ShowMenu(v menu.View) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	return hud, nil
+	h.menu = &v
+}
+
+func (h *Hud) HideMenu() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.menu = nil
 }
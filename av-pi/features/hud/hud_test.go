@@ -0,0 +1,51 @@
+package hud
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/markus-wa/vlc-sampler/features/sampler/state"
+)
+
+func TestSendSamplerStateLists(t *testing.T) {
+	h, err := NewHud(DefaultConfig(), nopBackend{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.SendSamplerState(state.Sampler{
+		Mode:          "playlists",
+		Playlist:      "live",
+		Playlists:     []string{"intro", "live"},
+		PlaylistIndex: 1,
+		Clips:         []string{"a.mp4", "b.mp4"},
+		ClipIndex:     0,
+	})
+
+	want := []Widget{
+		Lamp{Label: "REC"},
+		List{Title: "Playlists", Items: []string{"intro", "live"}, Current: 1},
+		List{Title: "live", Items: []string{"a.mp4", "b.mp4"}},
+	}
+
+	if got := h.Frame().Widgets; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// stream mode has no playlist to list the clips of
+	h.SendSamplerState(state.Sampler{
+		Mode:          "stream",
+		Playlists:     []string{"intro", "live"},
+		PlaylistIndex: -1,
+		ClipIndex:     -1,
+	})
+
+	want = []Widget{
+		Lamp{Label: "REC"},
+		List{Title: "Playlists", Items: []string{"intro", "live"}, Current: -1},
+	}
+
+	if got := h.Frame().Widgets; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,268 @@
+package hud
+
+import (
+	"image"
+
+	"github.com/markus-wa/vlc-sampler/features/menu"
+)
+
+var (
+	colorPanel     = Color{R: 0.0, G: 0.0, B: 0.0, A: 0.6}
+	colorMeterBg   = Color{R: 0.2, G: 0.2, B: 0.2, A: 0.6}
+	colorMeter     = Color{R: 0.2, G: 0.8, B: 0.3, A: 0.8}
+	colorLampOff   = Color{R: 0.3, G: 0.1, B: 0.1, A: 0.6}
+	colorLampOn    = Color{R: 1.0, G: 0.2, B: 0.1, A: 0.9}
+	colorHighlight = Color{R: 0.2, G: 0.4, B: 0.9, A: 0.7}
+	colorText      = Color{R: 1.0, G: 1.0, B: 1.0, A: 0.8}
+	colorMenu      = Color{R: 0.0, G: 0.0, B: 0.0, A: 0.85}
+)
+
+const labelScale = 0.5
+
+// Rect is a filled rectangle. Coordinates are in pixels with the origin at the top left.
+// It replaces whatever is below it, including the alpha channel.
+type Rect struct {
+	X, Y, W, H int
+	Color      Color
+}
+
+// Text is a line of text with its baseline at Y.
+// Scale is relative to the configured font size.
+type Text struct {
+	X, Y  int
+	Scale float32
+	Color Color
+	Text  string
+}
+
+// Picture is an image scaled into a rectangle.
+type Picture struct {
+	X, Y, W, H int
+	Image      image.Image
+}
+
+// DisplayList is a laid out frame. All rectangles are drawn first, then the pictures and then the text.
+type DisplayList struct {
+	Rects    []Rect
+	Pictures []Picture
+	Texts    []Text
+}
+
+// Measure returns the width of a text in pixels at the given scale.
+type Measure func(scale float32, text string) int
+
+func (d *DisplayList) rect(x, y, w, h int, c Color) {
+	d.Rects = append(d.Rects, Rect{x, y, w, h, c})
+}
+
+func (d *DisplayList) text(x, y int, scale float32, c Color, text string) {
+	d.Texts = append(d.Texts, Text{x, y, scale, c, text})
+}
+
+// Layout places everything in a frame on a screen of the given size, so all backends draw the same picture.
+// Meters and lamps go into a panel on the right, lists on the left below the messages
+// and the status bar along the bottom edge.
+func Layout(cfg Config, frame Frame, width, height int, measure Measure) DisplayList {
+	var d DisplayList
+
+	// menus are modal, and text of the widgets below would show through
+	if frame.Menu != nil {
+		frame.Widgets = nil
+	}
+
+	if len(frame.Messages) > 0 {
+		d.rect(0, 0, width, height, Color{A: cfg.Background})
+	}
+
+	layoutWidgets(&d, cfg, frame, width, height)
+	layoutMessages(&d, cfg, frame.Messages, width, height, measure)
+
+	if frame.Menu != nil {
+		layoutMenu(&d, cfg, *frame.Menu, width, height, 1.0, measure)
+	}
+
+	return d
+}
+
+func layoutWidgets(d *DisplayList, cfg Config, frame Frame, width, height int) {
+	lineHeight := cfg.FontSize
+	label := lineHeight / 2
+
+	// status bar
+	if frame.Status != "" {
+		barHeight := lineHeight * 3 / 2
+
+		d.rect(0, height-barHeight, width, barHeight, colorPanel)
+		d.text(cfg.MarginX, height-barHeight/3, 1.0, colorText, frame.Status)
+	}
+
+	// right panel: horizontal meters, then vertical meters, then lamps
+	panelW := width / 4
+	panelX := width - cfg.MarginX - panelW
+	y := cfg.MarginY
+
+	var (
+		vMeters []Meter
+		lamps   []Lamp
+		lists   []List
+	)
+
+	for _, w := range frame.Widgets {
+		switch w := w.(type) {
+		case Meter:
+			if w.Orientation == Vertical {
+				vMeters = append(vMeters, w)
+
+				continue
+			}
+
+			barH := label
+
+			d.text(panelX, y+label, labelScale, colorText, w.Label)
+
+			y += label + 4
+
+			d.rect(panelX, y, panelW, barH, colorMeterBg)
+			d.rect(panelX, y, int(float32(panelW)*w.Value), barH, colorMeter)
+
+			y += barH + label/2
+
+		case Lamp:
+			lamps = append(lamps, w)
+
+		case List:
+			lists = append(lists, w)
+		}
+	}
+
+	if len(vMeters) > 0 {
+		colW := panelW / max(len(vMeters), 4)
+		barH := lineHeight * 4
+
+		for i, m := range vMeters {
+			x := panelX + i*colW
+			fill := int(float32(barH) * m.Value)
+
+			d.rect(x, y, colW*2/3, barH, colorMeterBg)
+			d.rect(x, y+barH-fill, colW*2/3, fill, colorMeter)
+			d.text(x, y+barH+label, labelScale, colorText, m.Label)
+		}
+
+		y += barH + label*2
+	}
+
+	if len(lamps) > 0 {
+		const perRow = 4
+
+		colW := panelW / perRow
+		size := label
+
+		for i, l := range lamps {
+			x := panelX + (i%perRow)*colW
+			ly := y + (i/perRow)*(size+label*2)
+
+			c := colorLampOff
+			if l.On {
+				c = colorLampOn
+			}
+
+			d.rect(x, ly, size, size, c)
+			d.text(x, ly+size+label, labelScale, colorText, l.Label)
+		}
+	}
+
+	// left column: lists, starting below the messages and sharing the space above the status bar,
+	// each scrolled to keep its current item visible
+	y = height / 2
+	bottom := height - lineHeight*3/2
+
+	for n, l := range lists {
+		rows := max((bottom-y)/(len(lists)-n)/max(label, 1)-2, 1)
+		first := scrollTo(l.Current, len(l.Items), rows)
+
+		d.text(cfg.MarginX, y, labelScale*1.5, colorText, l.Title)
+
+		y += label
+
+		for i, item := range l.Items[first:min(first+rows, len(l.Items))] {
+			if first+i == l.Current {
+				d.rect(cfg.MarginX-8, y-label+4, width/3, label+4, colorHighlight)
+			}
+
+			d.text(cfg.MarginX, y, labelScale, colorText, item)
+
+			y += label
+		}
+
+		y += label
+	}
+}
+
+// layoutMessages places the message lines at the configured anchor.
+func layoutMessages(d *DisplayList, cfg Config, lines []Message, width, height int, measure Measure) {
+	lineHeight := cfg.FontSize
+
+	top := cfg.MarginY
+
+	switch cfg.Anchor {
+	case AnchorBottomLeft, AnchorBottomRight:
+		top = height - cfg.MarginY - (len(lines)-1)*lineHeight
+	case AnchorCenter:
+		top = (height-len(lines)*lineHeight)/2 + lineHeight
+	}
+
+	for i, m := range lines {
+		text := m.String()
+		x := cfg.MarginX
+
+		switch cfg.Anchor {
+		case AnchorTopRight, AnchorBottomRight:
+			x = width - cfg.MarginX - measure(1.0, text)
+		case AnchorCenter:
+			x = (width - measure(1.0, text)) / 2
+		}
+
+		d.text(x, top+i*lineHeight, 1.0, cfg.Style(m.Severity).Color, text)
+	}
+}
+
+// layoutMenu places the menu in a box in the middle of the screen, with the current item highlighted.
+// Long menus show as many items as fit, scrolled so the current one is visible.
+func layoutMenu(d *DisplayList, cfg Config, v menu.View, width, height int, scale float32, measure Measure) {
+	lineHeight := int(float32(cfg.FontSize) * scale)
+	pad := lineHeight / 2
+
+	rows := max((height-2*pad)/max(lineHeight, 1)-1, 1)
+	first := scrollTo(v.Current, len(v.Items), rows)
+	items := v.Items[first:min(first+rows, len(v.Items))]
+
+	boxW := width / 2
+	boxH := (len(items)+1)*lineHeight + 2*pad
+	x := (width - boxW) / 2
+	y := (height - boxH) / 2
+
+	d.rect(x, y, boxW, boxH, colorMenu)
+
+	textW := boxW - 2*pad
+
+	d.text(x+pad, y+pad+lineHeight*3/4, scale, colorText, fit(measure, scale, v.Title, textW))
+
+	for i, item := range items {
+		top := y + pad + (i+1)*lineHeight
+
+		if first+i == v.Current {
+			d.rect(x, top, boxW, lineHeight, colorHighlight)
+		}
+
+		d.text(x+pad, top+lineHeight*3/4, scale*0.8, colorText, fit(measure, scale*0.8, item, textW))
+	}
+}
+
+// scrollTo returns the first of rows visible items out of n, keeping current roughly in the middle.
+func scrollTo(current, n, rows int) int {
+	if n <= rows {
+		return 0
+	}
+
+	return min(max(current-rows/2, 0), n-rows)
+}
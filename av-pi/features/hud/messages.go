@@ -0,0 +1,109 @@
+package hud
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Message is a transient line of text. Count is the number of coalesced repetitions.
+type Message struct {
+	Text     string
+	Severity Severity
+	Count    int
+}
+
+func (m Message) String() string {
+	if m.Count > 1 {
+		return fmt.Sprintf("%s (x%d)", m.Text, m.Count)
+	}
+
+	return m.Text
+}
+
+type message struct {
+	Message
+
+	expiresAt time.Time
+}
+
+// messages is the queue of transient text lines shown by the HUD.
+// It is not safe for concurrent use, Hud guards it with its mutex.
+type messages struct {
+	lines    []message
+	maxLines int
+}
+
+// push appends a line, coalescing bursts of similar messages of the same severity:
+// a repeated text bumps the count of the last line, and a text that only differs
+// from the last line in its trailing value (e.g. an axis position) replaces it.
+// Once there are more than maxLines lines the oldest ones scroll off.
+func (q *messages) push(text string, sev Severity, lifetime time.Duration, now time.Time) {
+	expiresAt := now.Add(lifetime)
+
+	if n := len(q.lines); n > 0 && q.lines[n-1].Severity == sev {
+		last := &q.lines[n-1]
+
+		if last.Text == text {
+			last.Count++
+			last.expiresAt = expiresAt
+
+			return
+		}
+
+		if key := coalesceKey(text); key != "" && key == coalesceKey(last.Text) {
+			last.Text = text
+			last.Count = 1
+			last.expiresAt = expiresAt
+
+			return
+		}
+	}
+
+	q.lines = append(q.lines, message{
+		Message: Message{
+			Text:     text,
+			Severity: sev,
+			Count:    1,
+		},
+		expiresAt: expiresAt,
+	})
+
+	if len(q.lines) > q.maxLines {
+		q.lines = slices.Delete(q.lines, 0, len(q.lines)-q.maxLines)
+	}
+}
+
+func (q *messages) expire(now time.Time) {
+	q.lines = slices.DeleteFunc(q.lines, func(m message) bool {
+		return m.expiresAt.Before(now)
+	})
+}
+
+func (q *messages) snapshot() []Message {
+	msgs := make([]Message, len(q.lines))
+
+	for i, m := range q.lines {
+		msgs[i] = m.Message
+	}
+
+	return msgs
+}
+
+// coalesceKey returns the text without its trailing number,
+// or an empty string if the text doesn't end in a number.
+func coalesceKey(text string) string {
+	i := strings.LastIndexByte(text, ' ')
+	if i < 0 {
+		return ""
+	}
+
+	value := strings.TrimPrefix(text[i+1:], "-")
+
+	if value == "" || strings.Trim(value, "0123456789.") != "" {
+		return ""
+	}
+
+	return text[:i]
+}
@@ -0,0 +1,150 @@
+package hud
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPush(t *testing.T) {
+	type push struct {
+		text string
+		sev  Severity
+	}
+
+	tests := []struct {
+		name   string
+		pushes []push
+		want   []Message
+	}{
+		{
+			name:   "burst of the same text",
+			pushes: []push{{"next clip", SeverityInfo}, {"next clip", SeverityInfo}, {"next clip", SeverityInfo}},
+			want:   []Message{{Text: "next clip", Count: 3}},
+		},
+		{
+			name:   "burst of values",
+			pushes: []push{{"crossfader 0.1", SeverityInfo}, {"crossfader 0.25", SeverityInfo}, {"crossfader -1", SeverityInfo}},
+			want:   []Message{{Text: "crossfader -1", Count: 1}},
+		},
+		{
+			name:   "repeated value after a burst",
+			pushes: []push{{"CC0 1", SeverityInfo}, {"CC0 2", SeverityInfo}, {"CC0 2", SeverityInfo}},
+			want:   []Message{{Text: "CC0 2", Count: 2}},
+		},
+		{
+			name:   "different severity",
+			pushes: []push{{"no port", SeverityInfo}, {"no port", SeverityError}},
+			want:   []Message{{Text: "no port", Count: 1}, {Text: "no port", Severity: SeverityError, Count: 1}},
+		},
+		{
+			name:   "interrupted burst",
+			pushes: []push{{"play", SeverityInfo}, {"record", SeverityInfo}, {"play", SeverityInfo}},
+			want:   []Message{{Text: "play", Count: 1}, {Text: "record", Count: 1}, {Text: "play", Count: 1}},
+		},
+		{
+			name:   "scroll off",
+			pushes: []push{{"a", SeverityInfo}, {"b", SeverityInfo}, {"c", SeverityInfo}, {"d", SeverityInfo}},
+			want:   []Message{{Text: "b", Count: 1}, {Text: "c", Count: 1}, {Text: "d", Count: 1}},
+		},
+	}
+
+	now := time.Unix(0, 0)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := messages{maxLines: 3}
+
+			for _, p := range tt.pushes {
+				q.push(p.text, p.sev, time.Second, now)
+			}
+
+			if got := q.snapshot(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpire(t *testing.T) {
+	q := messages{maxLines: 3}
+	start := time.Unix(0, 0)
+
+	q.push("a", SeverityInfo, time.Second, start)
+	q.push("b", SeverityError, 3*time.Second, start)
+	q.push("c", SeverityInfo, time.Second, start.Add(time.Second))
+
+	// a repetition keeps the line alive
+	q.push("c", SeverityInfo, time.Second, start.Add(2*time.Second))
+
+	q.expire(start.Add(2500 * time.Millisecond))
+
+	want := []Message{{Text: "b", Severity: SeverityError, Count: 1}, {Text: "c", Count: 2}}
+
+	if got := q.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	q.expire(start.Add(4 * time.Second))
+
+	if got := q.snapshot(); len(got) != 0 {
+		t.Errorf("got %+v after all lifetimes", got)
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	for text, want := range map[string]string{
+		"crossfader 0.5": "crossfader",
+		"rate -1.25":     "rate",
+		"CC 2 127":       "CC 2",
+		"next clip":      "",
+		"clip 1a":        "",
+		"42":             "",
+		"zoom -":         "",
+	} {
+		if got := coalesceKey(text); got != want {
+			t.Errorf("coalesceKey(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestConcurrentSend(t *testing.T) {
+	h, err := NewHud(DefaultConfig(), nopBackend{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range 4 {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range 100 {
+				h.SendText(fmt.Sprintf("sender %d %d", i, j))
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for range 100 {
+				h.Frame()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n := len(h.Frame().Messages); n == 0 || n > h.Config().MaxLines {
+		t.Errorf("got %d lines, want 1 to %d", n, h.Config().MaxLines)
+	}
+}
+
+type nopBackend struct{}
+
+func (nopBackend) Run(*Hud) {}
@@ -0,0 +1,147 @@
+package hud
+
+var colorPreviewBg = Color{R: 0.0, G: 0.0, B: 0.0, A: 1.0}
+
+// PreviewLayout places a frame on a small preview screen, e.g. an LCD on the rack.
+// The preview's title and thumbnail take the left two thirds, all meters and lamps the rest.
+// The newest message and the status bar go along the bottom edge, an open menu on top. Lists aren't shown.
+// Sizes are derived from the screen height rather than the configured font size.
+func PreviewLayout(cfg Config, frame Frame, width, height int, measure Measure) DisplayList {
+	var d DisplayList
+
+	// menus are modal, and text of the widgets below would show through
+	if frame.Menu != nil {
+		frame.Widgets = nil
+	}
+
+	unit := max(height/12, 8)
+	margin := unit / 3
+	scale := float32(unit) / float32(cfg.FontSize)
+	small := scale * 0.75
+
+	d.rect(0, 0, width, height, colorPreviewBg)
+
+	var (
+		preview Preview
+		meters  []Meter
+		lamps   []Lamp
+	)
+
+	for _, w := range frame.Widgets {
+		switch w := w.(type) {
+		case Preview:
+			preview = w
+		case Meter:
+			meters = append(meters, w)
+		case Lamp:
+			lamps = append(lamps, w)
+		}
+	}
+
+	leftW := width*2/3 - 2*margin
+
+	// title and thumbnail
+	title := preview.Title
+	if title == "" {
+		title = "no source"
+	}
+
+	d.text(margin, unit, scale, colorText, fit(measure, scale, title, leftW))
+
+	boxY := unit + margin
+	boxH := height - boxY - 2*unit - margin
+
+	d.rect(margin, boxY, leftW, boxH, colorMeterBg)
+
+	if preview.Image != nil {
+		b := preview.Image.Bounds()
+
+		if b.Dx() > 0 && b.Dy() > 0 {
+			// keep the aspect ratio, centered in the box
+			w, h := leftW, b.Dy()*leftW/b.Dx()
+			if h > boxH {
+				w, h = b.Dx()*boxH/b.Dy(), boxH
+			}
+
+			d.Pictures = append(d.Pictures, Picture{
+				X:     margin + (leftW-w)/2,
+				Y:     boxY + (boxH-h)/2,
+				W:     w,
+				H:     h,
+				Image: preview.Image,
+			})
+		}
+	}
+
+	// meters and lamps
+	rightX := width * 2 / 3
+	rightW := width - rightX - margin
+	y := margin
+
+	if len(meters) > 0 {
+		colW := rightW / max(len(meters), 4)
+		barH := height / 2
+
+		for i, m := range meters {
+			x := rightX + i*colW
+			fill := int(float32(barH) * m.Value)
+
+			d.rect(x, y, colW*2/3, barH, colorMeterBg)
+			d.rect(x, y+barH-fill, colW*2/3, fill, colorMeter)
+			d.text(x, y+barH+unit, small, colorText, fit(measure, small, m.Label, colW-2))
+		}
+
+		y += barH + unit + margin
+	}
+
+	if len(lamps) > 0 {
+		const perRow = 4
+
+		colW := rightW / perRow
+		size := min(unit/2, colW/2)
+
+		for i, l := range lamps {
+			x := rightX + (i%perRow)*colW
+			ly := y + (i/perRow)*size*2
+
+			c := colorLampOff
+			if l.On {
+				c = colorLampOn
+			}
+
+			d.rect(x, ly, size, size, c)
+		}
+	}
+
+	// newest message and status
+	if n := len(frame.Messages); n > 0 {
+		m := frame.Messages[n-1]
+
+		d.text(margin, height-unit-margin, small, cfg.Style(m.Severity).Color, fit(measure, small, m.String(), width-2*margin))
+	}
+
+	if frame.Status != "" {
+		d.text(margin, height-margin, small, colorText, fit(measure, small, frame.Status, width-2*margin))
+	}
+
+	if frame.Menu != nil {
+		layoutMenu(&d, cfg, *frame.Menu, width, height, scale, measure)
+	}
+
+	return d
+}
+
+// fit shortens text to fit into width pixels.
+func fit(measure Measure, scale float32, text string, width int) string {
+	if measure(scale, text) <= width {
+		return text
+	}
+
+	r := []rune(text)
+
+	for len(r) > 0 && measure(scale, string(r)+"…") > width {
+		r = r[:len(r)-1]
+	}
+
+	return string(r) + "…"
+}
@@ -0,0 +1,207 @@
+// Package raster draws the HUD in software, without a display or GPU.
+package raster
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang/freetype/truetype"
+	"go.uber.org/zap"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/markus-wa/vlc-sampler/features/atomicfile"
+	"github.com/markus-wa/vlc-sampler/features/hud"
+)
+
+const refreshInterval = 100 * time.Millisecond
+
+// Renderer rasterises frames into images, using the same layout as the other backends.
+type Renderer struct {
+	cfg    hud.Config
+	width  int
+	height int
+	font   *truetype.Font
+	faces  map[float32]font.Face
+}
+
+func NewRenderer(cfg hud.Config, width, height int) (*Renderer, error) {
+	b, err := cfg.Font()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %w", err)
+	}
+
+	f, err := truetype.Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+
+	return &Renderer{
+		cfg:    cfg,
+		width:  width,
+		height: height,
+		font:   f,
+		faces:  map[float32]font.Face{},
+	}, nil
+}
+
+// Render draws a frame onto a new, transparent image.
+func (r *Renderer) Render(frame hud.Frame) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, r.width, r.height))
+
+	r.Draw(img, frame)
+
+	return img
+}
+
+// Draw draws a frame onto img, which should be r's size.
+func (r *Renderer) Draw(img draw.Image, frame hud.Frame) {
+	r.DrawList(img, hud.Layout(r.cfg, frame, r.width, r.height, r.Measure))
+}
+
+// DrawList draws a laid out frame onto img.
+// The rectangles replace what is below them, pictures and text are blended on top.
+func (r *Renderer) DrawList(img draw.Image, d hud.DisplayList) {
+	for _, rect := range d.Rects {
+		if rect.W <= 0 || rect.H <= 0 {
+			continue
+		}
+
+		bounds := image.Rect(rect.X, rect.Y, rect.X+rect.W, rect.Y+rect.H)
+
+		draw.Draw(img, bounds, image.NewUniform(rect.Color.NRGBA()), image.Point{}, draw.Src)
+	}
+
+	for _, p := range d.Pictures {
+		bounds := image.Rect(p.X, p.Y, p.X+p.W, p.Y+p.H)
+
+		xdraw.ApproxBiLinear.Scale(img, bounds, p.Image, p.Image.Bounds(), draw.Over, nil)
+	}
+
+	for _, t := range d.Texts {
+		drawer := font.Drawer{
+			Dst:  img,
+			Src:  image.NewUniform(t.Color.NRGBA()),
+			Face: r.face(t.Scale),
+			Dot:  fixed.P(t.X, t.Y),
+		}
+
+		drawer.DrawString(t.Text)
+	}
+}
+
+// Measure returns the width of a text in pixels at the given scale.
+func (r *Renderer) Measure(scale float32, text string) int {
+	return font.MeasureString(r.face(scale), text).Round()
+}
+
+func (r *Renderer) face(scale float32) font.Face {
+	f, ok := r.faces[scale]
+	if !ok {
+		f = truetype.NewFace(r.font, &truetype.Options{
+			Size:    float64(float32(r.cfg.FontSize) * scale),
+			Hinting: font.HintingFull,
+		})
+
+		r.faces[scale] = f
+	}
+
+	return f
+}
+
+// Headless is a HUD backend rendering into an offscreen image.
+// It is meant for tests and remote debugging, optionally dumping every changed frame to a PNG file.
+type Headless struct {
+	renderer *Renderer
+	dumpPath string
+	stop     chan struct{}
+
+	mu  sync.Mutex
+	img *image.RGBA
+}
+
+// NewHeadless creates a headless backend. If dumpPath is empty no PNG is written.
+func NewHeadless(cfg hud.Config, width, height int, dumpPath string) (*Headless, error) {
+	r, err := NewRenderer(cfg, width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Headless{
+		renderer: r,
+		dumpPath: dumpPath,
+		stop:     make(chan struct{}),
+		img:      image.NewRGBA(image.Rect(0, 0, width, height)),
+	}, nil
+}
+
+func (b *Headless) Run(h *hud.Hud) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	var last *hud.Frame
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+
+		frame := h.Frame()
+
+		if last != nil && reflect.DeepEqual(frame, *last) {
+			continue
+		}
+
+		last = &frame
+
+		img := b.renderer.Render(frame)
+
+		b.mu.Lock()
+		b.img = img
+		b.mu.Unlock()
+
+		if b.dumpPath != "" {
+			err := b.dump()
+			if err != nil {
+				zap.S().Errorw("failed to dump HUD", "error", err)
+			}
+		}
+	}
+}
+
+// Close stops Run.
+func (b *Headless) Close() {
+	close(b.stop)
+}
+
+// Image returns the last rendered frame.
+func (b *Headless) Image() *image.RGBA {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.img
+}
+
+// WritePNG encodes the last rendered frame as PNG.
+func (b *Headless) WritePNG(w io.Writer) error {
+	err := png.Encode(w, b.Image())
+	if err != nil {
+		return fmt.Errorf("failed to encode HUD image: %w", err)
+	}
+
+	return nil
+}
+
+// dump replaces the PNG atomically, so readers never see a partial file.
+func (b *Headless) dump() error {
+	return atomicfile.Write(b.dumpPath, b.WritePNG)
+}
@@ -0,0 +1,257 @@
+package raster
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/markus-wa/vlc-sampler/features/hud"
+	"github.com/markus-wa/vlc-sampler/features/menu"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+const (
+	testWidth  = 640
+	testHeight = 360
+)
+
+func testConfig() hud.Config {
+	cfg := hud.DefaultConfig()
+	cfg.FontSize = 24
+	cfg.MarginX = 20
+	cfg.MarginY = 20
+
+	return cfg
+}
+
+func widgetFrame() hud.Frame {
+	return hud.Frame{
+		Widgets: []hud.Widget{
+			hud.Meter{Label: "Volume", Value: 0.75},
+			hud.Meter{Label: "CC0", Value: 0.25, Orientation: hud.Vertical},
+			hud.Meter{Label: "CC1", Value: 1, Orientation: hud.Vertical},
+			hud.Lamp{Label: "G4", On: true},
+			hud.Lamp{Label: "G5"},
+			hud.List{Title: "Playlist", Items: []string{"intro.mp4", "loop.mp4", "outro.mp4"}, Current: 1},
+		},
+		Status: "MIDI Midi Through | mode step | step size 8",
+	}
+}
+
+func TestGolden(t *testing.T) {
+	bottomRight := testConfig()
+	bottomRight.Anchor = hud.AnchorBottomRight
+
+	center := testConfig()
+	center.Anchor = hud.AnchorCenter
+
+	messages := hud.Frame{
+		Messages: []hud.Message{
+			{Text: "clip: loop.mp4", Severity: hud.SeverityInfo},
+			{Text: "no MIDI port", Severity: hud.SeverityWarning, Count: 3},
+			{Text: "recording failed", Severity: hud.SeverityError},
+		},
+	}
+
+	all := widgetFrame()
+	all.Messages = messages.Messages
+
+	thumb := image.NewRGBA(image.Rect(0, 0, 64, 36))
+	draw.Draw(thumb, image.Rect(0, 0, 32, 36), image.NewUniform(color.RGBA{R: 200, A: 255}), image.Point{}, draw.Src)
+	draw.Draw(thumb, image.Rect(32, 0, 64, 36), image.NewUniform(color.RGBA{B: 200, A: 255}), image.Point{}, draw.Src)
+
+	preview := widgetFrame()
+	preview.Messages = messages.Messages
+	preview.Widgets = append(preview.Widgets, hud.Preview{Title: "a very long clip name that doesn't fit.mp4", Image: thumb})
+
+	withMenu := widgetFrame()
+	withMenu.Menu = &menu.View{Title: "Delete 1700000000.mp4?", Items: []string{"No", "Yes"}, Current: 1}
+
+	var ports []string
+	for i := range 30 {
+		ports = append(ports, fmt.Sprintf("MIDI port %d", i))
+	}
+
+	longMenu := hud.Frame{Menu: &menu.View{Title: "MIDI port", Items: ports, Current: 20}}
+
+	longLists := hud.Frame{
+		Widgets: []hud.Widget{
+			hud.List{Title: "Playlists", Items: ports, Current: 25},
+			hud.List{Title: "MIDI port 25", Items: ports, Current: 3},
+		},
+		Status: "mode playlists",
+	}
+
+	tests := []struct {
+		name   string
+		cfg    hud.Config
+		frame  hud.Frame
+		layout func(hud.Config, hud.Frame, int, int, hud.Measure) hud.DisplayList
+	}{
+		{"empty", testConfig(), hud.Frame{}, hud.Layout},
+		{"messages", testConfig(), messages, hud.Layout},
+		{"messages-bottom-right", bottomRight, messages, hud.Layout},
+		{"messages-center", center, messages, hud.Layout},
+		{"widgets", testConfig(), widgetFrame(), hud.Layout},
+		{"all", testConfig(), all, hud.Layout},
+		{"preview", testConfig(), preview, hud.PreviewLayout},
+		{"menu", testConfig(), withMenu, hud.Layout},
+		{"menu-long", testConfig(), longMenu, hud.Layout},
+		{"lists-long", testConfig(), longLists, hud.Layout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRenderer(tt.cfg, testWidth, testHeight)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img := image.NewRGBA(image.Rect(0, 0, testWidth, testHeight))
+
+			r.DrawList(img, tt.layout(tt.cfg, tt.frame, testWidth, testHeight, r.Measure))
+
+			compareGolden(t, img, filepath.Join("testdata", tt.name+".png"))
+		})
+	}
+}
+
+func compareGolden(t *testing.T, img *image.RGBA, path string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	err := png.Encode(&buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		err = os.WriteFile(path, buf.Bytes(), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open golden image, run with -update to create it: %v", err)
+	}
+
+	defer f.Close()
+
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// PNG isn't premultiplied, so compare what survives the round trip
+	got, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want.Bounds() != got.Bounds() {
+		t.Fatalf("size is %v, want %v", got.Bounds(), want.Bounds())
+	}
+
+	diff := 0
+
+	for y := got.Bounds().Min.Y; y < got.Bounds().Max.Y; y++ {
+		for x := got.Bounds().Min.X; x < got.Bounds().Max.X; x++ {
+			r1, g1, b1, a1 := got.At(x, y).RGBA()
+			r2, g2, b2, a2 := want.At(x, y).RGBA()
+
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				diff++
+			}
+		}
+	}
+
+	if diff > 0 {
+		actual := filepath.Join(t.TempDir(), filepath.Base(path))
+
+		_ = os.WriteFile(actual, buf.Bytes(), 0o644)
+
+		t.Errorf("%d pixels differ from %s, got %s", diff, path, actual)
+	}
+}
+
+func TestHeadlessDump(t *testing.T) {
+	dump := filepath.Join(t.TempDir(), "hud.png")
+
+	b, err := NewHeadless(testConfig(), testWidth, testHeight, dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := hud.NewHud(testConfig(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.SetStatus("status")
+
+	done := make(chan struct{})
+
+	go func() {
+		h.Start()
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		_, err := os.Stat(dump)
+		if err == nil {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("no dump written")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	b.Close()
+	<-done
+
+	f, err := os.Open(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds() != image.Rect(0, 0, testWidth, testHeight) {
+		t.Errorf("dump is %v", img.Bounds())
+	}
+
+	var buf bytes.Buffer
+
+	err = b.WritePNG(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() == 0 {
+		t.Error("empty PNG")
+	}
+}
@@ -0,0 +1,422 @@
+package vlchud
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	vlc "github.com/adrg/libvlc-go/v3"
+	"go.uber.org/zap"
+
+	"github.com/markus-wa/vlc-sampler/features/hud"
+	"github.com/markus-wa/vlc-sampler/features/hud/raster"
+)
+
+const refreshInterval = 100 * time.Millisecond
+
+// Overlay is a HUD backend drawing into the video output of libvlc players,
+// using the marquee sub-filter for text and the logo sub-filter for meters and lamps.
+// Unlike a separate window it doesn't depend on compositor support.
+type Overlay struct {
+	players []*vlc.Player
+	dir     string
+	stop    chan struct{}
+
+	// mu is held while drawing into the players, so Close can wait for it
+	mu     sync.Mutex
+	closed bool
+}
+
+// New draws into all players, e.g. the sampler's decks, so the HUD stays on whichever is on air.
+func New(players ...*vlc.Player) (*Overlay, error) {
+	dir, err := os.MkdirTemp("", "vlchud")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory for logo images: %w", err)
+	}
+
+	return &Overlay{
+		players: players,
+		dir:     dir,
+		stop:    make(chan struct{}),
+	}, nil
+}
+
+// Run draws the HUD until Close is called. If the players can't be set up it logs the error
+// and still blocks, so the video keeps playing without the overlay.
+func (o *Overlay) Run(h *hud.Hud) {
+	cfg := h.Config()
+
+	r, err := raster.NewRenderer(cfg, canvasWidth, canvasHeight)
+	if err == nil {
+		err = o.draw(func() error {
+			return o.setup(cfg)
+		})
+	}
+
+	if errors.Is(err, errClosed) {
+		return
+	}
+
+	if err != nil {
+		zap.S().Errorw("failed to set up video overlay", "error", err)
+		h.SendError(fmt.Sprintf("video overlay failed: %v", err))
+
+		<-o.stop
+
+		return
+	}
+
+	var (
+		lastText    string
+		lastSev     = hud.Severity(-1)
+		lastWidgets []hud.Widget
+		logoIdx     int
+	)
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.stop:
+			return
+		case <-ticker.C:
+		}
+
+		frame := h.Frame()
+
+		text, sev := overlayText(frame)
+
+		if text != lastText || sev != lastSev {
+			err := o.draw(func() error {
+				return o.setText(cfg, text, sev)
+			})
+			if errors.Is(err, errClosed) {
+				return
+			}
+
+			if err != nil {
+				zap.S().Errorw("failed to update marquee", "error", err)
+			}
+
+			lastText, lastSev = text, sev
+		}
+
+		widgets := graphicWidgets(frame.Widgets)
+
+		if !reflect.DeepEqual(widgets, lastWidgets) {
+			// libvlc only reloads the logo if the file name changes
+			logoIdx = 1 - logoIdx
+
+			err := o.draw(func() error {
+				return o.setLogo(r, cfg, widgets, path.Join(o.dir, fmt.Sprintf("widgets-%d.png", logoIdx)))
+			})
+			if errors.Is(err, errClosed) {
+				return
+			}
+
+			if err != nil {
+				zap.S().Errorw("failed to update logo", "error", err)
+			}
+
+			lastWidgets = widgets
+		}
+	}
+}
+
+var errClosed = errors.New("overlay closed")
+
+// draw calls f unless the overlay is closed, Close waits for it to return.
+func (o *Overlay) draw(f func() error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return errClosed
+	}
+
+	return f()
+}
+
+// Close stops Run and removes the logo images. The players can be released once it returns.
+func (o *Overlay) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return nil
+	}
+
+	o.closed = true
+	close(o.stop)
+
+	err := os.RemoveAll(o.dir)
+	if err != nil {
+		return fmt.Errorf("failed to remove logo images: %w", err)
+	}
+
+	return nil
+}
+
+func (o *Overlay) setup(cfg hud.Config) error {
+	for _, p := range o.players {
+		err := setup(p, cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setup(p *vlc.Player, cfg hud.Config) error {
+	m := p.Marquee()
+
+	err := m.SetPosition(position(cfg.Anchor))
+	if err != nil {
+		return fmt.Errorf("failed to set marquee position: %w", err)
+	}
+
+	err = m.SetX(cfg.MarginX)
+	if err != nil {
+		return fmt.Errorf("failed to set marquee x: %w", err)
+	}
+
+	err = m.SetY(cfg.MarginY)
+	if err != nil {
+		return fmt.Errorf("failed to set marquee y: %w", err)
+	}
+
+	err = m.SetSize(cfg.FontSize)
+	if err != nil {
+		return fmt.Errorf("failed to set marquee size: %w", err)
+	}
+
+	err = m.SetRefreshInterval(refreshInterval)
+	if err != nil {
+		return fmt.Errorf("failed to set marquee refresh interval: %w", err)
+	}
+
+	err = m.Enable(true)
+	if err != nil {
+		return fmt.Errorf("failed to enable marquee: %w", err)
+	}
+
+	l := p.Logo()
+
+	// meters and lamps go opposite the messages, like in the window HUD
+	err = l.SetPosition(vlc.PositionTopRight)
+	if err != nil {
+		return fmt.Errorf("failed to set logo position: %w", err)
+	}
+
+	err = l.SetX(cfg.MarginX)
+	if err != nil {
+		return fmt.Errorf("failed to set logo x: %w", err)
+	}
+
+	err = l.SetY(cfg.MarginY)
+	if err != nil {
+		return fmt.Errorf("failed to set logo y: %w", err)
+	}
+
+	err = l.Enable(true)
+	if err != nil {
+		return fmt.Errorf("failed to enable logo: %w", err)
+	}
+
+	return nil
+}
+
+func (o *Overlay) setText(cfg hud.Config, text string, sev hud.Severity) error {
+	for _, p := range o.players {
+		err := setText(p, cfg, text, sev)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setText(p *vlc.Player, cfg hud.Config, text string, sev hud.Severity) error {
+	m := p.Marquee()
+
+	c := cfg.Style(sev).Color.NRGBA()
+
+	err := m.SetColor(color.NRGBA{R: c.R, G: c.G, B: c.B, A: 255})
+	if err != nil {
+		return fmt.Errorf("failed to set marquee color: %w", err)
+	}
+
+	err = m.SetOpacity(int(c.A))
+	if err != nil {
+		return fmt.Errorf("failed to set marquee opacity: %w", err)
+	}
+
+	// the marquee expands strftime sequences
+	err = m.SetText(strings.ReplaceAll(text, "%", "%%"))
+	if err != nil {
+		return fmt.Errorf("failed to set marquee text: %w", err)
+	}
+
+	return nil
+}
+
+func (o *Overlay) setLogo(r *raster.Renderer, cfg hud.Config, widgets []hud.Widget, file string) error {
+	img := renderWidgets(r, cfg, widgets)
+
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("failed to create logo image: %w", err)
+	}
+
+	err = png.Encode(f, img)
+	if err != nil {
+		f.Close()
+
+		return fmt.Errorf("failed to encode logo image: %w", err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write logo image: %w", err)
+	}
+
+	logo, err := vlc.NewLogoFileFromPath(file, -1, -1)
+	if err != nil {
+		return fmt.Errorf("failed to create logo file: %w", err)
+	}
+
+	for _, p := range o.players {
+		err = p.Logo().SetFiles(logo)
+		if err != nil {
+			return fmt.Errorf("failed to set logo file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func position(a hud.Anchor) vlc.Position {
+	switch a {
+	case hud.AnchorTopRight:
+		return vlc.PositionTopRight
+	case hud.AnchorBottomLeft:
+		return vlc.PositionBottomLeft
+	case hud.AnchorBottomRight:
+		return vlc.PositionBottomRight
+	case hud.AnchorCenter:
+		return vlc.PositionCenter
+	default:
+		return vlc.PositionTopLeft
+	}
+}
+
+// overlayText renders messages, lists, the open menu and the status bar as lines of text,
+// along with the highest severity among the messages which decides the color.
+func overlayText(frame hud.Frame) (string, hud.Severity) {
+	var (
+		lines []string
+		sev   hud.Severity
+	)
+
+	for _, m := range frame.Messages {
+		lines = append(lines, m.String())
+		sev = max(sev, m.Severity)
+	}
+
+	for _, w := range frame.Widgets {
+		l, ok := w.(hud.List)
+		if !ok {
+			continue
+		}
+
+		lines = append(lines, l.Title)
+
+		for i, item := range l.Items {
+			prefix := "  "
+			if i == l.Current {
+				prefix = "> "
+			}
+
+			lines = append(lines, prefix+item)
+		}
+	}
+
+	if frame.Menu != nil {
+		lines = append(lines, "", "[ "+frame.Menu.Title+" ]")
+
+		for i, item := range frame.Menu.Items {
+			prefix := "  "
+			if i == frame.Menu.Current {
+				prefix = "> "
+			}
+
+			lines = append(lines, prefix+item)
+		}
+
+		lines = append(lines, "")
+	}
+
+	if frame.Status != "" {
+		lines = append(lines, frame.Status)
+	}
+
+	return strings.Join(lines, "\n"), sev
+}
+
+func graphicWidgets(ws []hud.Widget) []hud.Widget {
+	var res []hud.Widget
+
+	for _, w := range ws {
+		switch w.(type) {
+		case hud.Meter, hud.Lamp:
+			res = append(res, w)
+		}
+	}
+
+	return res
+}
+
+// The widgets are laid out on a canvas of this size, like on a screen, and cropped to their panel.
+const (
+	canvasWidth  = 1280
+	canvasHeight = 720
+)
+
+// renderWidgets draws meters and lamps the way the other backends do and crops them to what was drawn.
+func renderWidgets(r *raster.Renderer, cfg hud.Config, ws []hud.Widget) image.Image {
+	d := hud.Layout(cfg, hud.Frame{Widgets: ws}, canvasWidth, canvasHeight, r.Measure)
+
+	var bounds image.Rectangle
+
+	for _, rect := range d.Rects {
+		bounds = bounds.Union(image.Rect(rect.X, rect.Y, rect.X+rect.W, rect.Y+rect.H))
+	}
+
+	for _, t := range d.Texts {
+		size := int(float32(cfg.FontSize) * t.Scale)
+
+		bounds = bounds.Union(image.Rect(t.X, t.Y-size, t.X+r.Measure(t.Scale, t.Text), t.Y+size/2))
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, canvasWidth, canvasHeight))
+
+	r.DrawList(img, d)
+
+	bounds = bounds.Intersect(img.Bounds())
+	if bounds.Empty() {
+		return image.NewRGBA(image.Rect(0, 0, 1, 1))
+	}
+
+	return img.SubImage(bounds)
+}
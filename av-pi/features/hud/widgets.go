@@ -0,0 +1,151 @@
+package hud
+
+import (
+	"image"
+	"slices"
+)
+
+type Orientation int
+
+const (
+	Horizontal Orientation = iota
+	Vertical
+)
+
+// Widget is a Meter, Lamp, List or Preview.
+type Widget interface {
+	widget()
+}
+
+// Meter is a bar showing a value between 0 and 1, e.g. a MIDI CC.
+type Meter struct {
+	Label       string
+	Value       float32
+	Orientation Orientation
+}
+
+// Lamp is an on/off indicator, e.g. a gate.
+type Lamp struct {
+	Label string
+	On    bool
+}
+
+// List is a list of items with the current one highlighted, e.g. a playlist.
+type List struct {
+	Title   string
+	Items   []string
+	Current int
+}
+
+// Preview is the active source with its name and a thumbnail.
+// Only preview panels show it, the main output already shows the source itself.
+type Preview struct {
+	Title string
+	Image image.Image
+}
+
+func (Meter) widget()   {}
+func (Lamp) widget()    {}
+func (List) widget()    {}
+func (Preview) widget() {}
+
+// widgets holds the persistent named widgets in the order they were first set.
+type widgets struct {
+	order  []string
+	byID   map[string]Widget
+	status string
+}
+
+func newWidgets() widgets {
+	return widgets{
+		byID: map[string]Widget{},
+	}
+}
+
+func (w *widgets) set(id string, widget Widget) {
+	if _, ok := w.byID[id]; !ok {
+		w.order = append(w.order, id)
+	}
+
+	w.byID[id] = widget
+}
+
+func (w *widgets) remove(id string) {
+	delete(w.byID, id)
+
+	w.order = slices.DeleteFunc(w.order, func(o string) bool {
+		return o == id
+	})
+}
+
+func (w *widgets) snapshot() []Widget {
+	ws := make([]Widget, len(w.order))
+
+	for i, id := range w.order {
+		ws[i] = w.byID[id]
+	}
+
+	return ws
+}
+
+// SetMeter creates or updates the meter with the given id.
+func (h *Hud) SetMeter(id string, m Meter) {
+	m.Value = clamp01(m.Value)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.widgets.set(id, m)
+}
+
+// SetLamp creates or updates the lamp with the given id.
+func (h *Hud) SetLamp(id string, l Lamp) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.widgets.set(id, l)
+}
+
+// SetList creates or updates the list with the given id.
+func (h *Hud) SetList(id string, l List) {
+	l.Items = slices.Clone(l.Items)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.widgets.set(id, l)
+}
+
+// SetPreview creates or updates the preview with the given id. The image must not be modified afterwards.
+func (h *Hud) SetPreview(id string, p Preview) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.widgets.set(id, p)
+}
+
+// SetStatus sets the text of the status bar. An empty text hides it.
+func (h *Hud) SetStatus(text string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.widgets.status = text
+}
+
+// RemoveWidget removes the widget with the given id.
+func (h *Hud) RemoveWidget(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.widgets.remove(id)
+}
+
+func clamp01(v float32) float32 {
+	if v < 0 {
+		return 0
+	} else if v > 1 {
+		return 1
+	}
+
+	return v
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/kenshaw/evdev"
@@ -70,7 +71,9 @@ func listDevices() ([]Device, error) {
 	return devices, nil
 }
 
-func PollDefault(ctx context.Context) (*evdev.Evdev, error) {
+// PollDefault opens the default gamepad, preferring combined JoyCons. Gamepads named in exclude are skipped,
+// e.g. the ones opened with PollNamed.
+func PollDefault(ctx context.Context, exclude ...string) (*evdev.Evdev, error) {
 	devs, err := listDevices()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list devices: %w", err)
@@ -79,6 +82,10 @@ func PollDefault(ctx context.Context) (*evdev.Evdev, error) {
 	var dev *Device
 
 	for _, d := range devs {
+		if slices.Contains(exclude, d.Name) {
+			continue
+		}
+
 		if d.IsGamepad && dev == nil || (dev != nil && !dev.IsCombinedJoyCon() && d.IsCombinedJoyCon()) {
 			dev = &d // either the first gamepad or the first combined joycon
 		}
@@ -95,3 +102,25 @@ func PollDefault(ctx context.Context) (*evdev.Evdev, error) {
 
 	return device, nil
 }
+
+// PollNamed opens the gamepad with the given name.
+func PollNamed(ctx context.Context, name string) (*evdev.Evdev, error) {
+	devs, err := listDevices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	i := slices.IndexFunc(devs, func(d Device) bool {
+		return d.Name == name
+	})
+	if i < 0 {
+		return nil, fmt.Errorf("no gamepad named %q found", name)
+	}
+
+	device, err := evdev.OpenFile(devs[i].Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open device: %w", err)
+	}
+
+	return device, nil
+}
@@ -0,0 +1,37 @@
+package input
+
+import (
+	"github.com/kenshaw/evdev"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+)
+
+// D-pad directions of JoyCons (keys) and Switch Pro controllers (hat).
+var (
+	DpadUp    = []Button{Btn(evdev.KeyType(544)), Hat(evdev.AbsoluteHat0Y, -1)}
+	DpadDown  = []Button{Btn(evdev.KeyType(545)), Hat(evdev.AbsoluteHat0Y, 1)}
+	DpadLeft  = []Button{Btn(evdev.KeyType(546)), Hat(evdev.AbsoluteHat0X, -1)}
+	DpadRight = []Button{Btn(evdev.KeyType(547)), Hat(evdev.AbsoluteHat0X, 1)}
+)
+
+// Bind binds g on each of the buttons to a, e.g. on both variants of a D-pad direction.
+func Bind(g Gesture, a bus.Action, buttons ...Button) []Binding {
+	var bindings []Binding
+
+	for _, b := range buttons {
+		bindings = append(bindings, Binding{Buttons: []Button{b}, Gesture: g, Action: a})
+	}
+
+	return bindings
+}
+
+// Bindings concatenates groups of bindings, for writing layouts.
+func Bindings(groups ...[]Binding) []Binding {
+	var all []Binding
+
+	for _, g := range groups {
+		all = append(all, g...)
+	}
+
+	return all
+}
@@ -0,0 +1,445 @@
+package input
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/kenshaw/evdev"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+)
+
+const (
+	DefaultLongPress = 500 * time.Millisecond
+	DefaultDoubleTap = 250 * time.Millisecond
+
+	// TickInterval is how often Mapper.Tick should be called to detect long presses and single taps in time.
+	TickInterval = 20 * time.Millisecond
+)
+
+// Button is a gamepad button, or one direction of a hat.
+type Button struct {
+	Type any
+	Dir  int32 // -1 or 1 for hats, 0 otherwise
+}
+
+func Btn(typ any) Button {
+	return Button{Type: typ}
+}
+
+func Hat(typ any, dir int32) Button {
+	return Button{Type: typ, Dir: dir}
+}
+
+func isHat(typ any) bool {
+	return typ == evdev.AbsoluteHat0X || typ == evdev.AbsoluteHat0Y
+}
+
+type Gesture int
+
+const (
+	// Press is a short press. It fires when the button goes down, unless the button also has a LongPress
+	// or DoubleTap binding or is part of a chord; then it fires once it's clear the press was short and single.
+	Press Gesture = iota
+	// LongPress fires once the button has been held for Config.LongPress.
+	LongPress
+	// DoubleTap fires when the button goes down a second time within Config.DoubleTap of being released.
+	DoubleTap
+	// Down and Up fire immediately when the button goes down or up, e.g. for momentary gates.
+	Down
+	Up
+)
+
+// Binding binds a gesture to an action. A binding with several buttons is a chord,
+// which fires when the last of its buttons goes down while the others are held; its gesture is ignored.
+type Binding struct {
+	Buttons []Button
+	Gesture Gesture
+	Action  bus.Action
+}
+
+// Layer is a set of bindings. The first layer of a Config is the base layer and always active,
+// the others are active while one of their modifiers is held, or toggled by them.
+// Active layers take precedence over the ones before them; buttons they don't bind fall through.
+type Layer struct {
+	Name      string
+	Modifiers []Button
+	Toggle    bool
+	// OnEnter and OnExit, if set, are published when the layer becomes active or inactive.
+	OnEnter  bus.Action
+	OnExit   bus.Action
+	Bindings []Binding
+}
+
+type Config struct {
+	Layers []Layer
+	// Axes maps analog axes, e.g. sticks, to actions.
+	Axes map[any]func(value int32) bus.Action
+
+	LongPress time.Duration // DefaultLongPress if zero
+	DoubleTap time.Duration // DefaultDoubleTap if zero
+}
+
+// Mapper turns gamepad events into actions according to a Config. It is not safe for concurrent use.
+// Modifiers are buttons like any other: they can have bindings, which are looked up before their layer is active.
+type Mapper struct {
+	cfg   Config
+	pub   bus.Publisher
+	clock func() time.Time
+
+	known  map[Button]bool
+	active []bool
+	held   map[Button]*press
+	taps   map[Button]*press
+	hats   map[any]int32
+	// moved are the axes whose last value wasn't 0, Reset centres them
+	moved map[any]bool
+}
+
+type press struct {
+	at     time.Time
+	layers []int // active layers when the button went down, topmost first
+
+	deferred bool // Press waits until the release
+	consumed bool // a chord, long press or double tap fired instead of Press
+}
+
+func NewMapper(cfg Config, pub bus.Publisher) *Mapper {
+	if cfg.LongPress == 0 {
+		cfg.LongPress = DefaultLongPress
+	}
+
+	if cfg.DoubleTap == 0 {
+		cfg.DoubleTap = DefaultDoubleTap
+	}
+
+	m := &Mapper{
+		cfg:    cfg,
+		pub:    pub,
+		clock:  time.Now,
+		known:  map[Button]bool{},
+		active: make([]bool, len(cfg.Layers)),
+		held:   map[Button]*press{},
+		taps:   map[Button]*press{},
+		hats:   map[any]int32{},
+		moved:  map[any]bool{},
+	}
+
+	if len(m.active) > 0 {
+		m.active[0] = true
+	}
+
+	for _, l := range cfg.Layers {
+		for _, b := range l.Modifiers {
+			m.known[b] = true
+		}
+
+		for _, bind := range l.Bindings {
+			for _, b := range bind.Buttons {
+				m.known[b] = true
+			}
+		}
+	}
+
+	return m
+}
+
+// HandleEvent publishes the actions the event completes. Events of unbound buttons and axes are ignored.
+func (m *Mapper) HandleEvent(event *evdev.EventEnvelope) error {
+	now := m.clock()
+
+	if f, ok := m.cfg.Axes[event.Type]; ok {
+		if event.Value != 0 {
+			m.moved[event.Type] = true
+		} else {
+			delete(m.moved, event.Type)
+		}
+
+		return m.publish(f(event.Value))
+	}
+
+	if isHat(event.Type) {
+		dir := int32(0)
+		if event.Value < 0 {
+			dir = -1
+		} else if event.Value > 0 {
+			dir = 1
+		}
+
+		prev := m.hats[event.Type]
+		if dir == prev {
+			return nil
+		}
+
+		m.hats[event.Type] = dir
+
+		var errs []error
+
+		if prev != 0 {
+			errs = append(errs, m.up(Hat(event.Type, prev)))
+		}
+
+		if dir != 0 {
+			errs = append(errs, m.down(Hat(event.Type, dir), now))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	b := Btn(event.Type)
+	if !m.known[b] {
+		return nil
+	}
+
+	_, isHeld := m.held[b]
+
+	if event.Value != 0 && !isHeld {
+		return m.down(b, now)
+	} else if event.Value == 0 && isHeld {
+		return m.up(b)
+	}
+
+	return nil // key repeat, or an analog trigger moving
+}
+
+// Tick fires long presses that have been held long enough and single taps that weren't followed by a second one.
+func (m *Mapper) Tick() error {
+	now := m.clock()
+
+	var errs []error
+
+	for b, p := range m.held {
+		if p.deferred && !p.consumed && now.Sub(p.at) >= m.cfg.LongPress {
+			if a := m.lookup(p.layers, b, LongPress); a != nil {
+				p.consumed = true
+
+				errs = append(errs, m.publish(a))
+			}
+		}
+	}
+
+	for b, t := range m.taps {
+		if now.Sub(t.at) > m.cfg.DoubleTap {
+			delete(m.taps, b)
+
+			errs = append(errs, m.publish(m.lookup(t.layers, b, Press)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Reset releases all held buttons, firing their Up bindings, centres the axes that are off centre, so what they
+// move stops, and forgets pending taps. Toggled layers stay active.
+func (m *Mapper) Reset() error {
+	var errs []error
+
+	for typ := range m.moved {
+		errs = append(errs, m.publish(m.cfg.Axes[typ](0)))
+	}
+
+	clear(m.moved)
+
+	for b := range m.held {
+		p := m.held[b]
+		p.consumed = true
+
+		errs = append(errs, m.up(b))
+	}
+
+	clear(m.taps)
+	clear(m.hats)
+
+	return errors.Join(errs...)
+}
+
+// Active returns the names of the active layers, topmost first.
+func (m *Mapper) Active() []string {
+	var names []string
+
+	for _, i := range m.activeLayers() {
+		names = append(names, m.cfg.Layers[i].Name)
+	}
+
+	return names
+}
+
+func (m *Mapper) down(b Button, now time.Time) error {
+	p := &press{
+		at:     now,
+		layers: m.activeLayers(),
+	}
+
+	m.held[b] = p
+
+	var errs []error
+
+	// a tap Tick hasn't seen expire yet
+	if t, ok := m.taps[b]; ok && now.Sub(t.at) > m.cfg.DoubleTap {
+		delete(m.taps, b)
+
+		errs = append(errs, m.publish(m.lookup(t.layers, b, Press)))
+	}
+
+	errs = append(errs, m.modifiers(b, true))
+
+	errs = append(errs, m.publish(m.lookup(p.layers, b, Down)))
+
+	if chord := m.chord(p.layers, b); chord != nil {
+		for _, cb := range chord.Buttons {
+			m.held[cb].consumed = true
+		}
+
+		errs = append(errs, m.publish(chord.Action))
+	} else if t, ok := m.taps[b]; ok && now.Sub(t.at) <= m.cfg.DoubleTap {
+		delete(m.taps, b)
+
+		p.consumed = true
+
+		errs = append(errs, m.publish(m.lookup(t.layers, b, DoubleTap)))
+	}
+
+	p.deferred = m.lookup(p.layers, b, LongPress) != nil || m.lookup(p.layers, b, DoubleTap) != nil || m.inChord(p.layers, b)
+
+	if !p.deferred && !p.consumed {
+		errs = append(errs, m.publish(m.lookup(p.layers, b, Press)))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *Mapper) up(b Button) error {
+	p, ok := m.held[b]
+	if !ok {
+		return nil
+	}
+
+	delete(m.held, b)
+
+	errs := []error{m.modifiers(b, false)}
+
+	errs = append(errs, m.publish(m.lookup(p.layers, b, Up)))
+
+	if p.deferred && !p.consumed {
+		if m.lookup(p.layers, b, DoubleTap) != nil {
+			p.at = m.clock()
+			m.taps[b] = p
+		} else {
+			errs = append(errs, m.publish(m.lookup(p.layers, b, Press)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// modifiers activates or deactivates the layers b is a modifier of.
+func (m *Mapper) modifiers(b Button, down bool) error {
+	var errs []error
+
+	for i, l := range m.cfg.Layers {
+		if i == 0 || !slices.Contains(l.Modifiers, b) {
+			continue
+		}
+
+		if l.Toggle {
+			if down {
+				errs = append(errs, m.setActive(i, !m.active[i]))
+			}
+
+			continue
+		}
+
+		held := slices.ContainsFunc(l.Modifiers, func(mod Button) bool {
+			_, ok := m.held[mod]
+
+			return ok
+		})
+
+		errs = append(errs, m.setActive(i, held))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *Mapper) setActive(i int, active bool) error {
+	if m.active[i] == active {
+		return nil
+	}
+
+	m.active[i] = active
+
+	if active {
+		return m.publish(m.cfg.Layers[i].OnEnter)
+	}
+
+	return m.publish(m.cfg.Layers[i].OnExit)
+}
+
+func (m *Mapper) activeLayers() []int {
+	var layers []int
+
+	for i := len(m.active) - 1; i >= 0; i-- {
+		if m.active[i] {
+			layers = append(layers, i)
+		}
+	}
+
+	return layers
+}
+
+// lookup returns the action bound to g on b alone, from the topmost layer binding it, or nil.
+func (m *Mapper) lookup(layers []int, b Button, g Gesture) bus.Action {
+	for _, i := range layers {
+		for _, bind := range m.cfg.Layers[i].Bindings {
+			if len(bind.Buttons) == 1 && bind.Buttons[0] == b && bind.Gesture == g {
+				return bind.Action
+			}
+		}
+	}
+
+	return nil
+}
+
+// chord returns the chord completed by b going down, or nil.
+func (m *Mapper) chord(layers []int, b Button) *Binding {
+	for _, i := range layers {
+		for j, bind := range m.cfg.Layers[i].Bindings {
+			if len(bind.Buttons) < 2 || !slices.Contains(bind.Buttons, b) {
+				continue
+			}
+
+			complete := !slices.ContainsFunc(bind.Buttons, func(cb Button) bool {
+				_, ok := m.held[cb]
+
+				return !ok
+			})
+
+			if complete {
+				return &m.cfg.Layers[i].Bindings[j]
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *Mapper) inChord(layers []int, b Button) bool {
+	for _, i := range layers {
+		for _, bind := range m.cfg.Layers[i].Bindings {
+			if len(bind.Buttons) > 1 && slices.Contains(bind.Buttons, b) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (m *Mapper) publish(a bus.Action) error {
+	if a == nil {
+		return nil
+	}
+
+	return m.pub.Publish(a)
+}
@@ -0,0 +1,308 @@
+package input
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kenshaw/evdev"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/internal/testutil"
+)
+
+type recorder []bus.Action
+
+func (r *recorder) Publish(a bus.Action) error {
+	*r = append(*r, a)
+
+	return nil
+}
+
+func ev(typ any, value int32) *evdev.EventEnvelope {
+	return &evdev.EventEnvelope{
+		Type:  typ,
+		Event: evdev.Event{Value: value},
+	}
+}
+
+type step struct {
+	event *evdev.EventEnvelope
+	wait  time.Duration // advanced before the event, or before a tick if event is nil
+}
+
+func down(typ any) step {
+	return step{event: ev(typ, 1)}
+}
+
+func up(typ any) step {
+	return step{event: ev(typ, 0)}
+}
+
+func wait(d time.Duration) step {
+	return step{wait: d}
+}
+
+func run(t *testing.T, cfg Config, steps ...step) []bus.Action {
+	t.Helper()
+
+	var got recorder
+
+	clock := testutil.NewClock(time.Unix(0, 0))
+
+	m := NewMapper(cfg, &got)
+	m.clock = clock.Now
+
+	for _, s := range steps {
+		clock.Advance(s.wait)
+
+		var err error
+
+		if s.event != nil {
+			err = m.HandleEvent(s.event)
+		} else {
+			err = m.Tick()
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return got
+}
+
+func check(t *testing.T, got []bus.Action, want ...bus.Action) {
+	t.Helper()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func bind(b Button, g Gesture, a bus.Action) Binding {
+	return Binding{Buttons: []Button{b}, Gesture: g, Action: a}
+}
+
+func TestPress(t *testing.T) {
+	cfg := Config{Layers: []Layer{{Bindings: []Binding{bind(Btn(evdev.BtnA), Press, bus.TogglePlay{})}}}}
+
+	check(t, run(t, cfg, down(evdev.BtnA)), bus.TogglePlay{})
+	check(t, run(t, cfg, down(evdev.BtnA), step{event: ev(evdev.BtnA, 2)}, up(evdev.BtnA)), bus.TogglePlay{})
+	check(t, run(t, cfg, down(evdev.BtnB), up(evdev.BtnB)))
+}
+
+func TestDownUp(t *testing.T) {
+	cfg := Config{Layers: []Layer{{Bindings: []Binding{
+		bind(Btn(evdev.BtnA), Down, bus.Gate{Channel: 5, On: true}),
+		bind(Btn(evdev.BtnA), Up, bus.Gate{Channel: 5}),
+	}}}}
+
+	check(t, run(t, cfg, down(evdev.BtnA), up(evdev.BtnA)), bus.Gate{Channel: 5, On: true}, bus.Gate{Channel: 5})
+}
+
+func TestLongPress(t *testing.T) {
+	cfg := Config{Layers: []Layer{{Bindings: []Binding{
+		bind(Btn(evdev.BtnX), Press, bus.ToggleRecord{}),
+		bind(Btn(evdev.BtnX), LongPress, bus.RecordingsMenu{}),
+	}}}}
+
+	check(t, run(t, cfg, down(evdev.BtnX), wait(100*time.Millisecond), up(evdev.BtnX)), bus.ToggleRecord{})
+	check(t, run(t, cfg, down(evdev.BtnX), wait(DefaultLongPress), wait(time.Second), up(evdev.BtnX)), bus.RecordingsMenu{})
+}
+
+func TestDoubleTap(t *testing.T) {
+	cfg := Config{Layers: []Layer{{Bindings: []Binding{
+		bind(Btn(evdev.BtnA), Press, bus.NextClip{}),
+		bind(Btn(evdev.BtnA), DoubleTap, bus.NextPlaylist{}),
+	}}}}
+
+	t.Run("double", func(t *testing.T) {
+		got := run(t, cfg, down(evdev.BtnA), up(evdev.BtnA), wait(100*time.Millisecond), down(evdev.BtnA), up(evdev.BtnA), wait(time.Second))
+		check(t, got, bus.NextPlaylist{})
+	})
+
+	t.Run("single", func(t *testing.T) {
+		got := run(t, cfg, down(evdev.BtnA), up(evdev.BtnA), wait(DefaultDoubleTap/2))
+		check(t, got)
+
+		got = run(t, cfg, down(evdev.BtnA), up(evdev.BtnA), wait(DefaultDoubleTap+time.Millisecond))
+		check(t, got, bus.NextClip{})
+	})
+
+	t.Run("two singles without tick", func(t *testing.T) {
+		got := run(t, cfg, down(evdev.BtnA), up(evdev.BtnA), step{event: ev(evdev.BtnA, 1), wait: time.Second})
+		check(t, got, bus.NextClip{})
+	})
+}
+
+func TestChord(t *testing.T) {
+	cfg := Config{Layers: []Layer{{Bindings: []Binding{
+		bind(Btn(evdev.BtnA), Press, bus.TogglePlay{}),
+		bind(Btn(evdev.BtnB), Press, bus.NextClip{}),
+		{Buttons: []Button{Btn(evdev.BtnA), Btn(evdev.BtnB)}, Action: bus.ToggleMode{}},
+	}}}}
+
+	check(t, run(t, cfg, down(evdev.BtnA), down(evdev.BtnB), up(evdev.BtnA), up(evdev.BtnB)), bus.ToggleMode{})
+	check(t, run(t, cfg, down(evdev.BtnB), down(evdev.BtnA), up(evdev.BtnB), up(evdev.BtnA)), bus.ToggleMode{})
+	check(t, run(t, cfg, down(evdev.BtnA), up(evdev.BtnA), down(evdev.BtnB), up(evdev.BtnB)), bus.TogglePlay{}, bus.NextClip{})
+}
+
+func TestHoldLayer(t *testing.T) {
+	cfg := Config{Layers: []Layer{
+		{Name: "base", Bindings: []Binding{
+			bind(Btn(evdev.BtnStart), Press, bus.NextClip{}),
+			bind(Btn(evdev.BtnA), Press, bus.TogglePlay{}),
+		}},
+		{
+			Name:      "playlist",
+			Modifiers: []Button{Btn(evdev.BtnZ), Btn(evdev.AbsoluteZ)},
+			OnEnter:   bus.PortMode{On: true},
+			OnExit:    bus.PortMode{},
+			Bindings:  []Binding{bind(Btn(evdev.BtnStart), Press, bus.NextPlaylist{})},
+		},
+	}}
+
+	got := run(t, cfg,
+		down(evdev.BtnZ), down(evdev.BtnStart), up(evdev.BtnStart),
+		down(evdev.BtnA), up(evdev.BtnA), // falls through to the base layer
+		step{event: ev(evdev.AbsoluteZ, 200)}, up(evdev.BtnZ), // still held by the other modifier
+		down(evdev.BtnStart), up(evdev.BtnStart),
+		up(evdev.AbsoluteZ),
+		down(evdev.BtnStart),
+	)
+
+	check(t, got, bus.PortMode{On: true}, bus.NextPlaylist{}, bus.TogglePlay{}, bus.NextPlaylist{}, bus.PortMode{}, bus.NextClip{})
+}
+
+func TestToggleLayer(t *testing.T) {
+	cfg := Config{Layers: []Layer{
+		{Bindings: []Binding{bind(Btn(evdev.BtnA), Press, bus.TogglePlay{})}},
+		{
+			Modifiers: []Button{Btn(evdev.BtnY)},
+			Toggle:    true,
+			Bindings:  []Binding{bind(Btn(evdev.BtnA), Press, bus.ToggleRecord{})},
+		},
+	}}
+
+	got := run(t, cfg,
+		down(evdev.BtnY), up(evdev.BtnY),
+		down(evdev.BtnA), up(evdev.BtnA),
+		down(evdev.BtnY), up(evdev.BtnY),
+		down(evdev.BtnA), up(evdev.BtnA),
+	)
+
+	check(t, got, bus.ToggleRecord{}, bus.TogglePlay{})
+}
+
+func TestReleaseUsesLayerOfPress(t *testing.T) {
+	cfg := Config{Layers: []Layer{
+		{Bindings: []Binding{
+			bind(Btn(evdev.BtnA), Down, bus.Gate{Channel: 5, On: true}),
+			bind(Btn(evdev.BtnA), Up, bus.Gate{Channel: 5}),
+		}},
+		{Modifiers: []Button{Btn(evdev.BtnZ)}, Bindings: []Binding{
+			bind(Btn(evdev.BtnA), Up, bus.Gate{Channel: 6}),
+		}},
+	}}
+
+	got := run(t, cfg, down(evdev.BtnA), down(evdev.BtnZ), up(evdev.BtnA))
+
+	check(t, got, bus.Gate{Channel: 5, On: true}, bus.Gate{Channel: 5})
+}
+
+func TestHatAndAxes(t *testing.T) {
+	cfg := Config{
+		Layers: []Layer{{Bindings: []Binding{
+			bind(Hat(evdev.AbsoluteHat0X, -1), Press, bus.PrevClip{}),
+			bind(Hat(evdev.AbsoluteHat0X, 1), Down, bus.Gate{Channel: 10, On: true}),
+			bind(Hat(evdev.AbsoluteHat0X, 1), Up, bus.Gate{Channel: 10}),
+		}}},
+		Axes: map[any]func(int32) bus.Action{
+			evdev.AbsoluteX: func(v int32) bus.Action { return bus.MoveCC{CC: 0, Speed: v} },
+		},
+	}
+
+	got := run(t, cfg,
+		down(evdev.AbsoluteHat0X), step{event: ev(evdev.AbsoluteHat0X, -1)}, up(evdev.AbsoluteHat0X),
+		step{event: ev(evdev.AbsoluteX, -300)},
+		step{event: ev(evdev.AbsoluteY, 300)},
+	)
+
+	check(t, got, bus.Gate{Channel: 10, On: true}, bus.Gate{Channel: 10}, bus.PrevClip{}, bus.MoveCC{CC: 0, Speed: -300})
+}
+
+func TestReset(t *testing.T) {
+	cfg := Config{Layers: []Layer{
+		{Bindings: []Binding{
+			bind(Btn(evdev.BtnA), Up, bus.Gate{Channel: 5}),
+			bind(Btn(evdev.BtnB), Press, bus.NextClip{}),
+			bind(Btn(evdev.BtnB), LongPress, bus.NextPlaylist{}),
+		}},
+		{Name: "shift", Modifiers: []Button{Btn(evdev.BtnZ)}},
+	}}
+
+	var got recorder
+
+	m := NewMapper(cfg, &got)
+
+	for _, e := range []*evdev.EventEnvelope{ev(evdev.BtnA, 1), ev(evdev.BtnB, 1), ev(evdev.BtnZ, 1)} {
+		err := m.HandleEvent(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if a := m.Active(); !reflect.DeepEqual(a, []string{"shift", ""}) {
+		t.Errorf("active layers %q", a)
+	}
+
+	err := m.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check(t, got, bus.Gate{Channel: 5})
+
+	if a := m.Active(); len(a) != 1 {
+		t.Errorf("shift still active after reset: %q", a)
+	}
+}
+
+func TestResetCentresAxes(t *testing.T) {
+	cfg := Config{Axes: map[any]func(int32) bus.Action{
+		evdev.AbsoluteX:  func(v int32) bus.Action { return bus.MoveCrossfader{Speed: v} },
+		evdev.AbsoluteY:  func(v int32) bus.Action { return bus.MoveCC{CC: 1, Speed: v} },
+		evdev.AbsoluteRX: func(v int32) bus.Action { return bus.Jog{Speed: v} },
+	}}
+
+	var got recorder
+
+	m := NewMapper(cfg, &got)
+
+	// X is held off centre, Y went back to the centre, RX never moved
+	for _, e := range []*evdev.EventEnvelope{ev(evdev.AbsoluteX, 20000), ev(evdev.AbsoluteY, -500), ev(evdev.AbsoluteY, 0)} {
+		err := m.HandleEvent(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got = nil
+
+	err := m.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check(t, got, bus.MoveCrossfader{})
+
+	got = nil
+
+	err = m.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check(t, got)
+}
@@ -0,0 +1,259 @@
+// Package menu provides on-screen menus and prompts navigated with the D-pad.
+package menu
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/eiannone/keyboard"
+	"github.com/kenshaw/evdev"
+)
+
+// Item is one choice of a Menu. Action may be nil, e.g. for "No" in a prompt.
+type Item struct {
+	Label  string
+	Action func() error
+}
+
+// Menu is a list of choices. Cancelling it does nothing.
+type Menu struct {
+	Title   string
+	Items   []Item
+	Current int
+}
+
+// Confirm returns a yes/no prompt for a destructive action. "No" is preselected.
+func Confirm(question string, yes func() error) Menu {
+	return Menu{
+		Title: question,
+		Items: []Item{
+			{Label: "No"},
+			{Label: "Yes", Action: yes},
+		},
+	}
+}
+
+// View is what a UI shows of the open menu.
+type View struct {
+	Title   string
+	Items   []string
+	Current int
+}
+
+// UI shows menus. ShowMenu is called whenever the open menu or its selection changes.
+type UI interface {
+	ShowMenu(v View)
+	HideMenu()
+}
+
+// Navigator holds the open menu, at most one at a time, and routes input to it.
+// It is safe for concurrent use.
+type Navigator struct {
+	ui UI
+
+	mu   sync.Mutex
+	open *Menu
+	// swallowed are the buttons whose presses went to a menu, their releases go there too
+	swallowed map[any]bool
+}
+
+func NewNavigator(ui UI) *Navigator {
+	return &Navigator{
+		ui:        ui,
+		swallowed: map[any]bool{},
+	}
+}
+
+// Open shows m, replacing any open menu.
+func (n *Navigator) Open(m Menu) {
+	if len(m.Items) == 0 {
+		return
+	}
+
+	m.Current = max(0, min(m.Current, len(m.Items)-1))
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.open = &m
+
+	n.show()
+}
+
+func (n *Navigator) IsOpen() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.open != nil
+}
+
+// Up selects the previous item, wrapping around.
+func (n *Navigator) Up() {
+	n.move(-1)
+}
+
+// Down selects the next item, wrapping around.
+func (n *Navigator) Down() {
+	n.move(1)
+}
+
+func (n *Navigator) move(d int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.open == nil {
+		return
+	}
+
+	k := len(n.open.Items)
+	n.open.Current = (n.open.Current + d + k) % k
+
+	n.show()
+}
+
+// Select closes the menu and runs the selected item's action.
+// The action may open another menu, e.g. a prompt.
+func (n *Navigator) Select() error {
+	n.mu.Lock()
+
+	if n.open == nil {
+		n.mu.Unlock()
+
+		return nil
+	}
+
+	item := n.open.Items[n.open.Current]
+
+	n.open = nil
+	n.ui.HideMenu()
+
+	n.mu.Unlock()
+
+	if item.Action == nil {
+		return nil
+	}
+
+	err := item.Action()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", item.Label, err)
+	}
+
+	return nil
+}
+
+// Cancel closes the menu without doing anything.
+func (n *Navigator) Cancel() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.open == nil {
+		return
+	}
+
+	n.open = nil
+	n.ui.HideMenu()
+}
+
+func (n *Navigator) show() {
+	v := View{
+		Title:   n.open.Title,
+		Items:   make([]string, len(n.open.Items)),
+		Current: n.open.Current,
+	}
+
+	for i, it := range n.open.Items {
+		v.Items[i] = it.Label
+	}
+
+	n.ui.ShowMenu(v)
+}
+
+// HandleEvent navigates the open menu: D-pad up/down, A to select and B to cancel.
+// While a menu is open it swallows all presses, so they don't reach the controllers, and then their releases,
+// even once the menu is closed, e.g. by the A that selected. Other releases and the analog sticks are passed on
+// to keep the controllers' state consistent.
+func (n *Navigator) HandleEvent(event *evdev.EventEnvelope) (handled bool, err error) {
+	switch event.Type {
+	case evdev.AbsoluteX, evdev.AbsoluteY, evdev.AbsoluteRX, evdev.AbsoluteRY:
+		return false, nil
+	}
+
+	if event.Value == 0 {
+		return n.released(event.Type), nil
+	}
+
+	if !n.pressed(event.Type) {
+		return false, nil
+	}
+
+	switch event.Type {
+	case evdev.AbsoluteHat0Y: // Switch Pro D-Pad
+		if event.Value < 0 {
+			n.Up()
+		} else {
+			n.Down()
+		}
+	case evdev.KeyType(544): // JoyCon D-Pad
+		n.Up()
+	case evdev.KeyType(545):
+		n.Down()
+	case evdev.BtnA:
+		return true, n.Select()
+	case evdev.BtnB:
+		n.Cancel()
+	}
+
+	return true, nil
+}
+
+// pressed reports whether a menu is open and takes the press of typ, so its release is swallowed too.
+func (n *Navigator) pressed(typ any) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.open == nil {
+		// the controllers get this press, so they get its release, even if an earlier one was missed
+		delete(n.swallowed, typ)
+
+		return false
+	}
+
+	n.swallowed[typ] = true
+
+	return true
+}
+
+// released reports whether the press of typ was swallowed.
+func (n *Navigator) released(typ any) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.swallowed[typ] {
+		return false
+	}
+
+	delete(n.swallowed, typ)
+
+	return true
+}
+
+// HandleKey is the keyboard counterpart to HandleEvent: ↑/↓ navigate, Enter selects and Esc cancels.
+// While a menu is open it swallows all keys.
+func (n *Navigator) HandleKey(key keyboard.KeyEvent) (handled bool, err error) {
+	if !n.IsOpen() {
+		return false, nil
+	}
+
+	switch key.Key {
+	case keyboard.KeyArrowUp:
+		n.Up()
+	case keyboard.KeyArrowDown:
+		n.Down()
+	case keyboard.KeyEnter:
+		return true, n.Select()
+	case keyboard.KeyEsc:
+		n.Cancel()
+	}
+
+	return true, nil
+}
@@ -0,0 +1,264 @@
+package menu
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eiannone/keyboard"
+	"github.com/kenshaw/evdev"
+)
+
+type fakeUI struct {
+	view *View
+}
+
+func (ui *fakeUI) ShowMenu(v View) {
+	ui.view = &v
+}
+
+func (ui *fakeUI) HideMenu() {
+	ui.view = nil
+}
+
+func ev(typ any, value int32) *evdev.EventEnvelope {
+	return &evdev.EventEnvelope{
+		Event: evdev.Event{Value: value},
+		Type:  typ,
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	ui := &fakeUI{}
+	n := NewNavigator(ui)
+
+	var chosen string
+
+	choose := func(s string) func() error {
+		return func() error {
+			chosen = s
+
+			return nil
+		}
+	}
+
+	n.Open(Menu{
+		Title: "test",
+		Items: []Item{{"a", choose("a")}, {"b", choose("b")}, {"c", choose("c")}},
+	})
+
+	if ui.view == nil || ui.view.Current != 0 {
+		t.Fatalf("menu not shown: %+v", ui.view)
+	}
+
+	n.Up()
+
+	if ui.view.Current != 2 {
+		t.Errorf("up from first item should wrap to last, got %d", ui.view.Current)
+	}
+
+	n.Down()
+	n.Down()
+
+	err := n.Select()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if chosen != "b" {
+		t.Errorf("chose %q, want b", chosen)
+	}
+
+	if ui.view != nil || n.IsOpen() {
+		t.Error("menu still open after select")
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	ui := &fakeUI{}
+	n := NewNavigator(ui)
+
+	deleted := false
+
+	n.Open(Confirm("Delete?", func() error {
+		deleted = true
+
+		return nil
+	}))
+
+	// the default answer is no
+	err := n.Select()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted {
+		t.Fatal("deleted without confirmation")
+	}
+
+	n.Open(Confirm("Delete?", func() error {
+		deleted = true
+
+		return nil
+	}))
+
+	n.Down()
+
+	err = n.Select()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !deleted {
+		t.Error("not deleted after confirmation")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ui := &fakeUI{}
+	n := NewNavigator(ui)
+
+	n.Open(Confirm("Delete?", func() error {
+		t.Error("action ran on cancel")
+
+		return nil
+	}))
+
+	n.Down()
+	n.Cancel()
+
+	if ui.view != nil || n.IsOpen() {
+		t.Error("menu still open after cancel")
+	}
+}
+
+func TestActionOpensPrompt(t *testing.T) {
+	ui := &fakeUI{}
+	n := NewNavigator(ui)
+
+	n.Open(Menu{
+		Title: "Recordings",
+		Items: []Item{{"1.mp4", func() error {
+			n.Open(Confirm("Delete 1.mp4?", nil))
+
+			return nil
+		}}},
+	})
+
+	err := n.Select()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ui.view == nil || ui.view.Title != "Delete 1.mp4?" {
+		t.Errorf("prompt not shown: %+v", ui.view)
+	}
+}
+
+func TestSelectReturnsActionError(t *testing.T) {
+	n := NewNavigator(&fakeUI{})
+
+	errFail := errors.New("fail")
+
+	n.Open(Menu{Items: []Item{{"x", func() error { return errFail }}}})
+
+	err := n.Select()
+	if !errors.Is(err, errFail) {
+		t.Errorf("got %v, want %v", err, errFail)
+	}
+}
+
+func TestHandleEvent(t *testing.T) {
+	ui := &fakeUI{}
+	n := NewNavigator(ui)
+
+	handled, _ := n.HandleEvent(ev(evdev.BtnA, 1))
+	if handled {
+		t.Error("handled event without open menu")
+	}
+
+	selected := false
+
+	n.Open(Menu{Items: []Item{{"a", nil}, {"b", func() error {
+		selected = true
+
+		return nil
+	}}}})
+
+	tests := []struct {
+		name    string
+		event   *evdev.EventEnvelope
+		handled bool
+	}{
+		{"stick passes", ev(evdev.AbsoluteX, 1000), false},
+		{"release of an earlier press passes", ev(evdev.BtnY, 0), false},
+		{"other press is swallowed", ev(evdev.BtnX, 1), true},
+		{"its release too", ev(evdev.BtnX, 0), true},
+		{"joycon down", ev(evdev.KeyType(545), 1), true},
+		{"select", ev(evdev.BtnA, 1), true},
+		{"release of select after the menu closed", ev(evdev.BtnA, 0), true},
+		{"next press after the menu closed", ev(evdev.BtnA, 1), false},
+		{"and its release", ev(evdev.BtnA, 0), false},
+	}
+
+	for _, tt := range tests {
+		handled, err := n.HandleEvent(tt.event)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if handled != tt.handled {
+			t.Errorf("%s: handled is %v, want %v", tt.name, handled, tt.handled)
+		}
+	}
+
+	if !selected {
+		t.Error("D-pad down and A didn't select the second item")
+	}
+
+	n.Open(Confirm("Delete?", nil))
+
+	_, _ = n.HandleEvent(ev(evdev.AbsoluteHat0Y, 1))
+
+	if ui.view.Current != 1 {
+		t.Errorf("hat down didn't move, current is %d", ui.view.Current)
+	}
+
+	_, _ = n.HandleEvent(ev(evdev.BtnB, 1))
+
+	if n.IsOpen() {
+		t.Error("B didn't cancel")
+	}
+}
+
+func TestHandleKey(t *testing.T) {
+	ui := &fakeUI{}
+	n := NewNavigator(ui)
+
+	handled, _ := n.HandleKey(keyboard.KeyEvent{Rune: 'p'})
+	if handled {
+		t.Error("handled key without open menu")
+	}
+
+	deleted := false
+
+	n.Open(Confirm("Delete?", func() error {
+		deleted = true
+
+		return nil
+	}))
+
+	for _, key := range []keyboard.KeyEvent{{Rune: 'p'}, {Key: keyboard.KeyArrowDown}, {Key: keyboard.KeyEnter}} {
+		handled, err := n.HandleKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !handled {
+			t.Errorf("key %+v not handled", key)
+		}
+	}
+
+	if !deleted {
+		t.Error("↓ and Enter didn't confirm")
+	}
+}
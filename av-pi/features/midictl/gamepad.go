@@ -0,0 +1,92 @@
+package midictl
+
+import (
+	"github.com/kenshaw/evdev"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/input"
+)
+
+// momentary gates, by button
+var momentary = map[input.Button]uint8{
+	input.Btn(evdev.BtnA):       5,
+	input.Btn(evdev.BtnB):       4,
+	input.Btn(evdev.BtnX):       7,
+	input.Btn(evdev.BtnY):       6,
+	input.Btn(evdev.BtnTL2):     14,
+	input.Btn(evdev.BtnTR2):     15,
+	input.Btn(evdev.AbsoluteZ):  14, // 8bitdo TL2
+	input.Btn(evdev.AbsoluteRZ): 15, // 8bitdo TR2
+}
+
+// latched gates, by button
+var latched = []struct {
+	buttons []input.Button
+	ch      uint8
+}{
+	{input.DpadUp, 8},
+	{input.DpadLeft, 9},
+	{input.DpadRight, 10},
+	{input.DpadDown, 11},
+	{[]input.Button{input.Btn(evdev.BtnTL)}, 12}, // triggers
+	{[]input.Button{input.Btn(evdev.BtnTR)}, 13},
+}
+
+// Layout is the MIDI layout: the sticks move the CCs, the face buttons and ZL/ZR are momentary gates,
+// the D-pad and L/R latch gates, Start/Select change the step size, or the MIDI port while Z is held,
+// and Mode opens the port menu. Z+R opens the menu for learning which MIDI control sets a sampler parameter.
+func Layout() input.Config {
+	base := input.Bindings(
+		input.Bind(input.Press, bus.DecStepSize{}, input.Btn(evdev.BtnSelect)),
+		input.Bind(input.Press, bus.IncStepSize{}, input.Btn(evdev.BtnStart)),
+		input.Bind(input.Press, bus.PortMenu{}, input.Btn(evdev.BtnMode)),
+	)
+
+	for b, ch := range momentary {
+		base = append(base,
+			input.Binding{Buttons: []input.Button{b}, Gesture: input.Down, Action: bus.Gate{Channel: ch, On: true}},
+			input.Binding{Buttons: []input.Button{b}, Gesture: input.Up, Action: bus.Gate{Channel: ch}},
+		)
+	}
+
+	for _, l := range latched {
+		base = append(base, input.Bind(input.Press, bus.ToggleGate{Channel: l.ch}, l.buttons...)...)
+	}
+
+	moveCC := func(cc int) func(int32) bus.Action {
+		return func(v int32) bus.Action {
+			return bus.MoveCC{CC: cc, Speed: v}
+		}
+	}
+
+	return input.Config{
+		Layers: []input.Layer{
+			{
+				Name:     "step",
+				Bindings: base,
+			},
+			{
+				Name:      "port",
+				Modifiers: []input.Button{input.Btn(evdev.BtnZ), input.Btn(evdev.AbsoluteZ)},
+				OnEnter:   bus.PortMode{On: true},
+				OnExit:    bus.PortMode{},
+				Bindings: input.Bindings(
+					input.Bind(input.Press, bus.PrevPort{}, input.Btn(evdev.BtnSelect)),
+					input.Bind(input.Press, bus.NextPort{}, input.Btn(evdev.BtnStart)),
+					input.Bind(input.Press, bus.LearnMenu{}, input.Btn(evdev.BtnTR)),
+				),
+			},
+		},
+		Axes: map[any]func(int32) bus.Action{
+			evdev.AbsoluteX:  moveCC(0),
+			evdev.AbsoluteY:  moveCC(1),
+			evdev.AbsoluteRX: moveCC(2),
+			evdev.AbsoluteRY: moveCC(3),
+		},
+	}
+}
+
+// NewGamepad returns a mapper publishing the MIDI actions to pub.
+func NewGamepad(pub bus.Publisher) *input.Mapper {
+	return input.NewMapper(Layout(), pub)
+}
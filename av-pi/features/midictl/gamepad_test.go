@@ -0,0 +1,55 @@
+package midictl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kenshaw/evdev"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+)
+
+type recorder []bus.Action
+
+func (r *recorder) Publish(a bus.Action) error {
+	*r = append(*r, a)
+
+	return nil
+}
+
+func TestGamepadActions(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []*evdev.EventEnvelope
+		want   []bus.Action
+	}{
+		{"stick", []*evdev.EventEnvelope{ev(evdev.AbsoluteRY, -100)}, []bus.Action{bus.MoveCC{CC: 3, Speed: -100}}},
+		{"momentary gate", []*evdev.EventEnvelope{ev(evdev.BtnA, 1), ev(evdev.BtnA, 0)}, []bus.Action{bus.Gate{Channel: 5, On: true}, bus.Gate{Channel: 5}}},
+		{"latched gate", []*evdev.EventEnvelope{ev(evdev.BtnTR, 1), ev(evdev.BtnTR, 0)}, []bus.Action{bus.ToggleGate{Channel: 13}}},
+		{"hat", []*evdev.EventEnvelope{ev(evdev.AbsoluteHat0X, 1), ev(evdev.AbsoluteHat0X, 0)}, []bus.Action{bus.ToggleGate{Channel: 10}}},
+		{"step size", []*evdev.EventEnvelope{ev(evdev.BtnStart, 1), ev(evdev.BtnSelect, 1)}, []bus.Action{bus.IncStepSize{}, bus.DecStepSize{}}},
+		{"port", []*evdev.EventEnvelope{ev(evdev.BtnZ, 1), ev(evdev.BtnStart, 1), ev(evdev.BtnZ, 0)}, []bus.Action{bus.PortMode{On: true}, bus.NextPort{}, bus.PortMode{}}},
+		{"8bitdo ZL", []*evdev.EventEnvelope{ev(evdev.AbsoluteZ, 1)}, []bus.Action{bus.PortMode{On: true}, bus.Gate{Channel: 14, On: true}}},
+		{"learn menu", []*evdev.EventEnvelope{ev(evdev.BtnZ, 1), ev(evdev.BtnTR, 1), ev(evdev.BtnTR, 0)}, []bus.Action{bus.PortMode{On: true}, bus.LearnMenu{}}},
+		{"port menu", []*evdev.EventEnvelope{ev(evdev.BtnMode, 1)}, []bus.Action{bus.PortMenu{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got recorder
+
+			g := NewGamepad(&got)
+
+			for _, e := range tt.events {
+				err := g.HandleEvent(e)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !reflect.DeepEqual([]bus.Action(got), tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
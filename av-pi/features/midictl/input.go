@@ -0,0 +1,229 @@
+package midictl
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/drivers"
+	"go.uber.org/zap"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/menu"
+)
+
+// inputQueue is how many mapped actions wait to be published before further ones are dropped,
+// e.g. while a playlist selected by a program change is parsed.
+const inputQueue = 64
+
+// Input listens to a MIDI input port and publishes the actions its mappings translate the messages to.
+type Input struct {
+	pub    bus.Publisher
+	ui     UI
+	menus  *menu.Navigator
+	params []string
+
+	mu       sync.Mutex
+	mappings *Mappings
+
+	port    drivers.In
+	stop    func()
+	actions chan bus.Action
+	// closing stops publish, which closes done when it returns. actions is never closed,
+	// the driver may still call receive while the port is closed.
+	closing chan struct{}
+	done    chan struct{}
+}
+
+// NewInput listens to the first MIDI input port whose name contains port, or the first port if it is empty.
+// Without input ports it still handles the learn actions, so mappings can be prepared.
+// params are the names of the sampler parameters that can be learned.
+func NewInput(port string, mappings *Mappings, params []string, pub bus.Publisher, ui UI, menus *menu.Navigator) (*Input, error) {
+	in := &Input{
+		pub:      pub,
+		ui:       ui,
+		menus:    menus,
+		params:   params,
+		mappings: mappings,
+		actions:  make(chan bus.Action, inputQueue),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go in.publish()
+
+	inPorts := midi.GetInPorts()
+
+	idx := slices.IndexFunc(inPorts, func(p drivers.In) bool {
+		return strings.Contains(p.String(), port)
+	})
+	if idx < 0 {
+		zap.S().Warnw("no MIDI input port", "name", port)
+
+		return in, nil
+	}
+
+	in.port = inPorts[idx]
+
+	// the time code option lets the clock through
+	stop, err := midi.ListenTo(in.port, func(msg midi.Message, _ int32) {
+		in.receive(msg)
+	}, midi.UseTimeCode(), midi.HandleError(func(err error) {
+		zap.S().Warnw("failed to receive MIDI", "error", err)
+	}))
+	if err != nil {
+		close(in.closing)
+
+		return nil, fmt.Errorf("failed to listen to MIDI input port %q: %w", in.port.String(), err)
+	}
+
+	in.stop = stop
+
+	zap.S().Infow("listening to MIDI input", "name", in.port.String())
+
+	return in, nil
+}
+
+// Close stops listening and waits until the queued actions are published.
+func (in *Input) Close() error {
+	var err error
+
+	if in.stop != nil {
+		in.stop()
+
+		err = in.port.Close()
+	}
+
+	close(in.closing)
+	<-in.done
+
+	if err != nil {
+		return fmt.Errorf("failed to close MIDI input port: %w", err)
+	}
+
+	return nil
+}
+
+// receive is called by the MIDI driver for every message.
+func (in *Input) receive(msg midi.Message) {
+	select {
+	case <-in.closing:
+		return
+	default:
+	}
+
+	in.mu.Lock()
+	actions, learned, err := in.mappings.Translate(msg)
+	in.mu.Unlock()
+
+	if learned != nil {
+		in.ui.SendText(fmt.Sprintf("%s mapped to %s", learned, learned.Param))
+	}
+
+	if err != nil {
+		zap.S().Errorw("failed to map MIDI", "message", msg.String(), "error", err)
+		in.ui.SendError(fmt.Sprintf("MIDI mapping error: %v", err))
+	}
+
+	for _, a := range actions {
+		select {
+		case in.actions <- a:
+		default:
+			zap.S().Debugw("dropped MIDI action", "action", a.String())
+		}
+	}
+}
+
+// publish publishes the actions in the order they were received, so the driver's callback never waits for the sampler.
+// Once closing, it publishes what is queued and returns.
+func (in *Input) publish() {
+	defer close(in.done)
+
+	for {
+		select {
+		case a := <-in.actions:
+			in.perform(a)
+
+		case <-in.closing:
+			for {
+				select {
+				case a := <-in.actions:
+					in.perform(a)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (in *Input) perform(a bus.Action) {
+	err := in.pub.Publish(a)
+	if err != nil {
+		zap.S().Debugw("failed to perform MIDI action", "action", a.String(), "error", err)
+	}
+}
+
+// Handle performs the learn actions. Actions for other services are ignored.
+func (in *Input) Handle(a bus.Action) error {
+	switch a := a.(type) {
+	case bus.Learn:
+		return in.learn(a.Param)
+
+	case bus.Unlearn:
+		in.mu.Lock()
+		err := in.mappings.Unlearn(a.Param)
+		in.mu.Unlock()
+
+		if err != nil {
+			return fmt.Errorf("failed to unlearn %s: %w", a.Param, err)
+		}
+
+		in.ui.SendText(a.Param + " unmapped")
+
+	case bus.LearnMenu:
+		in.menus.Open(in.learnMenu())
+	}
+
+	return nil
+}
+
+func (in *Input) learn(param string) error {
+	if !slices.Contains(in.params, param) {
+		return fmt.Errorf("unknown parameter %q, want one of %s", param, strings.Join(in.params, ", "))
+	}
+
+	in.mu.Lock()
+	in.mappings.Learn(param)
+	in.mu.Unlock()
+
+	in.ui.SendText(fmt.Sprintf("move a MIDI control to map it to %s", param))
+
+	return nil
+}
+
+// learnMenu lists the parameters, choosing one learns it.
+func (in *Input) learnMenu() menu.Menu {
+	m := menu.Menu{Title: "MIDI learn"}
+
+	in.mu.Lock()
+	learning, _ := in.mappings.Learning()
+	in.mu.Unlock()
+
+	for _, p := range in.params {
+		if p == learning {
+			m.Current = len(m.Items)
+		}
+
+		m.Items = append(m.Items, menu.Item{
+			Label: p,
+			Action: func() error {
+				return in.learn(p)
+			},
+		})
+	}
+
+	return m
+}
@@ -0,0 +1,153 @@
+package midictl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"gitlab.com/gomidi/midi/v2"
+
+	"github.com/markus-wa/vlc-sampler/features/atomicfile"
+	"github.com/markus-wa/vlc-sampler/features/bus"
+)
+
+// BaseNote is the note that selects the first clip, C1 as on most drum pads, the notes above it select the next clips.
+const BaseNote = 36
+
+// clockTicks is how many MIDI clock messages there are per beat.
+const clockTicks = 24
+
+// MappingFileName is the name of the file the learned mappings are saved in.
+const MappingFileName = "midi-map.json"
+
+// Mapping maps a CC of the MIDI input to a sampler parameter.
+type Mapping struct {
+	Channel uint8  `json:"channel"`
+	CC      uint8  `json:"cc"`
+	Param   string `json:"param"`
+}
+
+func (m Mapping) String() string {
+	return fmt.Sprintf("CC %d on channel %d", m.CC, m.Channel)
+}
+
+type mappingFile struct {
+	Mappings []Mapping `json:"mappings"`
+}
+
+// Mappings turns MIDI messages into sampler actions: mapped CCs set parameters, notes select clips,
+// program changes playlists and the MIDI clock beats the sampler's beat grid.
+// Learned mappings are written to the file. It is not safe for concurrent use.
+type Mappings struct {
+	path string
+	f    mappingFile
+
+	// learning is the parameter the next CC is mapped to, empty if not learning
+	learning string
+	// ticks are the clock messages since the last beat
+	ticks int
+}
+
+// LoadMappings reads the mappings from path. A missing file has no mappings, it is created when one is learned.
+func LoadMappings(path string) (*Mappings, error) {
+	m := &Mappings{path: path}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read MIDI mappings: %w", err)
+	}
+
+	err = json.Unmarshal(b, &m.f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse MIDI mappings %s: %w", path, err)
+	}
+
+	return m, nil
+}
+
+// Learn maps the next CC received to param.
+func (m *Mappings) Learn(param string) {
+	m.learning = param
+}
+
+// Learning returns the parameter that is being learned, if any.
+func (m *Mappings) Learning() (string, bool) {
+	return m.learning, m.learning != ""
+}
+
+// Unlearn removes all mappings to param.
+func (m *Mappings) Unlearn(param string) error {
+	n := len(m.f.Mappings)
+
+	m.f.Mappings = slices.DeleteFunc(m.f.Mappings, func(mp Mapping) bool {
+		return mp.Param == param
+	})
+
+	if len(m.f.Mappings) == n {
+		return fmt.Errorf("%s isn't mapped", param)
+	}
+
+	return m.save()
+}
+
+// Translate returns the actions for msg. While learning, a CC is mapped instead and returned as learned.
+func (m *Mappings) Translate(msg midi.Message) (actions []bus.Action, learned *Mapping, err error) {
+	var ch, cc, v, key, vel, program uint8
+
+	switch {
+	case msg.GetControlChange(&ch, &cc, &v):
+		if m.learning != "" {
+			mp := Mapping{Channel: ch, CC: cc, Param: m.learning}
+			m.learning = ""
+
+			// a CC controls one parameter
+			m.f.Mappings = slices.DeleteFunc(m.f.Mappings, func(old Mapping) bool {
+				return old.Channel == ch && old.CC == cc
+			})
+			m.f.Mappings = append(m.f.Mappings, mp)
+
+			return nil, &mp, m.save()
+		}
+
+		for _, mp := range m.f.Mappings {
+			if mp.Channel == ch && mp.CC == cc {
+				actions = append(actions, bus.SetParam{Name: mp.Param, Value: float64(v) / 127})
+			}
+		}
+
+	case msg.GetNoteStart(&ch, &key, &vel):
+		if key >= BaseNote {
+			actions = append(actions, bus.SelectClip{N: int(key-BaseNote) + 1})
+		}
+
+	case msg.GetProgramChange(&ch, &program):
+		actions = append(actions, bus.SelectPlaylist{N: int(program) + 1})
+
+	case msg.Is(midi.StartMsg):
+		m.ticks = 0
+		actions = append(actions, bus.ClockStart{})
+
+	case msg.Is(midi.TimingClockMsg):
+		m.ticks++
+
+		if m.ticks == clockTicks {
+			m.ticks = 0
+			actions = append(actions, bus.ClockBeat{})
+		}
+	}
+
+	return actions, nil, nil
+}
+
+func (m *Mappings) save() error {
+	err := atomicfile.WriteJSON(m.path, m.f)
+	if err != nil {
+		return fmt.Errorf("failed to save MIDI mappings: %w", err)
+	}
+
+	return nil
+}
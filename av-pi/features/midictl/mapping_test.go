@@ -0,0 +1,158 @@
+package midictl
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/menu"
+)
+
+func loadTestMappings(t *testing.T) (*Mappings, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), MappingFileName)
+
+	m, err := LoadMappings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m, path
+}
+
+func TestLearn(t *testing.T) {
+	m, path := loadTestMappings(t)
+
+	actions, _, _ := m.Translate(midi.ControlChange(1, 7, 100))
+	if len(actions) > 0 {
+		t.Errorf("unmapped CC published %v", actions)
+	}
+
+	m.Learn("hue")
+
+	_, learned, err := m.Translate(midi.ControlChange(1, 7, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if learned == nil || *learned != (Mapping{Channel: 1, CC: 7, Param: "hue"}) {
+		t.Fatalf("learned %v", learned)
+	}
+
+	// learning the CC again moves it to the other parameter
+	m.Learn("zoom")
+
+	_, _, err = m.Translate(midi.ControlChange(1, 7, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = LoadMappings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions, _, _ = m.Translate(midi.ControlChange(1, 7, 127))
+	if !reflect.DeepEqual(actions, []bus.Action{bus.SetParam{Name: "zoom", Value: 1}}) {
+		t.Errorf("got %v", actions)
+	}
+
+	err = m.Unlearn("zoom")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Unlearn("zoom") == nil {
+		t.Error("unlearned an unmapped parameter")
+	}
+}
+
+func TestTranslateNotesAndPrograms(t *testing.T) {
+	m, _ := loadTestMappings(t)
+
+	tests := []struct {
+		msg  midi.Message
+		want []bus.Action
+	}{
+		{midi.NoteOn(9, BaseNote, 100), []bus.Action{bus.SelectClip{N: 1}}},
+		{midi.NoteOn(9, BaseNote+3, 100), []bus.Action{bus.SelectClip{N: 4}}},
+		{midi.NoteOn(9, BaseNote-1, 100), nil},
+		{midi.NoteOff(9, BaseNote), nil},
+		{midi.ProgramChange(0, 2), []bus.Action{bus.SelectPlaylist{N: 3}}},
+	}
+
+	for _, tt := range tests {
+		got, _, err := m.Translate(tt.msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestClock(t *testing.T) {
+	m, _ := loadTestMappings(t)
+
+	var got []bus.Action
+
+	for _, msg := range append([]midi.Message{midi.Start()}, slices.Repeat([]midi.Message{midi.TimingClock()}, 2*clockTicks+1)...) {
+		actions, _, err := m.Translate(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, actions...)
+	}
+
+	if want := []bus.Action{bus.ClockStart{}, bus.ClockBeat{}, bus.ClockBeat{}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInputLearn(t *testing.T) {
+	m, _ := loadTestMappings(t)
+	ui := &fakeUI{}
+
+	var got recorder
+
+	in, err := NewInput("", m, []string{"hue", "rate"}, &got, ui, menu.NewNavigator(ui))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if in.Handle(bus.Learn{Param: "speed"}) == nil {
+		t.Error("learned an unknown parameter")
+	}
+
+	err = in.Handle(bus.Learn{Param: "rate"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in.receive(midi.ControlChange(0, 1, 10))
+	in.receive(midi.ControlChange(0, 1, 127))
+
+	err = in.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the driver may still deliver while the port closes
+	in.receive(midi.ControlChange(0, 1, 0))
+
+	if !reflect.DeepEqual([]bus.Action(got), []bus.Action{bus.SetParam{Name: "rate", Value: 1}}) {
+		t.Errorf("published %v", got)
+	}
+
+	if !ui.contains("CC 1 on channel 0 mapped to rate") {
+		t.Errorf("texts %v", ui.texts)
+	}
+}
@@ -1,23 +1,31 @@
 package midictl
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"slices"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/kenshaw/evdev"
 	"gitlab.com/gomidi/midi/v2"
 	"gitlab.com/gomidi/midi/v2/drivers"
+	"go.uber.org/zap"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/menu"
 )
 
+var errNoPort = errors.New("no MIDI port open")
+
 type Service struct {
 	portIdx int
 	port    drivers.Out
 	mu      sync.Mutex
 
+	// synced is false until the last velocities have been sent on the current port
+	synced   bool
 	lastVel0 uint8
 	lastVel1 uint8
 	lastVel2 uint8
@@ -50,7 +58,7 @@ func (s *Service) openPort(i int) error {
 		idx = len(outPorts) - 1
 	}
 
-	log.Println("changing MIDI port from", s.portIdx, "to", idx)
+	zap.S().Infow("changing MIDI port", "from", s.portIdx, "to", idx, "name", outPorts[idx].String())
 
 	newPort := outPorts[idx]
 
@@ -65,6 +73,7 @@ func (s *Service) openPort(i int) error {
 
 	s.port = newPort
 	s.portIdx = idx
+	s.synced = false
 
 	if err != nil {
 		return fmt.Errorf("failed to close old MIDI port: %w", err)
@@ -86,23 +95,63 @@ func (s *Service) openDefaultPort() error {
 	return nil
 }
 
+// reopenPort closes the current port and opens the port with the same name again,
+// falling back to the default port if it disappeared (e.g. the USB cable was replugged).
+func (s *Service) reopenPort() error {
+	s.mu.Lock()
+
+	var name string
+
+	if s.port != nil {
+		name = s.port.String()
+
+		err := s.port.Close()
+		if err != nil {
+			zap.S().Warnw("failed to close MIDI port", "port", name, "error", err)
+		}
+
+		s.port = nil
+	}
+
+	s.mu.Unlock()
+
+	idx := slices.IndexFunc(midi.GetOutPorts(), func(o drivers.Out) bool {
+		return o.String() == name
+	})
+
+	if idx < 0 {
+		return s.openDefaultPort()
+	}
+
+	err := s.openPort(idx)
+	if err != nil {
+		return fmt.Errorf("failed to reopen MIDI port %q: %w", name, err)
+	}
+
+	return nil
+}
+
 func (s *Service) Send(vel0, vel1, vel2, vel3 uint8) error {
-	if vel0 == s.lastVel0 && vel1 == s.lastVel1 && vel2 == s.lastVel2 && vel3 == s.lastVel3 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.port == nil {
+		return errNoPort
+	}
+
+	if s.synced && vel0 == s.lastVel0 && vel1 == s.lastVel1 && vel2 == s.lastVel2 && vel3 == s.lastVel3 {
 		return nil
 	}
 
 	var msgs []midi.Message
 
-	log.Println("sending MIDI CCs:", vel0, vel1, vel2, vel3)
+	zap.S().Debugw("sending MIDI CCs", "vel0", vel0, "vel1", vel1, "vel2", vel2, "vel3", vel3)
 
 	msgs = append(msgs, midi.ControlChange(0, 2, vel0))
 	msgs = append(msgs, midi.ControlChange(1, 2, vel1))
 	msgs = append(msgs, midi.ControlChange(2, 2, vel2))
 	msgs = append(msgs, midi.ControlChange(3, 2, vel3))
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	for _, m := range msgs {
 		err := s.port.Send(m)
 		if err != nil {
@@ -114,6 +163,7 @@ func (s *Service) Send(vel0, vel1, vel2, vel3 uint8) error {
 	s.lastVel1 = vel1
 	s.lastVel2 = vel2
 	s.lastVel3 = vel3
+	s.synced = true
 
 	return nil
 }
@@ -130,11 +180,15 @@ func (s *Service) Gate(ch uint8, on bool) error {
 		msg = midi.NoteOff(ch, gateKey)
 	}
 
-	log.Println("sending MIDI note:", ch, gateKey, on)
+	zap.S().Debugw("sending MIDI note", "channel", ch, "key", gateKey, "on", on)
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.port == nil {
+		return errNoPort
+	}
+
 	err := s.port.Send(msg)
 	if err != nil {
 		return fmt.Errorf("failed to send MIDI note: %w", err)
@@ -143,57 +197,204 @@ func (s *Service) Gate(ch uint8, on bool) error {
 	return nil
 }
 
+// PortName returns the name of the open MIDI port, or an empty string if none is open.
+func (s *Service) PortName() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.port == nil {
+		return ""
+	}
+
+	return s.port.String()
+}
+
+// Ports returns the names of all MIDI output ports.
+func (s *Service) Ports() []string {
+	var names []string
+
+	for _, p := range midi.GetOutPorts() {
+		names = append(names, p.String())
+	}
+
+	return names
+}
+
 func (s *Service) previousPort() error {
-	return s.openPort(s.portIdx - 1)
+	return s.openPort(s.currentPort() - 1)
 }
 
 func (s *Service) nextPort() error {
-	return s.openPort(s.portIdx + 1)
+	return s.openPort(s.currentPort() + 1)
+}
+
+func (s *Service) currentPort() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.portIdx
 }
 
 func (s *Service) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.port == nil {
+		return nil
+	}
+
 	return s.port.Close()
 }
 
 type UI interface {
 	SendText(text string)
+	SendError(text string)
+	SendMIDIState(state State)
+}
+
+type Mode int
+
+const (
+	ModeStep Mode = iota // Start/Select change the step size
+	ModePort             // Start/Select change the MIDI port
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeStep:
+		return "step"
+	case ModePort:
+		return "port"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
 }
 
+const numChannels = 16
+
+// State is a snapshot of the controller that is published to the UI whenever it changes.
+type State struct {
+	Port     string
+	StepSize uint8
+	Mode     Mode
+
+	// CCs are the values sent on channels 0-3, in the order of the X, Y, RX and RY axes.
+	CCs [4]uint8
+
+	// Gates is indexed by MIDI channel and includes both momentary and latched gates.
+	Gates [numChannels]bool
+}
+
+// String formats the state as a compact single line.
+func (s State) String() string {
+	var gates []string
+
+	for ch, on := range s.Gates {
+		if on {
+			gates = append(gates, fmt.Sprint(ch))
+		}
+	}
+
+	port := s.Port
+	if port == "" {
+		port = "-"
+	}
+
+	return fmt.Sprintf("MIDI %s | mode %s | step size %d | CC %d %d %d %d | gates %s",
+		port, s.Mode, s.StepSize, s.CCs[0], s.CCs[1], s.CCs[2], s.CCs[3], strings.Join(gates, ","))
+}
+
+var errClosed = errors.New("controller closed")
+
+// Controller turns actions into MIDI CCs and gates.
+// All mutable state is owned by the loop goroutine; Handle hands actions over to it.
 type Controller struct {
+	svc   *Service
+	ui    UI
+	menus *menu.Navigator
+
+	actions chan request
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// request carries either an action or, for menu items, a port change to run on the loop.
+type request struct {
+	action bus.Action
+	open   func() error
+	errc   chan<- error
+}
+
+// state is only ever accessed from the loop goroutine.
+type state struct {
 	x  int32
 	y  int32
 	rx int32
 	ry int32
 
-	upOn    bool
-	leftOn  bool
-	rightOn bool
-	downOn  bool
-	tlOn    bool
-	trOn    bool
+	key0 uint8
+	vel0 uint8
+	key1 uint8
+	vel1 uint8
 
-	stepSize         uint8
-	midiPortModifier bool
+	gates [numChannels]bool
 
-	svc *Service
-	ui  UI
+	stepSize uint8
+	portMode bool
 }
 
-func NewController(svc *Service, ui UI) (*Controller, error) {
-	c := &Controller{
+func (s *state) snapshot(port string) State {
+	mode := ModeStep
+	if s.portMode {
+		mode = ModePort
+	}
+
+	return State{
+		Port:     port,
+		StepSize: s.stepSize,
+		Mode:     mode,
+		CCs:      [4]uint8{s.key0, s.vel0, s.key1, s.vel1},
+		Gates:    s.gates,
+	}
+}
+
+func newState() state {
+	return state{
+		key0:     63,
+		vel0:     63,
+		key1:     63,
+		vel1:     63,
 		stepSize: 8,
-		svc:      svc,
-		ui:       ui,
 	}
+}
 
-	go c.loop()
+// NewController starts the controller's loop, which runs until ctx is cancelled or Close is called.
+// Menus, e.g. for choosing the MIDI port, are opened on the given navigator.
+func NewController(ctx context.Context, svc *Service, ui UI, menus *menu.Navigator) (*Controller, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	c := &Controller{
+		svc:     svc,
+		ui:      ui,
+		menus:   menus,
+		actions: make(chan request),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	go c.loop(ctx)
 
 	return c, nil
 }
 
+// Close stops the loop and waits for it to exit. It does not close the Service.
+func (c *Controller) Close() error {
+	c.cancel()
+	<-c.done
+
+	return nil
+}
+
 // input -32k to 32k, output 0 to 127 where -32k is 0 and 32k is 127
 func step(prev uint8, v int32, max uint8) uint8 {
 	step := int8(float64(v) / 32767.0 * float64(max))
@@ -208,176 +409,254 @@ func step(prev uint8, v int32, max uint8) uint8 {
 	}
 }
 
-func (c *Controller) loop() {
+const (
+	stepInterval = 100 * time.Millisecond
+	minBackoff   = 250 * time.Millisecond
+	maxBackoff   = 8 * time.Second
+)
+
+func (c *Controller) loop(ctx context.Context) {
+	defer close(c.done)
+
+	st := newState()
+
+	ticker := time.NewTicker(stepInterval)
+	defer ticker.Stop()
+
 	var (
-		key0 uint8 = 63
-		vel0 uint8 = 63
-		key1 uint8 = 63
-		vel1 uint8 = 63
+		retry     <-chan time.Time // non-nil while the port is broken
+		backoff   = minBackoff
+		published State
 	)
 
-	const stepInterval = 100 * time.Millisecond
+	publish := func() {
+		next := st.snapshot(c.svc.PortName())
 
-	ticker := time.NewTicker(stepInterval)
+		if next != published {
+			published = next
+
+			c.ui.SendMIDIState(next)
+		}
+	}
+
+	publish()
 
 	for {
-		<-ticker.C
+		select {
+		case <-ctx.Done():
+			return
+
+		case req := <-c.actions:
+			if req.open != nil {
+				req.errc <- req.open()
+			} else {
+				req.errc <- c.handleAction(&st, req.action)
+			}
 
-		key0 = step(key0, c.x, c.stepSize)
-		vel0 = step(vel0, c.y, c.stepSize)
-		key1 = step(key1, c.rx, c.stepSize)
-		vel1 = step(vel1, c.ry, c.stepSize)
+			publish()
 
-		err := c.svc.Send(key0, vel0, key1, vel1)
-		if err != nil {
-			log.Fatalf("error: %v", err)
+		case <-ticker.C:
+			if retry != nil {
+				continue
+			}
+
+			st.step()
+
+			err := c.svc.Send(st.key0, st.vel0, st.key1, st.vel1)
+			if err != nil {
+				zap.S().Errorw("failed to send MIDI CCs", "error", err)
+				c.ui.SendError(fmt.Sprintf("MIDI error: %v", err))
+
+				retry = time.After(backoff)
+			}
+
+			publish()
+
+		case <-retry:
+			err := c.svc.reopenPort()
+			if err != nil {
+				zap.S().Warnw("failed to reopen MIDI port", "error", err, "retryIn", backoff)
+
+				backoff = min(backoff*2, maxBackoff)
+				retry = time.After(backoff)
+
+				publish()
+
+				continue
+			}
+
+			c.ui.SendText("MIDI port reopened")
+
+			retry = nil
+			backoff = minBackoff
+
+			publish()
 		}
 	}
 }
 
-func (c *Controller) setStepSize(v uint8) {
+func (s *state) step() {
+	s.key0 = step(s.key0, s.x, s.stepSize)
+	s.vel0 = step(s.vel0, s.y, s.stepSize)
+	s.key1 = step(s.key1, s.rx, s.stepSize)
+	s.vel1 = step(s.vel1, s.ry, s.stepSize)
+}
+
+func (s *state) setStepSize(v uint8) {
 	if v < 1 {
 		v = 1
 	} else if v > 127 {
 		v = 127
 	}
 
-	c.stepSize = v
-
-	fmt.Println("stepSize:", c.stepSize)
+	s.stepSize = v
 }
 
-func (c *Controller) incStepSize() {
-	inc := c.stepSize / 3
+func (s *state) incStepSize() {
+	inc := s.stepSize / 3
 
 	if inc == 0 {
 		inc = 1
 	}
 
-	c.setStepSize(c.stepSize + inc)
+	s.setStepSize(s.stepSize + inc)
 }
 
-func (c *Controller) decStepSize() {
-	dec := c.stepSize / 4
+func (s *state) decStepSize() {
+	dec := s.stepSize / 4
 
 	if dec == 0 {
 		dec = 1
 	}
 
-	c.setStepSize(c.stepSize - dec)
+	s.setStepSize(s.stepSize - dec)
 }
 
-func (c *Controller) HandleEvent(event *evdev.EventEnvelope) error {
-	if event.Type == evdev.AbsoluteX {
-		c.x = event.Value
-	} else if event.Type == evdev.AbsoluteY {
-		c.y = event.Value
-	} else if event.Type == evdev.AbsoluteRX {
-		c.rx = event.Value
-	} else if event.Type == evdev.AbsoluteRY {
-		c.ry = event.Value
-	} else if event.Type == evdev.BtnSelect && event.Value == 1 {
-		if c.midiPortModifier {
-			err := c.svc.previousPort()
-			if err != nil {
-				return fmt.Errorf("failed to change MIDI port: %w", err)
-			}
-		} else {
-			c.decStepSize()
+// portMenu lists the default and all other MIDI ports, with the open one selected.
+// The items open their port on the loop, which is the only goroutine touching the Service's port.
+func (c *Controller) portMenu() menu.Menu {
+	m := menu.Menu{
+		Title: "MIDI port",
+		Items: []menu.Item{
+			{Label: "default", Action: func() error {
+				return c.send(request{open: c.svc.openDefaultPort})
+			}},
+		},
+	}
+
+	current := c.svc.PortName()
+
+	for i, name := range c.svc.Ports() {
+		if name == current {
+			m.Current = len(m.Items)
 		}
-	} else if event.Type == evdev.BtnStart && event.Value == 1 {
-		if c.midiPortModifier {
-			err := c.svc.nextPort()
-			if err != nil {
-				return fmt.Errorf("failed to change MIDI port: %w", err)
-			}
-		} else {
-			c.incStepSize()
+
+		m.Items = append(m.Items, menu.Item{
+			Label: name,
+			Action: func() error {
+				return c.send(request{open: func() error {
+					return c.svc.openPort(i)
+				}})
+			},
+		})
+	}
+
+	return m
+}
+
+// Handle passes the action to the controller's loop and waits until it has been handled.
+// Actions for other services are ignored.
+func (c *Controller) Handle(a bus.Action) error {
+	return c.send(request{action: a})
+}
+
+// send passes req to the loop and waits for its result.
+func (c *Controller) send(req request) error {
+	errc := make(chan error, 1)
+	req.errc = errc
+
+	select {
+	case c.actions <- req:
+	case <-c.done:
+		return errClosed
+	}
+
+	return <-errc
+}
+
+func (c *Controller) handleAction(st *state, a bus.Action) error {
+	switch a := a.(type) {
+	case bus.MoveCC:
+		switch a.CC {
+		case 0:
+			st.x = a.Speed
+		case 1:
+			st.y = a.Speed
+		case 2:
+			st.rx = a.Speed
+		case 3:
+			st.ry = a.Speed
+		default:
+			return fmt.Errorf("no CC %d", a.CC)
 		}
-	} else if event.Type == evdev.BtnZ || event.Type == evdev.AbsoluteZ {
-		c.midiPortModifier = event.Value != 0
-	} else if event.Type == evdev.BtnMode && event.Value != 0 {
-		err := c.svc.openDefaultPort()
-		if err != nil {
-			return fmt.Errorf("failed to open default MIDI port: %w", err)
+
+	case bus.SetCC:
+		v := min(a.Value, 127)
+
+		switch a.CC {
+		case 0:
+			st.key0 = v
+		case 1:
+			st.vel0 = v
+		case 2:
+			st.key1 = v
+		case 3:
+			st.vel1 = v
+		default:
+			return fmt.Errorf("no CC %d", a.CC)
 		}
-	}
 
-	// gates
+	case bus.Gate:
+		return c.setGate(st, a.Channel, a.On)
 
-	ch, ok := map[any]uint8{
-		evdev.BtnA:       5,
-		evdev.BtnB:       4,
-		evdev.BtnX:       7,
-		evdev.BtnY:       6,
-		evdev.BtnTL2:     14,
-		evdev.BtnTR2:     15,
-		evdev.AbsoluteZ:  14, // 8bitdo TL2
-		evdev.AbsoluteRZ: 15, // 8bitdo TR2
-	}[event.Type]
+	case bus.ToggleGate:
+		if int(a.Channel) >= numChannels {
+			return fmt.Errorf("no MIDI channel %d", a.Channel)
+		}
+
+		return c.setGate(st, a.Channel, !st.gates[a.Channel])
 
-	on := event.Value == 1
+	case bus.IncStepSize:
+		st.incStepSize()
 
-	// toggles
+	case bus.DecStepSize:
+		st.decStepSize()
 
-	if !ok {
-		if event.Value == 0 {
-			return nil
+	case bus.PrevPort:
+		err := c.svc.previousPort()
+		if err != nil {
+			return fmt.Errorf("failed to change MIDI port: %w", err)
 		}
 
-		if event.Type == evdev.KeyType(544) { // JoyCon D-Pad
-			ch = 8
-			c.upOn = !c.upOn
-			on = c.upOn
-		} else if event.Type == evdev.KeyType(546) {
-			ch = 9
-			c.leftOn = !c.leftOn
-			on = c.leftOn
-		} else if event.Type == evdev.KeyType(547) {
-			ch = 10
-			c.rightOn = !c.rightOn
-			on = c.rightOn
-		} else if event.Type == evdev.KeyType(545) {
-			ch = 11
-			c.downOn = !c.downOn
-			on = c.downOn
-		} else if event.Type == evdev.BtnTL { // triggers
-			ch = 12
-			c.tlOn = !c.tlOn
-			on = c.tlOn
-		} else if event.Type == evdev.BtnTR {
-			ch = 13
-			c.trOn = !c.trOn
-			on = c.trOn
-		} else if event.Type == evdev.AbsoluteHat0Y { // Switch Pro D-Pad
-			if event.Value < 0 {
-				ch = 8
-				c.upOn = !c.upOn
-				on = c.upOn
-			} else if event.Value > 0 {
-				ch = 11
-				c.downOn = !c.downOn
-				on = c.downOn
-			} else {
-				panic("unexpected value")
-			}
-		} else if event.Type == evdev.AbsoluteHat0X {
-			if event.Value < 0 {
-				ch = 9
-				c.leftOn = !c.leftOn
-				on = c.leftOn
-			} else if event.Value > 0 {
-				ch = 10
-				c.rightOn = !c.rightOn
-				on = c.rightOn
-			} else {
-				panic("unexpected value")
-			}
+	case bus.NextPort:
+		err := c.svc.nextPort()
+		if err != nil {
+			return fmt.Errorf("failed to change MIDI port: %w", err)
 		}
+
+	case bus.PortMode:
+		st.portMode = a.On
+
+	case bus.PortMenu:
+		c.menus.Open(c.portMenu())
 	}
 
-	if ch == 0 {
-		return nil
+	return nil
+}
+
+func (c *Controller) setGate(st *state, ch uint8, on bool) error {
+	if int(ch) >= numChannels {
+		return fmt.Errorf("no MIDI channel %d", ch)
 	}
 
 	err := c.svc.Gate(ch, on)
@@ -385,5 +664,7 @@ func (c *Controller) HandleEvent(event *evdev.EventEnvelope) error {
 		return fmt.Errorf("failed to set MIDI gate %d to %t: %w", ch, on, err)
 	}
 
+	st.gates[ch] = on
+
 	return nil
 }
@@ -0,0 +1,491 @@
+package midictl
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kenshaw/evdev"
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/drivers"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/input"
+	"github.com/markus-wa/vlc-sampler/features/menu"
+)
+
+type fakeOut struct {
+	name string
+	mu   sync.Mutex
+	msgs []midi.Message
+	err  error
+}
+
+func (o *fakeOut) Open() error  { return nil }
+func (o *fakeOut) Close() error { return nil }
+func (o *fakeOut) IsOpen() bool { return true }
+func (o *fakeOut) Number() int  { return 0 }
+func (o *fakeOut) String() string {
+	if o.name == "" {
+		return "fake"
+	}
+
+	return o.name
+}
+func (o *fakeOut) Underlying() interface{} { return nil }
+
+func (o *fakeOut) Send(data []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.err != nil {
+		return o.err
+	}
+
+	o.msgs = append(o.msgs, midi.Message(data))
+
+	return nil
+}
+
+func (o *fakeOut) messages() []midi.Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]midi.Message(nil), o.msgs...)
+}
+
+type fakeUI struct {
+	mu     sync.Mutex
+	texts  []string
+	states []State
+	menu   *menu.View
+}
+
+func (ui *fakeUI) ShowMenu(v menu.View) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.menu = &v
+}
+
+func (ui *fakeUI) HideMenu() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.menu = nil
+}
+
+func (ui *fakeUI) shownMenu() *menu.View {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	return ui.menu
+}
+
+func (ui *fakeUI) SendError(text string) {
+	ui.SendText(text)
+}
+
+func (ui *fakeUI) SendMIDIState(state State) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.states = append(ui.states, state)
+}
+
+func (ui *fakeUI) lastState() State {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if len(ui.states) == 0 {
+		return State{}
+	}
+
+	return ui.states[len(ui.states)-1]
+}
+
+func (ui *fakeUI) SendText(text string) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.texts = append(ui.texts, text)
+}
+
+func (ui *fakeUI) contains(substr string) bool {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	for _, t := range ui.texts {
+		if strings.Contains(t, substr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newTestController(t *testing.T, out *fakeOut) (*Controller, *fakeUI) {
+	t.Helper()
+
+	ui := &fakeUI{}
+
+	c, err := NewController(context.Background(), &Service{port: out}, ui, menu.NewNavigator(ui))
+	if err != nil {
+		t.Fatalf("NewController: %v", err)
+	}
+
+	t.Cleanup(func() {
+		c.Close()
+	})
+
+	return c, ui
+}
+
+// gamepadFor returns a gamepad publishing to c through a bus.
+func gamepadFor(c *Controller) *input.Mapper {
+	b := bus.New()
+	b.Subscribe(c.Handle)
+
+	return NewGamepad(b)
+}
+
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func ev(typ any, value int32) *evdev.EventEnvelope {
+	return &evdev.EventEnvelope{
+		Type:  typ,
+		Event: evdev.Event{Value: value},
+	}
+}
+
+func TestStep(t *testing.T) {
+	tests := []struct {
+		prev uint8
+		v    int32
+		max  uint8
+		want uint8
+	}{
+		{63, 0, 8, 63},
+		{63, 32767, 8, 71},
+		{63, -32767, 8, 55},
+		{125, 32767, 8, 127},
+		{2, -32767, 8, 0},
+	}
+
+	for _, tt := range tests {
+		got := step(tt.prev, tt.v, tt.max)
+		if got != tt.want {
+			t.Errorf("step(%d, %d, %d) = %d, want %d", tt.prev, tt.v, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestControllerAxisSendsCC(t *testing.T) {
+	out := &fakeOut{}
+	c, _ := newTestController(t, out)
+	pad := gamepadFor(c)
+
+	err := pad.HandleEvent(ev(evdev.AbsoluteX, 32767))
+	if err != nil {
+		t.Fatalf("HandleEvent: %v", err)
+	}
+
+	eventually(t, func() bool {
+		for _, m := range out.messages() {
+			var ch, ctrl, val uint8
+
+			if m.GetControlChange(&ch, &ctrl, &val) && ch == 0 && val > 63 {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+func TestControllerGateToggle(t *testing.T) {
+	out := &fakeOut{}
+	c, _ := newTestController(t, out)
+	pad := gamepadFor(c)
+
+	for _, v := range []int32{1, 0, 1, 0} {
+		err := pad.HandleEvent(ev(evdev.KeyType(544), v))
+		if err != nil {
+			t.Fatalf("HandleEvent: %v", err)
+		}
+	}
+
+	var notes []bool
+
+	for _, m := range out.messages() {
+		var ch, key, vel uint8
+
+		if m.GetNoteStart(&ch, &key, &vel) && ch == 8 {
+			notes = append(notes, true)
+		} else if m.GetNoteEnd(&ch, &key) && ch == 8 {
+			notes = append(notes, false)
+		}
+	}
+
+	if len(notes) != 2 || !notes[0] || notes[1] {
+		t.Fatalf("expected gate 8 on then off, got %v", notes)
+	}
+}
+
+func TestControllerConcurrentEvents(t *testing.T) {
+	out := &fakeOut{}
+	c, _ := newTestController(t, out)
+
+	types := []any{
+		evdev.AbsoluteX,
+		evdev.AbsoluteY,
+		evdev.AbsoluteRX,
+		evdev.AbsoluteRY,
+		evdev.BtnStart,
+		evdev.BtnSelect,
+		evdev.BtnA,
+	}
+
+	var wg sync.WaitGroup
+
+	for i, typ := range types {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			// mappers aren't safe for concurrent use, e.g. av-pi has one per input
+			pad := gamepadFor(c)
+
+			for j := range 50 {
+				err := pad.HandleEvent(ev(typ, int32((i+j)%2)))
+				if err != nil {
+					t.Errorf("HandleEvent: %v", err)
+
+					return
+				}
+
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestControllerSendErrorIsReported(t *testing.T) {
+	out := &fakeOut{err: errors.New("cable unplugged")}
+	c, ui := newTestController(t, out)
+	pad := gamepadFor(c)
+
+	eventually(t, func() bool {
+		return ui.contains("cable unplugged")
+	})
+
+	// the loop keeps handling events while the port is broken
+	err := pad.HandleEvent(ev(evdev.AbsoluteX, 100))
+	if err != nil {
+		t.Fatalf("HandleEvent: %v", err)
+	}
+
+	err = c.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestControllerHandleAfterClose(t *testing.T) {
+	c, _ := newTestController(t, &fakeOut{})
+
+	err := c.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	err = c.Handle(bus.MoveCC{CC: 0, Speed: 100})
+	if !errors.Is(err, errClosed) {
+		t.Fatalf("expected errClosed, got %v", err)
+	}
+}
+
+func TestControllerPublishesState(t *testing.T) {
+	c, ui := newTestController(t, &fakeOut{})
+	pad := gamepadFor(c)
+
+	events := []*evdev.EventEnvelope{
+		ev(evdev.BtnStart, 1),   // step size 8 -> 10
+		ev(evdev.BtnA, 1),       // gate 5 on
+		ev(evdev.BtnTL, 1),      // gate 12 latched
+		ev(evdev.BtnZ, 1),       // port mode
+		ev(evdev.AbsoluteX, 0),  // no-op, must not publish a duplicate
+		ev(evdev.AbsoluteY, 0),  // no-op
+		ev(evdev.AbsoluteRX, 0), // no-op
+	}
+
+	for _, e := range events {
+		err := pad.HandleEvent(e)
+		if err != nil {
+			t.Fatalf("HandleEvent: %v", err)
+		}
+	}
+
+	got := ui.lastState()
+
+	if got.Port != "fake" {
+		t.Errorf("Port = %q, want %q", got.Port, "fake")
+	}
+
+	if got.StepSize != 10 {
+		t.Errorf("StepSize = %d, want 10", got.StepSize)
+	}
+
+	if got.Mode != ModePort {
+		t.Errorf("Mode = %s, want %s", got.Mode, ModePort)
+	}
+
+	if !got.Gates[5] || !got.Gates[12] || got.Gates[4] {
+		t.Errorf("unexpected gates: %v", got.Gates)
+	}
+
+	if got.CCs != [4]uint8{63, 63, 63, 63} {
+		t.Errorf("CCs = %v, want all 63", got.CCs)
+	}
+
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	for i := 1; i < len(ui.states); i++ {
+		if ui.states[i] == ui.states[i-1] {
+			t.Errorf("state %d published twice: %v", i, ui.states[i])
+		}
+	}
+}
+
+func TestStateString(t *testing.T) {
+	s := State{
+		Port:     "CH345",
+		StepSize: 8,
+		CCs:      [4]uint8{1, 2, 3, 4},
+	}
+	s.Gates[4] = true
+	s.Gates[12] = true
+
+	want := "MIDI CH345 | mode step | step size 8 | CC 1 2 3 4 | gates 4,12"
+
+	if got := s.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestControllerModeOpensPortMenu(t *testing.T) {
+	c, ui := newTestController(t, &fakeOut{})
+	pad := gamepadFor(c)
+
+	err := pad.HandleEvent(ev(evdev.BtnMode, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := ui.shownMenu()
+	if m == nil {
+		t.Fatal("no menu shown")
+	}
+
+	if m.Title != "MIDI port" || len(m.Items) == 0 || m.Items[0] != "default" {
+		t.Errorf("unexpected menu %+v", m)
+	}
+}
+
+func TestControllerSetCC(t *testing.T) {
+	c, ui := newTestController(t, &fakeOut{})
+
+	err := c.Handle(bus.SetCC{CC: 2, Value: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ui.lastState().CCs; got != [4]uint8{63, 63, 100, 63} {
+		t.Errorf("CCs = %v", got)
+	}
+
+	err = c.Handle(bus.SetCC{CC: 4, Value: 1})
+	if err == nil {
+		t.Error("expected an error for CC 4")
+	}
+}
+
+type fakeDriver struct {
+	outs []drivers.Out
+}
+
+func (d fakeDriver) Ins() ([]drivers.In, error)   { return nil, nil }
+func (d fakeDriver) Outs() ([]drivers.Out, error) { return d.outs, nil }
+func (d fakeDriver) String() string               { return "fake" }
+func (d fakeDriver) Close() error                 { return nil }
+
+func TestControllerPortMenuWhileSteppingPorts(t *testing.T) {
+	outs := []drivers.Out{&fakeOut{name: "a"}, &fakeOut{name: "b"}, &fakeOut{name: "c"}}
+	drivers.Register(fakeDriver{outs: outs})
+
+	c, _ := newTestController(t, outs[0].(*fakeOut))
+
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		for i := range 50 {
+			var a bus.Action = bus.NextPort{}
+			if i%2 == 0 {
+				a = bus.PrevPort{}
+			}
+
+			err := c.Handle(a)
+			if err != nil {
+				t.Errorf("%s: %v", a, err)
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		for range 50 {
+			err := c.Handle(bus.PortMenu{})
+			if err != nil {
+				t.Errorf("port menu: %v", err)
+			}
+
+			c.menus.Down()
+
+			err = c.menus.Select()
+			if err != nil {
+				t.Errorf("select: %v", err)
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	if name := c.svc.PortName(); !slices.Contains([]string{"a", "b", "c"}, name) {
+		t.Errorf("port %q after switching", name)
+	}
+}
@@ -0,0 +1,194 @@
+// Package modes switches the gamepads between application modes, e.g. controlling the sampler or MIDI.
+package modes
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/kenshaw/evdev"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/input"
+)
+
+// Mode is a named layout, e.g. of the sampler.
+type Mode struct {
+	Name   string
+	Layout input.Config
+}
+
+type UI interface {
+	SendText(string)
+}
+
+// Manager holds the current mode, shared by all devices that aren't pinned to a mode.
+// It is safe for concurrent use.
+type Manager struct {
+	pub    bus.Publisher
+	ui     UI
+	modes  []Mode
+	global []input.Binding
+
+	mu      sync.Mutex
+	current int
+	pins    map[string]int // device name -> mode
+}
+
+// NewManager creates a manager starting in the first mode. The global bindings are added to the base layer
+// of every mode, e.g. a chord to switch modes.
+func NewManager(pub bus.Publisher, ui UI, global []input.Binding, modes ...Mode) (*Manager, error) {
+	if len(modes) == 0 {
+		return nil, errors.New("no modes")
+	}
+
+	return &Manager{
+		pub:    pub,
+		ui:     ui,
+		modes:  modes,
+		global: global,
+		pins:   map[string]int{},
+	}, nil
+}
+
+func (m *Manager) index(name string) (int, error) {
+	i := slices.IndexFunc(m.modes, func(mode Mode) bool {
+		return mode.Name == name
+	})
+	if i < 0 {
+		return 0, fmt.Errorf("unknown mode %q", name)
+	}
+
+	return i, nil
+}
+
+// Pin keeps the device with the given name in mode, whatever the current mode is.
+func (m *Manager) Pin(device, mode string) error {
+	i, err := m.index(mode)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pins[device] = i
+
+	return nil
+}
+
+// Current returns the name of the current mode.
+func (m *Manager) Current() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.modes[m.current].Name
+}
+
+// Handle switches modes on NextMode and SetMode. Other actions are ignored.
+func (m *Manager) Handle(a bus.Action) error {
+	switch a := a.(type) {
+	case bus.NextMode:
+		m.mu.Lock()
+		i := (m.current + 1) % len(m.modes)
+		m.mu.Unlock()
+
+		m.set(i)
+
+	case bus.SetMode:
+		i, err := m.index(a.Name)
+		if err != nil {
+			return err
+		}
+
+		m.set(i)
+	}
+
+	return nil
+}
+
+func (m *Manager) set(i int) {
+	m.mu.Lock()
+	m.current = i
+	m.mu.Unlock()
+
+	m.ui.SendText("mode " + m.modes[i].Name)
+}
+
+func (m *Manager) modeOf(device string) (int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if i, ok := m.pins[device]; ok {
+		return i, true
+	}
+
+	return m.current, false
+}
+
+// Device returns the input for a device with the given name, e.g. evdev's Name().
+// Each device needs its own, they are not safe for concurrent use.
+func (m *Manager) Device(name string) *Device {
+	d := &Device{
+		m:    m,
+		name: name,
+	}
+
+	for _, mode := range m.modes {
+		cfg := mode.Layout
+		cfg.Layers = slices.Clone(cfg.Layers)
+
+		if len(cfg.Layers) == 0 {
+			cfg.Layers = []input.Layer{{Name: mode.Name}}
+		}
+
+		cfg.Layers[0].Bindings = input.Bindings(m.global, cfg.Layers[0].Bindings)
+
+		d.mappers = append(d.mappers, input.NewMapper(cfg, m.pub))
+	}
+
+	i, pinned := m.modeOf(name)
+	d.mode = i
+
+	if pinned {
+		m.ui.SendText(fmt.Sprintf("%s pinned to mode %s", name, m.modes[i].Name))
+	}
+
+	return d
+}
+
+// Device maps the events of one device in its current mode.
+type Device struct {
+	m       *Manager
+	name    string
+	mappers []*input.Mapper
+	mode    int
+}
+
+// sync follows a mode switch. Buttons held in the old mode are released first, so e.g. no gate stays on.
+func (d *Device) sync() error {
+	i, _ := d.m.modeOf(d.name)
+	if i == d.mode {
+		return nil
+	}
+
+	err := d.mappers[d.mode].Reset()
+
+	d.mode = i
+
+	return err
+}
+
+func (d *Device) HandleEvent(event *evdev.EventEnvelope) error {
+	err := d.sync()
+
+	return errors.Join(err, d.mappers[d.mode].HandleEvent(event))
+}
+
+// Tick must be called every input.TickInterval.
+func (d *Device) Tick() error {
+	err := d.sync()
+
+	return errors.Join(err, d.mappers[d.mode].Tick())
+}
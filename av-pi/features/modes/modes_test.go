@@ -0,0 +1,172 @@
+package modes
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kenshaw/evdev"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/input"
+)
+
+type fakeUI struct {
+	texts []string
+}
+
+func (ui *fakeUI) SendText(text string) {
+	ui.texts = append(ui.texts, text)
+}
+
+func ev(typ any, value int32) *evdev.EventEnvelope {
+	return &evdev.EventEnvelope{
+		Type:  typ,
+		Event: evdev.Event{Value: value},
+	}
+}
+
+// recorder is subscribed after the manager, so it sees all actions
+type recorder struct {
+	actions []bus.Action
+}
+
+func (r *recorder) handle(a bus.Action) error {
+	r.actions = append(r.actions, a)
+
+	return nil
+}
+
+func newTestManager(t *testing.T) (*Manager, *fakeUI, *recorder) {
+	t.Helper()
+
+	b := bus.New()
+	ui := &fakeUI{}
+	rec := &recorder{}
+
+	global := []input.Binding{
+		{Buttons: []input.Button{input.Btn(evdev.BtnZ), input.Btn(evdev.BtnMode)}, Action: bus.NextMode{}},
+	}
+
+	m, err := NewManager(b, ui, global,
+		Mode{Name: "sampler", Layout: input.Config{Layers: []input.Layer{{Bindings: input.Bind(input.Press, bus.NextClip{}, input.Btn(evdev.BtnA))}}}},
+		Mode{Name: "midi", Layout: input.Config{Layers: []input.Layer{{Bindings: input.Bindings(
+			input.Bind(input.Down, bus.Gate{Channel: 5, On: true}, input.Btn(evdev.BtnA)),
+			input.Bind(input.Up, bus.Gate{Channel: 5}, input.Btn(evdev.BtnA)),
+		)}}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Subscribe(m.Handle)
+	b.Subscribe(rec.handle)
+
+	return m, ui, rec
+}
+
+func handle(t *testing.T, d *Device, events ...*evdev.EventEnvelope) {
+	t.Helper()
+
+	for _, e := range events {
+		err := d.HandleEvent(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSwitchWithGlobalChord(t *testing.T) {
+	m, ui, rec := newTestManager(t)
+	d := m.Device("pad")
+
+	handle(t, d, ev(evdev.BtnA, 1), ev(evdev.BtnA, 0))
+	handle(t, d, ev(evdev.BtnZ, 1), ev(evdev.BtnMode, 1), ev(evdev.BtnMode, 0), ev(evdev.BtnZ, 0))
+
+	if m.Current() != "midi" {
+		t.Fatalf("mode is %s", m.Current())
+	}
+
+	if !reflect.DeepEqual(ui.texts, []string{"mode midi"}) {
+		t.Errorf("announced %q", ui.texts)
+	}
+
+	handle(t, d, ev(evdev.BtnA, 1))
+
+	want := []bus.Action{bus.NextClip{}, bus.NextMode{}, bus.Gate{Channel: 5, On: true}}
+
+	if !reflect.DeepEqual(rec.actions, want) {
+		t.Errorf("got %v, want %v", rec.actions, want)
+	}
+}
+
+func TestSwitchReleasesHeldButtons(t *testing.T) {
+	m, _, rec := newTestManager(t)
+
+	err := m.Handle(bus.SetMode{Name: "midi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := m.Device("pad")
+
+	handle(t, d, ev(evdev.BtnA, 1))
+
+	err = m.Handle(bus.NextMode{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.Tick()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []bus.Action{bus.Gate{Channel: 5, On: true}, bus.Gate{Channel: 5}}
+
+	if !reflect.DeepEqual(rec.actions, want) {
+		t.Errorf("got %v, want %v", rec.actions, want)
+	}
+}
+
+func TestPin(t *testing.T) {
+	m, ui, rec := newTestManager(t)
+
+	err := m.Pin("8BitDo", "midi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Pin("8BitDo", "sequencer")
+	if err == nil {
+		t.Error("pinned to unknown mode")
+	}
+
+	pinned := m.Device("8BitDo")
+	other := m.Device("JoyCon")
+
+	handle(t, pinned, ev(evdev.BtnA, 1), ev(evdev.BtnA, 0))
+	handle(t, other, ev(evdev.BtnA, 1), ev(evdev.BtnA, 0))
+
+	want := []bus.Action{bus.Gate{Channel: 5, On: true}, bus.Gate{Channel: 5}, bus.NextClip{}}
+
+	if !reflect.DeepEqual(rec.actions, want) {
+		t.Errorf("got %v, want %v", rec.actions, want)
+	}
+
+	if !reflect.DeepEqual(ui.texts, []string{"8BitDo pinned to mode midi"}) {
+		t.Errorf("announced %q", ui.texts)
+	}
+}
+
+func TestSetUnknownMode(t *testing.T) {
+	m, _, _ := newTestManager(t)
+
+	err := m.Handle(bus.SetMode{Name: "sequencer"})
+	if err == nil {
+		t.Error("expected an error")
+	}
+
+	if m.Current() != "sampler" {
+		t.Errorf("mode is %s", m.Current())
+	}
+}
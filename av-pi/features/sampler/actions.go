@@ -0,0 +1,152 @@
+package sampler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/sampler/state"
+)
+
+// Handle performs sampler actions, whichever input they came from. Other actions are ignored.
+func (c *Controller) Handle(a bus.Action) error {
+	var err error
+
+	switch a := a.(type) {
+	case bus.PrevClip:
+		err = c.sampler.Previous()
+	case bus.NextClip:
+		err = c.sampler.Next()
+	case bus.PrevPlaylist:
+		err = c.sampler.PreviousPlaylist()
+	case bus.NextPlaylist:
+		err = c.sampler.NextPlaylist()
+	case bus.TogglePlay:
+		err = c.sampler.TogglePlayPause()
+	case bus.ToggleRecord:
+		err = c.sampler.ToggleRecording()
+	case bus.ToggleMode:
+		err = c.sampler.ToggleMode()
+	case bus.RecordingsMenu:
+		err = c.openRecordingsMenu()
+	case bus.ToggleDeck:
+		c.ui.SendText(fmt.Sprintf("deck %s", c.sampler.ToggleDeck()))
+	case bus.Take:
+		c.sampler.Take()
+	case bus.NextTransition:
+		c.ui.SendText(fmt.Sprintf("transition %s", c.sampler.NextTransition()))
+	case bus.Crossfade:
+		c.sampler.SetCrossfader(a.Position)
+	case bus.MoveCrossfader:
+		c.sampler.MoveCrossfader(a.Speed)
+	case bus.SetIn:
+		err = c.sampler.SetIn()
+	case bus.SetOut:
+		err = c.sampler.SetOut()
+	case bus.ToggleLoop:
+		var looping bool
+
+		looping, err = c.sampler.ToggleLoop()
+		if err == nil && !looping {
+			c.ui.SendText("loop off")
+		}
+	case bus.ClearLoop:
+		err = c.sampler.ClearLoop()
+	case bus.Stutter:
+		err = c.sampler.Stutter()
+	case bus.LoopBeats:
+		err = c.sampler.LoopBeats(a.Beats)
+	case bus.SetRate:
+		err = c.sampler.SetRate(a.Rate)
+	case bus.Jog:
+		c.sampler.Jog(a.Speed)
+	case bus.ToggleFreeze:
+		err = c.sampler.ToggleFreeze()
+	case bus.SetCue:
+		err = c.sampler.SetCue(a.N)
+		if err == nil {
+			c.ui.SendText(fmt.Sprintf("cue %d set", a.N))
+		}
+	case bus.JumpToCue:
+		err = c.sampler.JumpToCue(a.N)
+	case bus.DeleteCue:
+		err = c.sampler.DeleteCue(a.N)
+	case bus.SetTempo:
+		err = c.sampler.SetTempo(a.BPM)
+		if err == nil {
+			c.ui.SendText(fmt.Sprintf("tempo %g BPM", a.BPM))
+		}
+	case bus.SetEffect:
+		err = c.sampler.SetEffect(a.Param, a.Value)
+	case bus.MoveEffect:
+		err = c.sampler.MoveEffect(a.Param, a.Speed)
+	case bus.ToggleFilter:
+		var on bool
+
+		on, err = c.sampler.ToggleFilter(a.Filter)
+		if err == nil && on {
+			c.ui.SendText(a.Filter + " on")
+		} else if err == nil {
+			c.ui.SendText(a.Filter + " off")
+		}
+	case bus.ResetEffects:
+		c.sampler.ResetEffects()
+		c.ui.SendText("effects off")
+	case bus.SetParam:
+		err = c.sampler.SetParam(a.Name, a.Value)
+	case bus.SelectClip:
+		err = c.sampler.SelectClip(a.N)
+	case bus.SelectPlaylist:
+		err = c.sampler.SelectPlaylist(a.N)
+	case bus.TapTempo:
+		if bpm, ok := c.sampler.TapTempo(); ok {
+			c.ui.SendText(fmt.Sprintf("tempo %g BPM", bpm))
+		}
+	case bus.SetQuantize:
+		err = c.sampler.SetQuantize(a.Mode)
+	case bus.NextQuantize:
+		c.ui.SendText("quantize " + c.sampler.NextQuantize().String())
+	case bus.ClockBeat:
+		c.sampler.ClockBeat()
+	case bus.ClockStart:
+		c.sampler.ClockStart()
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", a, err)
+	}
+
+	return nil
+}
+
+// StateUI is a UI that shows the sampler's state.
+type StateUI interface {
+	SendSamplerState(state.Sampler)
+}
+
+// PublishState sends the sampler's state to ui whenever it changes, which includes the clip advancing on its own.
+// It blocks until ctx is done.
+func PublishState(ctx context.Context, s *Sampler, ui StateUI) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	var last state.Sampler
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		st := s.State()
+
+		if !reflect.DeepEqual(st, last) {
+			last = st
+
+			ui.SendSamplerState(st)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package cues keeps the hot cues of clips in a sidecar file next to the playlists, so a set keeps its cues
+// across restarts.
+package cues
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/markus-wa/vlc-sampler/features/atomicfile"
+)
+
+const (
+	// Count is how many hot cues a clip has, numbered from 1.
+	Count = 4
+
+	// FileName is the name of the sidecar file in the playlist directory.
+	FileName = "cues.json"
+)
+
+// Duration is a time.Duration stored as e.g. "1m2.5s", so the file can be edited by hand.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+type file struct {
+	// Clips maps the location of a clip, e.g. file:///home/pi/Videos/loop.mp4, to its cues by number.
+	Clips map[string]map[int]Duration `json:"clips"`
+}
+
+func (f file) clone() file {
+	clips := make(map[string]map[int]Duration, len(f.Clips))
+
+	for clip, cues := range f.Clips {
+		clips[clip] = maps.Clone(cues)
+	}
+
+	return file{Clips: clips}
+}
+
+// Store holds the cues of all clips. Every change is written to the file. It is safe for concurrent use,
+// the file is written without holding up readers.
+type Store struct {
+	path string
+	// saveMu is held while changing and saving, so the saves are in the order of the changes
+	saveMu sync.Mutex
+
+	mu sync.Mutex
+	f  file
+}
+
+// Load reads the cues from path. A missing file is an empty store, it is created on the first change.
+func Load(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+		f:    file{Clips: map[string]map[int]Duration{}},
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read cues: %w", err)
+	}
+
+	err = json.Unmarshal(b, &s.f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cues %s: %w", path, err)
+	}
+
+	if s.f.Clips == nil {
+		s.f.Clips = map[string]map[int]Duration{}
+	}
+
+	return s, nil
+}
+
+func checkCue(n int) error {
+	if n < 1 || n > Count {
+		return fmt.Errorf("cue %d doesn't exist, there are %d", n, Count)
+	}
+
+	return nil
+}
+
+// Get returns where cue n of clip is, if it is set.
+func (s *Store) Get(clip string, n int) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.f.Clips[clip][n]
+
+	return time.Duration(t), ok
+}
+
+// Set puts cue n of clip at t.
+func (s *Store) Set(clip string, n int, t time.Duration) error {
+	err := checkCue(n)
+	if err != nil {
+		return err
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+
+	if s.f.Clips[clip] == nil {
+		s.f.Clips[clip] = map[int]Duration{}
+	}
+
+	s.f.Clips[clip][n] = Duration(t)
+	f := s.f.clone()
+
+	s.mu.Unlock()
+
+	return s.save(f)
+}
+
+// Delete removes cue n of clip.
+func (s *Store) Delete(clip string, n int) error {
+	err := checkCue(n)
+	if err != nil {
+		return err
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+
+	if _, ok := s.f.Clips[clip][n]; !ok {
+		s.mu.Unlock()
+
+		return nil
+	}
+
+	delete(s.f.Clips[clip], n)
+
+	if len(s.f.Clips[clip]) == 0 {
+		delete(s.f.Clips, clip)
+	}
+
+	f := s.f.clone()
+
+	s.mu.Unlock()
+
+	return s.save(f)
+}
+
+// IsSet returns which cues of clip are set, cue n at index n-1.
+func (s *Store) IsSet(clip string) [Count]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var set [Count]bool
+
+	for n := range s.f.Clips[clip] {
+		if n >= 1 && n <= Count {
+			set[n-1] = true
+		}
+	}
+
+	return set
+}
+
+// save writes f, a copy of the cues taken while holding mu. The caller holds saveMu.
+func (s *Store) save(f file) error {
+	err := atomicfile.WriteJSON(s.path, f)
+	if err != nil {
+		return fmt.Errorf("failed to save cues: %w", err)
+	}
+
+	return nil
+}
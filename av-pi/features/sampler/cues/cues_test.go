@@ -0,0 +1,123 @@
+package cues
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const clip = "file:///home/pi/Videos/loop.mp4"
+
+func TestPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := s.Get(clip, 1); ok {
+		t.Fatal("empty store has a cue")
+	}
+
+	for n, at := range map[int]time.Duration{1: 1500 * time.Millisecond, 3: time.Minute + 2*time.Second} {
+		err = s.Set(clip, n, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = s.Delete(clip, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if at, ok := s.Get(clip, 3); !ok || at != time.Minute+2*time.Second {
+		t.Errorf("cue 3 is %s, %v", at, ok)
+	}
+
+	if s.IsSet(clip) != [Count]bool{false, false, true, false} {
+		t.Errorf("set cues %v", s.IsSet(clip))
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), `"3": "1m2s"`) {
+		t.Errorf("unexpected file:\n%s", b)
+	}
+}
+
+func TestInvalidCue(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{0, Count + 1} {
+		if s.Set(clip, n, time.Second) == nil {
+			t.Errorf("set cue %d", n)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	err := os.WriteFile(path, []byte(`{"clips": {"a": {"1": "soon"}}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Load(path)
+	if err == nil {
+		t.Error("loaded an invalid file")
+	}
+}
+
+func TestConcurrentSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for n := 1; n <= Count; n++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := s.Set(clip, n, time.Duration(n)*time.Second)
+			if err != nil {
+				t.Error(err)
+			}
+
+			s.IsSet(clip)
+		}()
+	}
+
+	wg.Wait()
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := loaded.IsSet(clip); got != [Count]bool{true, true, true, true} {
+		t.Errorf("saved cues %v, want all", got)
+	}
+}
@@ -0,0 +1,319 @@
+package sampler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"sync"
+	"time"
+
+	vlc "github.com/adrg/libvlc-go/v3"
+	"go.uber.org/zap"
+
+	"github.com/markus-wa/vlc-sampler/features/sampler/fx"
+	"github.com/markus-wa/vlc-sampler/features/sampler/mixer"
+)
+
+// deck is one of the sampler's two players, with its own mode and playlist position.
+type deck struct {
+	listPlayer *vlc.ListPlayer
+
+	// guarded by the sampler's mu
+	mode             Mode
+	currentListIndex int
+	missing          int      // clips of the playlist that don't exist
+	clips            []string // names of the playlist's clips, in the order of the media list
+
+	// mu guards jog and the effects, which the controllers change and runDecks applies
+	mu  sync.Mutex
+	jog float64 // seconds per second, negative backwards
+	fx  *fx.Effects
+
+	// applied is what mix set last, only runDecks uses it
+	applied applied
+}
+
+type applied struct {
+	adjust [fx.ParamMax]float64
+	volume int
+	// clip is the location of the clip the filters and zoom were set for
+	clip    string
+	filters fx.Filter
+	zoom    float64
+}
+
+func newDeck() (*deck, error) {
+	listPlayer, err := vlc.NewListPlayer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create listPlayer: %w", err)
+	}
+
+	err = listPlayer.SetPlaybackMode(vlc.Loop)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set playback mode: %w", err)
+	}
+
+	pl, err := listPlayer.Player()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get player: %w", err)
+	}
+
+	// the crossfader dims the video with the brightness adjustment
+	err = pl.EnableVideoAdjustments(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable video adjustments: %w", err)
+	}
+
+	return &deck{
+		listPlayer: listPlayer,
+		fx:         fx.New(),
+		applied: applied{
+			volume: -1,
+			zoom:   1,
+		},
+	}, nil
+}
+
+func (d *deck) player() (*vlc.Player, error) {
+	p, err := d.listPlayer.Player()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get player: %w", err)
+	}
+
+	return p, nil
+}
+
+// setVolume sets the volume from the crossfader's level, from 0 (off) to 1 (unchanged).
+func (d *deck) setVolume(level float64) error {
+	volume := int(math.Round(level * 100))
+	if volume == d.applied.volume {
+		return nil
+	}
+
+	p, err := d.player()
+	if err != nil {
+		return err
+	}
+
+	err = p.SetVolume(volume)
+	if err != nil {
+		return fmt.Errorf("failed to set volume: %w", err)
+	}
+
+	d.applied.volume = volume
+
+	return nil
+}
+
+// raise puts the deck's video window in front of the other deck's by making it full screen again.
+func (d *deck) raise() error {
+	p, err := d.player()
+	if err != nil {
+		return err
+	}
+
+	err = p.SetFullScreen(false)
+	if err != nil {
+		return fmt.Errorf("failed to leave fullscreen: %w", err)
+	}
+
+	err = p.SetFullScreen(true)
+	if err != nil {
+		return fmt.Errorf("failed to set fullscreen: %w", err)
+	}
+
+	return nil
+}
+
+// clip returns the name of what is playing, its title or else its file name.
+func (d *deck) clip() (string, error) {
+	p, err := d.player()
+	if err != nil {
+		return "", err
+	}
+
+	m, err := p.Media()
+	if err != nil {
+		return "", fmt.Errorf("failed to get media: %w", err)
+	}
+
+	title, err := m.Meta(vlc.MediaTitle)
+	if err == nil && title != "" {
+		return title, nil
+	}
+
+	loc, err := m.Location()
+	if err != nil {
+		return "", fmt.Errorf("failed to get media location: %w", err)
+	}
+
+	return path.Base(loc), nil
+}
+
+// clipIndex returns the index of what is playing in the deck's media list.
+func (d *deck) clipIndex() (int, error) {
+	p, err := d.player()
+	if err != nil {
+		return 0, err
+	}
+
+	m, err := p.Media()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get media: %w", err)
+	}
+
+	i, err := d.listPlayer.MediaList().IndexOfMedia(m)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get index of media: %w", err)
+	}
+
+	return i, nil
+}
+
+// frozen reports whether the deck holds a frame, i.e. is paused rather than stopped.
+func (d *deck) frozen() bool {
+	p, err := d.player()
+	if err != nil {
+		return false
+	}
+
+	st, err := p.MediaState()
+
+	return err == nil && st == vlc.MediaPaused
+}
+
+func (d *deck) close() error {
+	err := d.listPlayer.Stop()
+	if err != nil {
+		return fmt.Errorf("failed to stop listPlayer: %w", err)
+	}
+
+	err = d.listPlayer.Release()
+	if err != nil {
+		return fmt.Errorf("failed to release listPlayer: %w", err)
+	}
+
+	return nil
+}
+
+// mixInterval is how often the crossfader, the jog and the parameters are applied while they move, about once per frame.
+const mixInterval = 40 * time.Millisecond
+
+// runDecks loops and jogs the decks, mixes them and runs the quantised switches, until the sampler is closed.
+func (s *Sampler) runDecks() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(loopInterval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	lastMix := last
+	onAir := mixer.Deck(-1)
+
+	for {
+		var now time.Time
+
+		select {
+		case <-s.done:
+			return
+		case now = <-ticker.C:
+		}
+
+		s.loopDecks()
+		s.runPending(last, now)
+
+		last = now
+
+		elapsed := now.Sub(lastMix)
+		if elapsed < mixInterval {
+			continue
+		}
+
+		lastMix = now
+
+		s.jogDecks(elapsed)
+		s.smoothParams(elapsed)
+		onAir = s.mix(onAir)
+	}
+}
+
+// mix applies the crossfader and the effects to the decks and returns the deck on air, raising it if it isn't onAir.
+func (s *Sampler) mix(onAir mixer.Deck) mixer.Deck {
+	s.mixMu.Lock()
+	levels := s.fader.Levels()
+	deck := s.fader.OnAir()
+	s.mixMu.Unlock()
+
+	if deck != onAir {
+		err := s.decks[deck].raise()
+		if err != nil {
+			zap.S().Errorw("failed to put deck on air", "deck", deck, "error", err)
+		}
+	}
+
+	for i, d := range s.decks {
+		err := errors.Join(d.setVolume(levels.Audio[i]), d.applyEffects(levels.Video[i]))
+		if err != nil {
+			zap.S().Debugw("failed to mix deck", "deck", mixer.Deck(i), "error", err)
+		}
+	}
+
+	return deck
+}
+
+// ToggleDeck switches which deck the clip, playlist and mode actions control and returns it.
+func (s *Sampler) ToggleDeck() mixer.Deck {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.selected = s.selected.Other()
+
+	return s.selected
+}
+
+// Take switches the output to the deck that isn't on air, using the current transition.
+func (s *Sampler) Take() {
+	s.mixMu.Lock()
+	defer s.mixMu.Unlock()
+
+	s.fader.Take()
+}
+
+// NextTransition cycles through cut, fade and timed fade and returns the new transition.
+func (s *Sampler) NextTransition() mixer.Transition {
+	s.mixMu.Lock()
+	defer s.mixMu.Unlock()
+
+	return s.fader.NextTransition()
+}
+
+// SetCrossfader moves the crossfader to pos, from 0 (deck A) to 1 (deck B).
+func (s *Sampler) SetCrossfader(pos float64) {
+	s.mixMu.Lock()
+	defer s.mixMu.Unlock()
+
+	s.fader.Set(pos)
+}
+
+// MoveCrossfader sets how fast the crossfader moves, -32767 to 32767 like a stick axis.
+func (s *Sampler) MoveCrossfader(speed int32) {
+	s.mixMu.Lock()
+	defer s.mixMu.Unlock()
+
+	s.fader.Move(speed)
+}
+
+func (s *Sampler) onAir() *deck {
+	s.mixMu.Lock()
+	defer s.mixMu.Unlock()
+
+	return s.decks[s.fader.OnAir()]
+}
+
+func (s *Sampler) selectedDeck() *deck {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.decks[s.selected]
+}
@@ -0,0 +1,184 @@
+package sampler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/markus-wa/vlc-sampler/features/sampler/fx"
+)
+
+// applyEffects sets the adjustments, with the brightness dimmed to the crossfader's level, and restarts the clip
+// with the filters and the zoom when they or the clip changed. Only runDecks calls it.
+func (d *deck) applyEffects(level float64) error {
+	d.mu.Lock()
+	values := d.fx.Values()
+	filters := d.fx.Filters()
+	zooming := d.fx.Moving(fx.Zoom)
+	d.mu.Unlock()
+
+	p, err := d.player()
+	if err != nil {
+		return err
+	}
+
+	adjust := values
+	adjust[fx.Brightness] *= level
+	adjust[fx.Zoom] = 0 // not an adjustment
+
+	if adjust != d.applied.adjust {
+		for _, set := range []struct {
+			param fx.Param
+			f     func(float64) error
+		}{
+			{fx.Brightness, p.SetBrightness},
+			{fx.Contrast, p.SetContrast},
+			{fx.Hue, p.SetHue},
+			{fx.Saturation, p.SetSaturation},
+			{fx.Gamma, p.SetGamma},
+		} {
+			err := set.f(adjust[set.param])
+			if err != nil {
+				return fmt.Errorf("failed to set %s: %w", set.param, err)
+			}
+		}
+
+		d.applied.adjust = adjust
+	}
+
+	// restarting while the zoom moves would stall the video
+	if zooming {
+		return nil
+	}
+
+	m, err := p.Media()
+	if err != nil || m == nil {
+		return nil
+	}
+
+	clip, err := m.Location()
+	if err != nil {
+		return fmt.Errorf("failed to get media location: %w", err)
+	}
+
+	zoom := values[fx.Zoom]
+
+	if clip == d.applied.clip && filters == d.applied.filters && zoom == d.applied.zoom {
+		return nil
+	}
+
+	// a new clip without effects plays as it is
+	if clip != d.applied.clip && filters == 0 && zoom == 1 {
+		d.applied.clip = clip
+
+		return nil
+	}
+
+	width, height, err := p.VideoDimensions()
+	if err != nil {
+		width, height = 0, 0
+	}
+
+	err = d.restart(fx.Options(filters, zoom, width, height))
+	if err != nil {
+		return err
+	}
+
+	d.applied.clip, d.applied.filters, d.applied.zoom = clip, filters, zoom
+
+	return nil
+}
+
+// restart plays the current clip again from where it is, with the media options added.
+func (d *deck) restart(options []string) error {
+	p, err := d.player()
+	if err != nil {
+		return err
+	}
+
+	m, err := p.Media()
+	if err != nil {
+		return fmt.Errorf("failed to get media: %w", err)
+	}
+
+	t, err := p.MediaTime()
+	if err != nil {
+		return fmt.Errorf("failed to get media time: %w", err)
+	}
+
+	err = m.AddOptions(options...)
+	if err != nil {
+		return fmt.Errorf("failed to add options: %w", err)
+	}
+
+	err = d.listPlayer.PlayItem(m)
+	if err != nil {
+		return fmt.Errorf("failed to restart media: %w", err)
+	}
+
+	return d.seek(time.Duration(t) * time.Millisecond)
+}
+
+// effects runs f on the effects of the selected deck.
+func (s *Sampler) effects(f func(e *fx.Effects) error) error {
+	d := s.selectedDeck()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return f(d.fx)
+}
+
+// SetEffect sets an effect parameter of the selected deck, e.g. "hue", within its range.
+func (s *Sampler) SetEffect(name string, v float64) error {
+	p, err := fx.ParseParam(name)
+	if err != nil {
+		return err
+	}
+
+	return s.effects(func(e *fx.Effects) error {
+		e.Set(p, v)
+
+		return nil
+	})
+}
+
+// MoveEffect sets how fast an effect parameter of the selected deck moves, -32767 to 32767 like a stick axis.
+func (s *Sampler) MoveEffect(name string, speed int32) error {
+	p, err := fx.ParseParam(name)
+	if err != nil {
+		return err
+	}
+
+	return s.effects(func(e *fx.Effects) error {
+		e.Move(p, speed)
+
+		return nil
+	})
+}
+
+// ToggleFilter turns a video filter of the selected deck on or off, e.g. "invert", and returns whether it is on.
+func (s *Sampler) ToggleFilter(name string) (bool, error) {
+	f, err := fx.ParseFilter(name)
+	if err != nil {
+		return false, err
+	}
+
+	var on bool
+
+	err = s.effects(func(e *fx.Effects) error {
+		on = e.Toggle(f)
+
+		return nil
+	})
+
+	return on, err
+}
+
+// ResetEffects turns all effects of the selected deck off.
+func (s *Sampler) ResetEffects() {
+	_ = s.effects(func(e *fx.Effects) error {
+		e.Reset()
+
+		return nil
+	})
+}
@@ -0,0 +1,210 @@
+// Package fx holds the video effects of a deck: the adjustments, the filters and the zoom, their ranges and
+// how stick axes move them.
+package fx
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Param is an effect parameter that can be moved with a stick.
+type Param int
+
+const (
+	Brightness Param = iota
+	Contrast
+	Hue
+	Saturation
+	Gamma
+	// Zoom crops the centre of the video, from 1 (no zoom) to 4.
+	Zoom
+	ParamMax
+)
+
+var paramNames = [ParamMax]string{"brightness", "contrast", "hue", "saturation", "gamma", "zoom"}
+
+func (p Param) String() string {
+	if p < 0 || p >= ParamMax {
+		return fmt.Sprintf("Param(%d)", int(p))
+	}
+
+	return paramNames[p]
+}
+
+func ParseParam(s string) (Param, error) {
+	i := slices.Index(paramNames[:], s)
+	if i < 0 {
+		return 0, fmt.Errorf("unknown effect %q, want one of %s", s, strings.Join(paramNames[:], ", "))
+	}
+
+	return Param(i), nil
+}
+
+// Range is what values a parameter takes, the ranges of the adjustments are libvlc's.
+type Range struct {
+	Min, Default, Max float64
+}
+
+var Ranges = [ParamMax]Range{
+	Brightness: {0, 1, 2},
+	Contrast:   {0, 1, 2},
+	Hue:        {-180, 0, 180},
+	Saturation: {0, 1, 3},
+	Gamma:      {0.01, 1, 10},
+	Zoom:       {1, 1, 4},
+}
+
+// Filter is a set of libvlc video filters.
+type Filter int
+
+const (
+	Invert Filter = 1 << iota
+	Posterize
+	Mirror
+	Wave
+	MotionBlur
+	Sharpen
+)
+
+// filterNames are the names of libvlc's filter modules, in the order of the flags.
+var filterNames = []string{"invert", "posterize", "mirror", "wave", "motionblur", "sharpen"}
+
+func (f Filter) String() string {
+	var names []string
+
+	for i, name := range filterNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+
+	return strings.Join(names, ":")
+}
+
+func ParseFilter(s string) (Filter, error) {
+	i := slices.Index(filterNames, s)
+	if i < 0 {
+		return 0, fmt.Errorf("unknown filter %q, want one of %s", s, strings.Join(filterNames, ", "))
+	}
+
+	return 1 << i, nil
+}
+
+// Travel is how long a parameter takes from one end of its range to the other with the stick fully pushed.
+const Travel = 2 * time.Second
+
+// Effects are the effects of one deck. It is not safe for concurrent use.
+type Effects struct {
+	clock func() time.Time
+
+	values  [ParamMax]float64
+	speeds  [ParamMax]float64 // of the range per second
+	movedAt [ParamMax]time.Time
+	filters Filter
+}
+
+func New() *Effects {
+	e := &Effects{
+		clock: time.Now,
+	}
+
+	e.Reset()
+
+	return e
+}
+
+// Reset sets all parameters to their defaults and turns the filters off.
+func (e *Effects) Reset() {
+	for p, r := range Ranges {
+		e.values[p] = r.Default
+		e.speeds[p] = 0
+	}
+
+	e.filters = 0
+}
+
+// Set sets p to v, within its range.
+func (e *Effects) Set(p Param, v float64) {
+	e.update()
+
+	r := Ranges[p]
+	e.values[p] = min(max(v, r.Min), r.Max)
+}
+
+// Move sets how fast p moves, -32767 to 32767 like a stick axis.
+func (e *Effects) Move(p Param, speed int32) {
+	e.update()
+
+	r := Ranges[p]
+	e.speeds[p] = float64(speed) / 32767 * (r.Max - r.Min) / Travel.Seconds()
+	e.movedAt[p] = e.clock()
+}
+
+func (e *Effects) update() {
+	now := e.clock()
+
+	for p, speed := range e.speeds {
+		if speed == 0 {
+			continue
+		}
+
+		r := Ranges[p]
+		e.values[p] = min(max(e.values[p]+speed*now.Sub(e.movedAt[p]).Seconds(), r.Min), r.Max)
+		e.movedAt[p] = now
+	}
+}
+
+// Values returns all parameters now.
+func (e *Effects) Values() [ParamMax]float64 {
+	e.update()
+
+	return e.values
+}
+
+// Moving reports whether p is moved by a stick.
+func (e *Effects) Moving(p Param) bool {
+	return e.speeds[p] != 0
+}
+
+// Toggle turns f on or off and returns whether it is on.
+func (e *Effects) Toggle(f Filter) bool {
+	e.filters ^= f
+
+	return e.filters&f != 0
+}
+
+func (e *Effects) Filters() Filter {
+	return e.filters
+}
+
+// Options returns the media options for the filters and the zoom of a video of the given size.
+// libvlc can't change them while playing, the media has to be restarted with them.
+// The filter option is always set, later options override earlier ones, so this also turns filters off.
+func Options(filters Filter, zoom float64, width, height uint) []string {
+	var (
+		names []string
+		opts  []string
+	)
+
+	if zoom > 1 && width > 0 && height > 0 {
+		// crop the same share on opposite sides, the video is scaled back up to the screen
+		x := int(float64(width) * (1 - 1/zoom) / 2)
+		y := int(float64(height) * (1 - 1/zoom) / 2)
+
+		names = append(names, "croppadd")
+		opts = append(opts,
+			fmt.Sprintf(":croppadd-cropleft=%d", x),
+			fmt.Sprintf(":croppadd-cropright=%d", x),
+			fmt.Sprintf(":croppadd-croptop=%d", y),
+			fmt.Sprintf(":croppadd-cropbottom=%d", y),
+		)
+	}
+
+	if s := filters.String(); s != "" {
+		names = append(names, s)
+	}
+
+	return append([]string{":video-filter=" + strings.Join(names, ":")}, opts...)
+}
@@ -0,0 +1,119 @@
+package fx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/markus-wa/vlc-sampler/internal/testutil"
+)
+
+func newTestEffects() (*Effects, *testutil.Clock) {
+	clock := testutil.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	e := New()
+	e.clock = clock.Now
+
+	return e, clock
+}
+
+func TestMove(t *testing.T) {
+	e, clock := newTestEffects()
+
+	e.Move(Hue, 32767)
+	clock.Advance(Travel / 4)
+
+	if v := e.Values()[Hue]; v != 90 {
+		t.Errorf("hue after a quarter of the travel is %v", v)
+	}
+
+	clock.Advance(Travel)
+
+	if v := e.Values()[Hue]; v != 180 {
+		t.Errorf("hue is %v, want the end of its range", v)
+	}
+
+	e.Move(Hue, 0)
+	clock.Advance(Travel)
+
+	if v := e.Values()[Hue]; v != 180 || e.Moving(Hue) {
+		t.Errorf("hue moved on to %v", v)
+	}
+}
+
+func TestSetClamps(t *testing.T) {
+	e, _ := newTestEffects()
+
+	e.Set(Gamma, 0)
+	e.Set(Zoom, 8)
+
+	v := e.Values()
+
+	if v[Gamma] != 0.01 || v[Zoom] != 4 {
+		t.Errorf("got gamma %v, zoom %v", v[Gamma], v[Zoom])
+	}
+
+	e.Reset()
+
+	if v := e.Values(); v[Gamma] != 1 || v[Zoom] != 1 || v[Saturation] != 1 {
+		t.Errorf("reset to %v", v)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	e, _ := newTestEffects()
+
+	for _, name := range []string{"invert", "wave", "sharpen"} {
+		f, err := ParseFilter(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !e.Toggle(f) {
+			t.Errorf("%s isn't on", name)
+		}
+	}
+
+	if e.Toggle(Wave) {
+		t.Error("wave is still on")
+	}
+
+	if got := e.Filters().String(); got != "invert:sharpen" {
+		t.Errorf("filters are %q", got)
+	}
+
+	_, err := ParseFilter("sepia")
+	if err == nil {
+		t.Error("parsed an unknown filter")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	got := Options(Mirror|Posterize, 2, 1920, 1080)
+	want := []string{
+		":video-filter=croppadd:posterize:mirror",
+		":croppadd-cropleft=480", ":croppadd-cropright=480", ":croppadd-croptop=270", ":croppadd-cropbottom=270",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got := Options(0, 1, 1920, 1080); !reflect.DeepEqual(got, []string{":video-filter="}) {
+		t.Errorf("without effects got %q", got)
+	}
+}
+
+func TestParseParam(t *testing.T) {
+	for p := range ParamMax {
+		got, err := ParseParam(p.String())
+		if err != nil || got != p {
+			t.Errorf("ParseParam(%q) = %v, %v", p, got, err)
+		}
+	}
+
+	_, err := ParseParam("blur")
+	if err == nil {
+		t.Error("parsed an unknown parameter")
+	}
+}
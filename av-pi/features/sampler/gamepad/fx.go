@@ -0,0 +1,58 @@
+package gamepad
+
+import (
+	"github.com/kenshaw/evdev"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/input"
+	"github.com/markus-wa/vlc-sampler/features/sampler/fx"
+)
+
+// FxLayout is the effects layout, it controls the selected deck like the sampler's layout.
+// The left stick moves the hue (left and right) and the saturation (up and down), the right stick the contrast
+// and the brightness. Holding up or down on the D-pad zooms in or out, left and right the gamma.
+// The face buttons and L/R toggle filters, Select turns all effects off and Start switches the deck.
+func FxLayout() input.Config {
+	return input.Config{
+		Layers: []input.Layer{
+			{
+				Name: "fx",
+				Bindings: input.Bindings(
+					hold(fx.Zoom, 32767, input.DpadUp...),
+					hold(fx.Zoom, -32767, input.DpadDown...),
+					hold(fx.Gamma, -32767, input.DpadLeft...),
+					hold(fx.Gamma, 32767, input.DpadRight...),
+					input.Bind(input.Press, bus.ToggleFilter{Filter: "invert"}, input.Btn(evdev.BtnA)),
+					input.Bind(input.Press, bus.ToggleFilter{Filter: "posterize"}, input.Btn(evdev.BtnB)),
+					input.Bind(input.Press, bus.ToggleFilter{Filter: "mirror"}, input.Btn(evdev.BtnX)),
+					input.Bind(input.Press, bus.ToggleFilter{Filter: "wave"}, input.Btn(evdev.BtnY)),
+					input.Bind(input.Press, bus.ToggleFilter{Filter: "motionblur"}, input.Btn(evdev.BtnTL)),
+					input.Bind(input.Press, bus.ToggleFilter{Filter: "sharpen"}, input.Btn(evdev.BtnTR)),
+					input.Bind(input.Press, bus.ResetEffects{}, input.Btn(evdev.BtnSelect)),
+					input.Bind(input.Press, bus.ToggleDeck{}, input.Btn(evdev.BtnStart)),
+				),
+			},
+		},
+		Axes: map[any]func(int32) bus.Action{
+			evdev.AbsoluteX:  moveEffect(fx.Hue, 1),
+			evdev.AbsoluteY:  moveEffect(fx.Saturation, -1),
+			evdev.AbsoluteRX: moveEffect(fx.Contrast, 1),
+			evdev.AbsoluteRY: moveEffect(fx.Brightness, -1),
+		},
+	}
+}
+
+// moveEffect moves p with a stick axis, sign -1 for up to increase it.
+func moveEffect(p fx.Param, sign int32) func(int32) bus.Action {
+	return func(v int32) bus.Action {
+		return bus.MoveEffect{Param: p.String(), Speed: sign * max(v, -32767)}
+	}
+}
+
+// hold moves p while the buttons are held.
+func hold(p fx.Param, speed int32, buttons ...input.Button) []input.Binding {
+	return append(
+		input.Bind(input.Down, bus.MoveEffect{Param: p.String(), Speed: speed}, buttons...),
+		input.Bind(input.Up, bus.MoveEffect{Param: p.String()}, buttons...)...,
+	)
+}
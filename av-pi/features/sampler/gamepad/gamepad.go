@@ -0,0 +1,94 @@
+// Package gamepad binds the buttons of a gamepad to sampler actions.
+package gamepad
+
+import (
+	"github.com/kenshaw/evdev"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/input"
+	"github.com/markus-wa/vlc-sampler/features/sampler/transport"
+)
+
+// Layout is the sampler's layout. Holding Z switches Select/Start from clips to playlists and the face buttons
+// to hot cues: pressing one jumps to its cue, holding it sets the cue.
+// Holding ZR (or the right trigger) switches the face buttons and the D-pad to loops, Start to tap tempo and
+// Select to the quantisation of clip and playlist switches.
+// The left stick sets the playback rate (up and down) and jogs (left and right), ZL freezes the frame.
+// The right stick moves the crossfader between the decks.
+func Layout() input.Config {
+	return input.Config{
+		Layers: []input.Layer{
+			{
+				Name: "clips",
+				Bindings: input.Bindings(
+					input.Bind(input.Press, bus.PrevClip{}, input.Btn(evdev.BtnSelect)),
+					input.Bind(input.Press, bus.NextClip{}, input.Btn(evdev.BtnStart)),
+					input.Bind(input.Press, bus.PrevClip{}, input.DpadLeft...),
+					input.Bind(input.Press, bus.NextClip{}, input.DpadRight...),
+					input.Bind(input.Press, bus.PrevPlaylist{}, input.DpadUp...),
+					input.Bind(input.Press, bus.NextPlaylist{}, input.DpadDown...),
+					input.Bind(input.Press, bus.TogglePlay{}, input.Btn(evdev.BtnA)),
+					input.Bind(input.Press, bus.ToggleRecord{}, input.Btn(evdev.BtnX)),
+					input.Bind(input.Press, bus.ToggleMode{}, input.Btn(evdev.BtnMode)),
+					input.Bind(input.Press, bus.RecordingsMenu{}, input.Btn(evdev.BtnTL)),
+					input.Bind(input.Press, bus.Take{}, input.Btn(evdev.BtnB)),
+					input.Bind(input.Press, bus.ToggleDeck{}, input.Btn(evdev.BtnY)),
+					input.Bind(input.Press, bus.NextTransition{}, input.Btn(evdev.BtnTR)),
+					input.Bind(input.Press, bus.ToggleFreeze{}, input.Btn(evdev.BtnTL2), input.Btn(evdev.AbsoluteZ)),
+				),
+			},
+			{
+				Name:      "playlists",
+				Modifiers: []input.Button{input.Btn(evdev.BtnZ)},
+				Bindings: input.Bindings(
+					input.Bind(input.Press, bus.PrevPlaylist{}, input.Btn(evdev.BtnSelect)),
+					input.Bind(input.Press, bus.NextPlaylist{}, input.Btn(evdev.BtnStart)),
+					hotCue(1, evdev.BtnA),
+					hotCue(2, evdev.BtnB),
+					hotCue(3, evdev.BtnX),
+					hotCue(4, evdev.BtnY),
+				),
+			},
+			{
+				Name:      "loop",
+				Modifiers: []input.Button{input.Btn(evdev.BtnTR2), input.Btn(evdev.AbsoluteRZ)},
+				Bindings: input.Bindings(
+					input.Bind(input.Press, bus.SetIn{}, input.Btn(evdev.BtnA)),
+					input.Bind(input.Press, bus.SetOut{}, input.Btn(evdev.BtnB)),
+					input.Bind(input.Press, bus.ToggleLoop{}, input.Btn(evdev.BtnX)),
+					input.Bind(input.Press, bus.Stutter{}, input.Btn(evdev.BtnY)),
+					input.Bind(input.Press, bus.ClearLoop{}, input.Btn(evdev.BtnTL)),
+					input.Bind(input.Press, bus.LoopBeats{Beats: 1}, input.DpadLeft...),
+					input.Bind(input.Press, bus.LoopBeats{Beats: 2}, input.DpadUp...),
+					input.Bind(input.Press, bus.LoopBeats{Beats: 4}, input.DpadRight...),
+					input.Bind(input.Press, bus.LoopBeats{Beats: 8}, input.DpadDown...),
+					input.Bind(input.Press, bus.TapTempo{}, input.Btn(evdev.BtnStart)),
+					input.Bind(input.Press, bus.NextQuantize{}, input.Btn(evdev.BtnSelect)),
+				),
+			},
+		},
+		Axes: map[any]func(int32) bus.Action{
+			evdev.AbsoluteY: func(v int32) bus.Action {
+				return bus.SetRate{Rate: transport.Rate(v)}
+			},
+			evdev.AbsoluteX: func(v int32) bus.Action {
+				return bus.Jog{Speed: v}
+			},
+			evdev.AbsoluteRX: func(v int32) bus.Action {
+				return bus.MoveCrossfader{Speed: v}
+			},
+		},
+	}
+}
+
+func hotCue(n int, btn evdev.KeyType) []input.Binding {
+	return input.Bindings(
+		input.Bind(input.Press, bus.JumpToCue{N: n}, input.Btn(btn)),
+		input.Bind(input.LongPress, bus.SetCue{N: n}, input.Btn(btn)),
+	)
+}
+
+// New returns a mapper publishing the sampler's actions to pub.
+func New(pub bus.Publisher) *input.Mapper {
+	return input.NewMapper(Layout(), pub)
+}
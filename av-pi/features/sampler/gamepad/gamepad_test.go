@@ -0,0 +1,190 @@
+package gamepad
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kenshaw/evdev"
+
+	"github.com/markus-wa/vlc-sampler/features/bus"
+	"github.com/markus-wa/vlc-sampler/features/input"
+)
+
+type recorder []bus.Action
+
+func (r *recorder) Publish(a bus.Action) error {
+	*r = append(*r, a)
+
+	return nil
+}
+
+func ev(typ any, value int32) *evdev.EventEnvelope {
+	return &evdev.EventEnvelope{
+		Type:  typ,
+		Event: evdev.Event{Value: value},
+	}
+}
+
+func tap(typ any) []*evdev.EventEnvelope {
+	return []*evdev.EventEnvelope{ev(typ, 1), ev(typ, 0)}
+}
+
+func withZ(events ...*evdev.EventEnvelope) []*evdev.EventEnvelope {
+	return append(append([]*evdev.EventEnvelope{ev(evdev.BtnZ, 1)}, events...), ev(evdev.BtnZ, 0))
+}
+
+func withZR(events ...*evdev.EventEnvelope) []*evdev.EventEnvelope {
+	return append(append([]*evdev.EventEnvelope{ev(evdev.BtnTR2, 1)}, events...), ev(evdev.BtnTR2, 0))
+}
+
+func TestBindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []*evdev.EventEnvelope
+		want   bus.Action
+	}{
+		{"select", tap(evdev.BtnSelect), bus.PrevClip{}},
+		{"start", tap(evdev.BtnStart), bus.NextClip{}},
+		{"left", tap(evdev.KeyType(546)), bus.PrevClip{}},
+		{"right", tap(evdev.KeyType(547)), bus.NextClip{}},
+		{"up", tap(evdev.KeyType(544)), bus.PrevPlaylist{}},
+		{"down", tap(evdev.KeyType(545)), bus.NextPlaylist{}},
+		{"hat left", []*evdev.EventEnvelope{ev(evdev.AbsoluteHat0X, -1), ev(evdev.AbsoluteHat0X, 0)}, bus.PrevClip{}},
+		{"hat right", []*evdev.EventEnvelope{ev(evdev.AbsoluteHat0X, 1), ev(evdev.AbsoluteHat0X, 0)}, bus.NextClip{}},
+		{"hat up", []*evdev.EventEnvelope{ev(evdev.AbsoluteHat0Y, -1), ev(evdev.AbsoluteHat0Y, 0)}, bus.PrevPlaylist{}},
+		{"hat down", []*evdev.EventEnvelope{ev(evdev.AbsoluteHat0Y, 1), ev(evdev.AbsoluteHat0Y, 0)}, bus.NextPlaylist{}},
+		{"A", tap(evdev.BtnA), bus.TogglePlay{}},
+		{"X", tap(evdev.BtnX), bus.ToggleRecord{}},
+		{"mode", tap(evdev.BtnMode), bus.ToggleMode{}},
+		{"L", tap(evdev.BtnTL), bus.RecordingsMenu{}},
+		{"B", tap(evdev.BtnB), bus.Take{}},
+		{"Y", tap(evdev.BtnY), bus.ToggleDeck{}},
+		{"R", tap(evdev.BtnTR), bus.NextTransition{}},
+		{"ZL", tap(evdev.BtnTL2), bus.ToggleFreeze{}},
+		{"left trigger", []*evdev.EventEnvelope{ev(evdev.AbsoluteZ, 255), ev(evdev.AbsoluteZ, 0)}, bus.ToggleFreeze{}},
+		{"left stick up", []*evdev.EventEnvelope{ev(evdev.AbsoluteY, -32767)}, bus.SetRate{Rate: 4}},
+		{"left stick centred", []*evdev.EventEnvelope{ev(evdev.AbsoluteY, 300)}, bus.SetRate{Rate: 1}},
+		{"left stick left", []*evdev.EventEnvelope{ev(evdev.AbsoluteX, -32767)}, bus.Jog{Speed: -32767}},
+		{"right stick", []*evdev.EventEnvelope{ev(evdev.AbsoluteRX, -20000)}, bus.MoveCrossfader{Speed: -20000}},
+		{"Z+select", withZ(tap(evdev.BtnSelect)...), bus.PrevPlaylist{}},
+		{"Z+start", withZ(tap(evdev.BtnStart)...), bus.NextPlaylist{}},
+		{"Z+A", withZ(tap(evdev.BtnA)...), bus.JumpToCue{N: 1}},
+		{"Z+Y", withZ(tap(evdev.BtnY)...), bus.JumpToCue{N: 4}},
+		{"ZR+A", withZR(tap(evdev.BtnA)...), bus.SetIn{}},
+		{"ZR+B", withZR(tap(evdev.BtnB)...), bus.SetOut{}},
+		{"ZR+X", withZR(tap(evdev.BtnX)...), bus.ToggleLoop{}},
+		{"ZR+Y", withZR(tap(evdev.BtnY)...), bus.Stutter{}},
+		{"ZR+L", withZR(tap(evdev.BtnTL)...), bus.ClearLoop{}},
+		{"ZR+left", withZR(tap(evdev.KeyType(546))...), bus.LoopBeats{Beats: 1}},
+		{"ZR+down", withZR(tap(evdev.KeyType(545))...), bus.LoopBeats{Beats: 8}},
+		{"ZR+start", withZR(tap(evdev.BtnStart)...), bus.TapTempo{}},
+		{"ZR+select", withZR(tap(evdev.BtnSelect)...), bus.NextQuantize{}},
+		{"trigger+up", []*evdev.EventEnvelope{
+			ev(evdev.AbsoluteRZ, 200), ev(evdev.KeyType(544), 1), ev(evdev.KeyType(544), 0), ev(evdev.AbsoluteRZ, 0),
+		}, bus.LoopBeats{Beats: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got recorder
+
+			g := New(&got)
+
+			for _, e := range tt.events {
+				err := g.HandleEvent(e)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !reflect.DeepEqual([]bus.Action(got), []bus.Action{tt.want}) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnboundDoesNothing(t *testing.T) {
+	var got recorder
+
+	g := New(&got)
+
+	for _, e := range append(withZ(tap(evdev.BtnThumbR)...), tap(evdev.BtnThumbL)...) {
+		err := g.HandleEvent(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(got) > 0 {
+		t.Errorf("published %v", got)
+	}
+}
+
+// TestAllActionsReachable guards against bindings shadowing each other, as Start and Select once did.
+func TestAllActionsReachable(t *testing.T) {
+	bound := map[bus.Action]bool{}
+
+	for _, l := range Layout().Layers {
+		for _, b := range l.Bindings {
+			bound[b.Action] = true
+		}
+	}
+
+	for _, a := range []bus.Action{
+		bus.PrevClip{}, bus.NextClip{}, bus.PrevPlaylist{}, bus.NextPlaylist{},
+		bus.TogglePlay{}, bus.ToggleRecord{}, bus.ToggleMode{}, bus.RecordingsMenu{},
+		bus.Take{}, bus.ToggleDeck{}, bus.NextTransition{},
+		bus.SetIn{}, bus.SetOut{}, bus.ToggleLoop{}, bus.ClearLoop{}, bus.Stutter{}, bus.ToggleFreeze{},
+		bus.JumpToCue{N: 1}, bus.JumpToCue{N: 4}, bus.SetCue{N: 1}, bus.SetCue{N: 4},
+		bus.TapTempo{}, bus.NextQuantize{},
+	} {
+		if !bound[a] {
+			t.Errorf("%s isn't bound", a)
+		}
+	}
+}
+
+func TestFxBindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []*evdev.EventEnvelope
+		want   []bus.Action
+	}{
+		{"A", tap(evdev.BtnA), []bus.Action{bus.ToggleFilter{Filter: "invert"}}},
+		{"R", tap(evdev.BtnTR), []bus.Action{bus.ToggleFilter{Filter: "sharpen"}}},
+		{"select", tap(evdev.BtnSelect), []bus.Action{bus.ResetEffects{}}},
+		{"start", tap(evdev.BtnStart), []bus.Action{bus.ToggleDeck{}}},
+		{"hold up", tap(evdev.KeyType(544)), []bus.Action{
+			bus.MoveEffect{Param: "zoom", Speed: 32767}, bus.MoveEffect{Param: "zoom"},
+		}},
+		{"hold hat left", []*evdev.EventEnvelope{ev(evdev.AbsoluteHat0X, -1), ev(evdev.AbsoluteHat0X, 0)}, []bus.Action{
+			bus.MoveEffect{Param: "gamma", Speed: -32767}, bus.MoveEffect{Param: "gamma"},
+		}},
+		{"left stick up", []*evdev.EventEnvelope{ev(evdev.AbsoluteY, -32768)}, []bus.Action{
+			bus.MoveEffect{Param: "saturation", Speed: 32767},
+		}},
+		{"right stick right", []*evdev.EventEnvelope{ev(evdev.AbsoluteRX, 10000)}, []bus.Action{
+			bus.MoveEffect{Param: "contrast", Speed: 10000},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got recorder
+
+			g := input.NewMapper(FxLayout(), &got)
+
+			for _, e := range tt.events {
+				err := g.HandleEvent(e)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !reflect.DeepEqual([]bus.Action(got), tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
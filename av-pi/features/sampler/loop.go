@@ -0,0 +1,223 @@
+package sampler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/markus-wa/vlc-sampler/features/sampler/loop"
+)
+
+const (
+	loopInterval = 10 * time.Millisecond
+	// loopMargin is how long before the end a clip looping to its end jumps back, before the list player advances.
+	loopMargin = 100 * time.Millisecond
+)
+
+var errNothingPlaying = errors.New("nothing is playing")
+
+// position returns the location of the deck's clip, where it is and how long it is.
+func (d *deck) position() (string, time.Duration, time.Duration, error) {
+	p, err := d.player()
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	m, err := p.Media()
+	if err != nil || m == nil {
+		return "", 0, 0, errNothingPlaying
+	}
+
+	loc, err := m.Location()
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("failed to get media location: %w", err)
+	}
+
+	t, err := p.MediaTime()
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("failed to get media time: %w", err)
+	}
+
+	length, err := p.MediaLength()
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("failed to get media length: %w", err)
+	}
+
+	return loc, time.Duration(t) * time.Millisecond, time.Duration(length) * time.Millisecond, nil
+}
+
+func (d *deck) seek(t time.Duration) error {
+	p, err := d.player()
+	if err != nil {
+		return err
+	}
+
+	err = p.SetMediaTime(int(t.Milliseconds()))
+	if err != nil {
+		return fmt.Errorf("failed to seek to %s: %w", t, err)
+	}
+
+	return nil
+}
+
+// loopDecks jumps back in looping clips.
+func (s *Sampler) loopDecks() {
+	for _, d := range s.decks {
+		loc, t, length, err := d.position()
+		if err != nil {
+			continue
+		}
+
+		to, ok := s.points(loc).Seek(t, length, loopMargin)
+		if !ok {
+			continue
+		}
+
+		err = d.seek(to)
+		if err != nil {
+			zap.S().Debugw("failed to loop", "clip", loc, "error", err)
+		}
+	}
+}
+
+// editPoints changes the points of the selected deck's clip, f gets where the clip is.
+func (s *Sampler) editPoints(f func(p *loop.Points, t time.Duration) error) error {
+	loc, t, _, err := s.selectedDeck().position()
+	if err != nil {
+		return err
+	}
+
+	s.loopMu.Lock()
+	defer s.loopMu.Unlock()
+
+	p := s.loops[loc]
+
+	err = f(&p, t)
+	if err != nil {
+		return err
+	}
+
+	s.loops[loc] = p
+
+	return nil
+}
+
+// SetIn marks where the selected deck's clip is as its in point.
+func (s *Sampler) SetIn() error {
+	return s.editPoints(func(p *loop.Points, t time.Duration) error {
+		p.SetIn(t)
+
+		return nil
+	})
+}
+
+// SetOut marks where the selected deck's clip is as its out point and loops it from the in point.
+func (s *Sampler) SetOut() error {
+	return s.editPoints(func(p *loop.Points, t time.Duration) error {
+		return p.SetOut(t)
+	})
+}
+
+// ToggleLoop loops the selected deck's clip between its points, or to its end without an out point.
+// It returns whether the clip loops now.
+func (s *Sampler) ToggleLoop() (bool, error) {
+	var looping bool
+
+	err := s.editPoints(func(p *loop.Points, _ time.Duration) error {
+		p.Looping = !p.Looping
+		looping = p.Looping
+
+		return nil
+	})
+
+	return looping, err
+}
+
+// LoopBeats loops the given number of beats from the in point of the selected deck's clip, at the tempo.
+func (s *Sampler) LoopBeats(beats int) error {
+	return s.editPoints(func(p *loop.Points, _ time.Duration) error {
+		return p.SetBeats(beats, s.grid.BPM())
+	})
+}
+
+// ClearLoop removes the points of the selected deck's clip.
+func (s *Sampler) ClearLoop() error {
+	return s.editPoints(func(p *loop.Points, _ time.Duration) error {
+		*p = loop.Points{}
+
+		return nil
+	})
+}
+
+// Stutter jumps back to the in point of the selected deck's clip, or its start without one.
+func (s *Sampler) Stutter() error {
+	d := s.selectedDeck()
+
+	loc, _, _, err := d.position()
+	if err != nil {
+		return err
+	}
+
+	return d.seek(s.points(loc).In)
+}
+
+// SetTempo sets the tempo of beat-length loops and quantised switches.
+func (s *Sampler) SetTempo(bpm float64) error {
+	if bpm <= 0 {
+		return fmt.Errorf("invalid tempo %g BPM", bpm)
+	}
+
+	s.loopMu.Lock()
+	defer s.loopMu.Unlock()
+
+	s.grid.SetTempo(bpm)
+
+	return nil
+}
+
+func (s *Sampler) points(loc string) loop.Points {
+	s.loopMu.Lock()
+	defer s.loopMu.Unlock()
+
+	return s.loops[loc]
+}
+
+// SetCue puts hot cue n of the selected deck's clip where it is and saves it.
+func (s *Sampler) SetCue(n int) error {
+	loc, t, _, err := s.selectedDeck().position()
+	if err != nil {
+		return err
+	}
+
+	return s.cues.Set(loc, n, t)
+}
+
+// JumpToCue jumps to hot cue n of the selected deck's clip.
+func (s *Sampler) JumpToCue(n int) error {
+	d := s.selectedDeck()
+
+	loc, _, _, err := d.position()
+	if err != nil {
+		return err
+	}
+
+	t, ok := s.cues.Get(loc, n)
+
+	if !ok {
+		return fmt.Errorf("cue %d isn't set", n)
+	}
+
+	return d.seek(t)
+}
+
+// DeleteCue removes hot cue n of the selected deck's clip.
+func (s *Sampler) DeleteCue(n int) error {
+	loc, _, _, err := s.selectedDeck().position()
+	if err != nil {
+		return err
+	}
+
+	return s.cues.Delete(loc, n)
+}
@@ -0,0 +1,81 @@
+// Package loop keeps the in and out points of clips, decides when a looping clip jumps back and measures
+// loops in beats of the tempo.
+package loop
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const DefaultBPM = 120
+
+var ErrOutBeforeIn = errors.New("out point isn't after the in point")
+
+// Points are the markers of one clip. The in point is also the cue point that Stutter jumps back to.
+type Points struct {
+	In time.Duration
+	// Out is zero for the end of the clip.
+	Out     time.Duration
+	Looping bool
+}
+
+// SetIn marks t as the in point, dropping an out point that is no longer after it.
+func (p *Points) SetIn(t time.Duration) {
+	p.In = t
+
+	if p.Out != 0 && p.Out <= t {
+		p.Out = 0
+		p.Looping = false
+	}
+}
+
+// SetOut marks t as the out point and starts looping the region.
+func (p *Points) SetOut(t time.Duration) error {
+	if t <= p.In {
+		return fmt.Errorf("%w: %s <= %s", ErrOutBeforeIn, t, p.In)
+	}
+
+	p.Out = t
+	p.Looping = true
+
+	return nil
+}
+
+// SetBeats loops the given number of beats from the in point.
+func (p *Points) SetBeats(beats int, bpm float64) error {
+	if beats <= 0 || bpm <= 0 {
+		return fmt.Errorf("invalid loop of %d beats at %g BPM", beats, bpm)
+	}
+
+	return p.SetOut(p.In + Beats(beats, bpm))
+}
+
+// Beats returns how long n beats last at bpm.
+func Beats(n int, bpm float64) time.Duration {
+	return time.Duration(float64(n) * float64(time.Minute) / bpm)
+}
+
+// Seek returns where a clip of the given length that is at t has to jump to, if it has to jump.
+// A looping clip jumps back to the in point when it reaches the out point or the end, which is close
+// enough to the end to be reached between two checks.
+func (p Points) Seek(t, length, margin time.Duration) (time.Duration, bool) {
+	if !p.Looping {
+		return 0, false
+	}
+
+	out := p.Out
+	if out == 0 && length > 0 {
+		out = length - margin
+	}
+
+	if out <= p.In {
+		return 0, false
+	}
+
+	if t >= out || t < p.In {
+		return p.In, true
+	}
+
+	return 0, false
+}
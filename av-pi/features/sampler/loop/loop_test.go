@@ -0,0 +1,101 @@
+package loop
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const margin = 50 * time.Millisecond
+
+func TestSeek(t *testing.T) {
+	p := Points{In: 2 * time.Second}
+
+	if _, ok := p.Seek(10*time.Second, 10*time.Second, margin); ok {
+		t.Error("jumped without looping")
+	}
+
+	err := p.SetOut(4 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		t    time.Duration
+		jump bool
+	}{
+		{3 * time.Second, false},
+		{4 * time.Second, true},
+		{5 * time.Second, true},
+		{time.Second, true},
+	} {
+		to, ok := p.Seek(tt.t, 10*time.Second, margin)
+		if ok != tt.jump || (ok && to != p.In) {
+			t.Errorf("at %s got %s, %v", tt.t, to, ok)
+		}
+	}
+}
+
+func TestSeekToEnd(t *testing.T) {
+	p := Points{In: time.Second, Looping: true}
+
+	if _, ok := p.Seek(9900*time.Millisecond, 10*time.Second, margin); ok {
+		t.Error("jumped before the end")
+	}
+
+	if to, ok := p.Seek(9960*time.Millisecond, 10*time.Second, margin); !ok || to != time.Second {
+		t.Errorf("at the end got %s, %v", to, ok)
+	}
+
+	// the length of streams is unknown
+	if _, ok := p.Seek(time.Hour, 0, margin); ok {
+		t.Error("jumped in a stream")
+	}
+}
+
+func TestSetOutBeforeIn(t *testing.T) {
+	p := Points{In: 2 * time.Second}
+
+	err := p.SetOut(time.Second)
+	if !errors.Is(err, ErrOutBeforeIn) {
+		t.Errorf("got %v", err)
+	}
+
+	if p.Looping {
+		t.Error("looping")
+	}
+}
+
+func TestSetInDropsOut(t *testing.T) {
+	p := Points{In: time.Second, Out: 2 * time.Second, Looping: true}
+
+	p.SetIn(1500 * time.Millisecond)
+
+	if p.Out != 2*time.Second || !p.Looping {
+		t.Errorf("in before out changed the loop: %+v", p)
+	}
+
+	p.SetIn(3 * time.Second)
+
+	if p.Out != 0 || p.Looping {
+		t.Errorf("in after out kept the loop: %+v", p)
+	}
+}
+
+func TestSetBeats(t *testing.T) {
+	p := Points{In: time.Second}
+
+	err := p.SetBeats(4, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Out != 3*time.Second || !p.Looping {
+		t.Errorf("got %+v", p)
+	}
+
+	err = p.SetBeats(0, 120)
+	if err == nil {
+		t.Error("looped 0 beats")
+	}
+}
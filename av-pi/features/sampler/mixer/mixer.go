@@ -0,0 +1,232 @@
+// Package mixer is the crossfader between the sampler's two decks: its position, the cut, fade and timed
+// fade transitions and the audio and video levels of each deck.
+package mixer
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Deck is one of the sampler's two players.
+type Deck int
+
+const (
+	A Deck = iota
+	B
+)
+
+func (d Deck) String() string {
+	switch d {
+	case A:
+		return "A"
+	case B:
+		return "B"
+	default:
+		return fmt.Sprintf("Deck(%d)", int(d))
+	}
+}
+
+func (d Deck) Other() Deck {
+	return 1 - d
+}
+
+// Transition is how the decks are switched.
+type Transition int
+
+const (
+	// Cut switches instantly, the crossfader is a hard switch at its centre.
+	Cut Transition = iota
+	// Fade blends the decks along the crossfader, Take still switches instantly.
+	Fade
+	// TimedFade blends like Fade, Take moves the crossfader over the fade time.
+	TimedFade
+	TransitionMax
+)
+
+func (t Transition) String() string {
+	switch t {
+	case Cut:
+		return "cut"
+	case Fade:
+		return "fade"
+	case TimedFade:
+		return "timed-fade"
+	default:
+		return fmt.Sprintf("Transition(%d)", int(t))
+	}
+}
+
+const (
+	DefaultFadeTime = 2 * time.Second
+
+	// Travel is how long the crossfader takes from one side to the other with the stick fully pushed.
+	Travel = time.Second
+)
+
+// Levels are the gains of both decks, from 0 to 1.
+type Levels struct {
+	Video [2]float64
+	Audio [2]float64
+}
+
+// Crossfader is at position 0 for deck A and 1 for deck B. It is not safe for concurrent use.
+type Crossfader struct {
+	clock      func() time.Time
+	transition Transition
+	fadeTime   time.Duration
+
+	pos     float64
+	speed   float64 // positions per second, from the stick
+	movedAt time.Time
+	fade    *fade
+}
+
+type fade struct {
+	from, to float64
+	start    time.Time
+}
+
+func New(transition Transition) *Crossfader {
+	return &Crossfader{
+		clock:      time.Now,
+		transition: transition,
+		fadeTime:   DefaultFadeTime,
+	}
+}
+
+func (c *Crossfader) Transition() Transition {
+	return c.transition
+}
+
+// NextTransition cycles through the transitions and returns the new one.
+func (c *Crossfader) NextTransition() Transition {
+	c.transition = (c.transition + 1) % TransitionMax
+
+	return c.transition
+}
+
+// Set moves the crossfader to pos, stopping a timed fade.
+func (c *Crossfader) Set(pos float64) {
+	c.update()
+
+	c.fade = nil
+	c.pos = clamp(pos)
+}
+
+// Move sets how fast the crossfader moves, -32767 to 32767 like a stick axis. Moving it stops a timed fade.
+func (c *Crossfader) Move(speed int32) {
+	c.update()
+
+	if speed != 0 {
+		c.fade = nil
+	}
+
+	c.speed = float64(speed) / 32767 / Travel.Seconds()
+	c.movedAt = c.clock()
+}
+
+// Take switches to the deck that isn't on air, using the transition.
+func (c *Crossfader) Take() {
+	c.update()
+
+	to := 1.0
+	if c.OnAir() == B {
+		to = 0
+	}
+
+	c.speed = 0
+
+	if c.transition != TimedFade {
+		c.fade = nil
+		c.pos = to
+
+		return
+	}
+
+	c.fade = &fade{
+		from:  c.pos,
+		to:    to,
+		start: c.clock(),
+	}
+}
+
+// update advances the crossfader by its speed or the timed fade.
+func (c *Crossfader) update() {
+	now := c.clock()
+
+	if c.speed != 0 {
+		c.pos = clamp(c.pos + c.speed*now.Sub(c.movedAt).Seconds())
+		c.movedAt = now
+	}
+
+	if c.fade == nil {
+		return
+	}
+
+	// a full fade takes fadeTime, a partial one proportionally less
+	d := time.Duration(math.Abs(c.fade.to-c.fade.from) * float64(c.fadeTime))
+
+	elapsed := now.Sub(c.fade.start)
+	if elapsed >= d {
+		c.pos = c.fade.to
+		c.fade = nil
+
+		return
+	}
+
+	c.pos = c.fade.from + (c.fade.to-c.fade.from)*float64(elapsed)/float64(d)
+}
+
+// Position returns where the crossfader is now.
+func (c *Crossfader) Position() float64 {
+	c.update()
+
+	return c.pos
+}
+
+// Moving reports whether the crossfader moves on its own, i.e. during a timed fade or while the stick is pushed.
+func (c *Crossfader) Moving() bool {
+	return c.fade != nil || c.speed != 0
+}
+
+// OnAir returns the deck whose video is shown.
+func (c *Crossfader) OnAir() Deck {
+	c.update()
+
+	if c.pos < 0.5 {
+		return A
+	}
+
+	return B
+}
+
+// Levels returns the gains of both decks now.
+// libvlc can't composite two players, so the video dips through black: the deck on air fades out
+// until the centre, where the other deck is put on air and fades in. The audio is mixed.
+func (c *Crossfader) Levels() Levels {
+	p := c.Position()
+
+	if c.transition == Cut {
+		var l Levels
+
+		onAir := A
+		if p >= 0.5 {
+			onAir = B
+		}
+
+		l.Video[onAir] = 1
+		l.Audio[onAir] = 1
+
+		return l
+	}
+
+	return Levels{
+		Video: [2]float64{clamp(1 - 2*p), clamp(2*p - 1)},
+		Audio: [2]float64{1 - p, p},
+	}
+}
+
+func clamp(v float64) float64 {
+	return min(max(v, 0), 1)
+}
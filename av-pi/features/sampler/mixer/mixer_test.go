@@ -0,0 +1,153 @@
+package mixer
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/markus-wa/vlc-sampler/internal/testutil"
+)
+
+func newTestCrossfader(t Transition) (*Crossfader, *testutil.Clock) {
+	clock := testutil.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	c := New(t)
+	c.clock = clock.Now
+
+	return c, clock
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCut(t *testing.T) {
+	c, _ := newTestCrossfader(Cut)
+
+	c.Set(0.4)
+
+	if l := c.Levels(); l.Video != [2]float64{1, 0} || l.Audio != [2]float64{1, 0} {
+		t.Errorf("at 0.4 got %+v, want deck A only", l)
+	}
+
+	c.Set(0.6)
+
+	if l := c.Levels(); l.Video != [2]float64{0, 1} || l.Audio != [2]float64{0, 1} {
+		t.Errorf("at 0.6 got %+v, want deck B only", l)
+	}
+
+	c.Take()
+
+	if c.Position() != 0 || c.OnAir() != A {
+		t.Errorf("take went to %v", c.Position())
+	}
+}
+
+func TestFadeLevels(t *testing.T) {
+	c, _ := newTestCrossfader(Fade)
+
+	for _, tt := range []struct {
+		pos   float64
+		video [2]float64
+		audio [2]float64
+	}{
+		{0, [2]float64{1, 0}, [2]float64{1, 0}},
+		{0.25, [2]float64{0.5, 0}, [2]float64{0.75, 0.25}},
+		{0.5, [2]float64{0, 0}, [2]float64{0.5, 0.5}},
+		{0.75, [2]float64{0, 0.5}, [2]float64{0.25, 0.75}},
+		{1.5, [2]float64{0, 1}, [2]float64{0, 1}},
+	} {
+		c.Set(tt.pos)
+
+		l := c.Levels()
+
+		if l.Video != tt.video || l.Audio != tt.audio {
+			t.Errorf("at %v got %+v, want video %v audio %v", tt.pos, l, tt.video, tt.audio)
+		}
+	}
+}
+
+func TestTimedFade(t *testing.T) {
+	c, clock := newTestCrossfader(TimedFade)
+
+	c.Take()
+
+	clock.Advance(DefaultFadeTime / 4)
+
+	if p := c.Position(); !near(p, 0.25) {
+		t.Errorf("after a quarter got %v", p)
+	}
+
+	if !c.Moving() {
+		t.Error("not fading")
+	}
+
+	clock.Advance(DefaultFadeTime)
+
+	if p := c.Position(); p != 1 || c.Moving() {
+		t.Errorf("after the fade time got %v, moving %v", p, c.Moving())
+	}
+
+	// from the centre back to A takes half the fade time
+	c.Set(0.5)
+	c.Take()
+
+	clock.Advance(DefaultFadeTime / 2)
+
+	if p := c.Position(); p != 0 {
+		t.Errorf("partial fade ended at %v", p)
+	}
+}
+
+func TestSetStopsTimedFade(t *testing.T) {
+	c, clock := newTestCrossfader(TimedFade)
+
+	c.Take()
+	clock.Advance(DefaultFadeTime / 4)
+	c.Set(0.1)
+	clock.Advance(DefaultFadeTime)
+
+	if p := c.Position(); p != 0.1 {
+		t.Errorf("got %v, want 0.1", p)
+	}
+}
+
+func TestMove(t *testing.T) {
+	c, clock := newTestCrossfader(Fade)
+
+	c.Move(32767)
+	clock.Advance(Travel / 2)
+
+	if p := c.Position(); !near(p, 0.5) {
+		t.Errorf("after half the travel got %v", p)
+	}
+
+	clock.Advance(Travel)
+
+	if p := c.Position(); p != 1 {
+		t.Errorf("got %v, want the end", p)
+	}
+
+	c.Move(-32767)
+	clock.Advance(Travel / 2)
+	c.Move(0)
+	clock.Advance(Travel)
+
+	if p := c.Position(); !near(p, 0.5) || c.Moving() {
+		t.Errorf("got %v, moving %v", p, c.Moving())
+	}
+}
+
+func TestNextTransition(t *testing.T) {
+	c, _ := newTestCrossfader(Cut)
+
+	var got []string
+
+	for range TransitionMax {
+		got = append(got, c.NextTransition().String())
+	}
+
+	if got[0] != "fade" || got[1] != "timed-fade" || got[2] != "cut" {
+		t.Errorf("got %q", got)
+	}
+}
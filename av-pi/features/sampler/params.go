@@ -0,0 +1,102 @@
+package sampler
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/markus-wa/vlc-sampler/features/sampler/fx"
+	"github.com/markus-wa/vlc-sampler/features/sampler/params"
+	"github.com/markus-wa/vlc-sampler/features/sampler/transport"
+)
+
+// newParams registers the parameters MIDI can set: the effects and the rate of the selected deck and the crossfader.
+func (s *Sampler) newParams() *params.Registry {
+	r := params.New()
+
+	for p := range fx.ParamMax {
+		r.Register(p.String(), params.Param{
+			Min: fx.Ranges[p].Min,
+			Max: fx.Ranges[p].Max,
+			// the gamma is a ratio like the rate
+			Log: p == fx.Gamma,
+			Set: func(v float64) error {
+				return s.effects(func(e *fx.Effects) error {
+					e.Set(p, v)
+
+					return nil
+				})
+			},
+		})
+	}
+
+	r.Register("rate", params.Param{Min: transport.MinRate, Max: transport.MaxRate, Log: true, Set: s.SetRate})
+	r.Register("crossfader", params.Param{Min: 0, Max: 1, Set: func(v float64) error {
+		s.SetCrossfader(v)
+
+		return nil
+	}})
+
+	return r
+}
+
+// Params returns the names of the parameters SetParam sets.
+func (s *Sampler) Params() []string {
+	s.paramMu.Lock()
+	defer s.paramMu.Unlock()
+
+	return s.params.Names()
+}
+
+// SetParam moves a parameter to v, from 0 (its minimum) to 1 (its maximum), smoothing the change.
+func (s *Sampler) SetParam(name string, v float64) error {
+	s.paramMu.Lock()
+	defer s.paramMu.Unlock()
+
+	return s.params.Set(name, v)
+}
+
+// smoothParams moves the changing parameters on by elapsed and applies them.
+func (s *Sampler) smoothParams(elapsed time.Duration) {
+	s.paramMu.Lock()
+	err := s.params.Tick(elapsed)
+	s.paramMu.Unlock()
+
+	if err != nil {
+		zap.S().Debugw("failed to set parameters", "error", err)
+	}
+}
+
+// selectClip plays clip n (from 1) of d's list.
+func (s *Sampler) selectClip(d *deck, n int) error {
+	count, err := d.listPlayer.MediaList().Count()
+	if err != nil {
+		return fmt.Errorf("failed to get media list count: %w", err)
+	}
+
+	if n < 1 || n > count {
+		return fmt.Errorf("clip %d doesn't exist, there are %d", n, count)
+	}
+
+	err = d.listPlayer.PlayAtIndex(uint(n - 1))
+	if err != nil {
+		return fmt.Errorf("failed to play media %d: %w", n-1, err)
+	}
+
+	return nil
+}
+
+// selectPlaylist plays playlist n (from 1) on d.
+func (s *Sampler) selectPlaylist(d *deck, n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n < 1 || n > len(s.playlists) {
+		return fmt.Errorf("playlist %d doesn't exist, there are %d", n, len(s.playlists))
+	}
+
+	d.currentListIndex = n - 1
+
+	return s.playPlaylist(d, d.currentListIndex)
+}
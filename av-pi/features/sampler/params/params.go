@@ -0,0 +1,118 @@
+// Package params is the registry of the sampler's continuous parameters, which MIDI and other modulation sources
+// set by name on a common 0 to 1 scale. Changes are smoothed, so the steps of 7-bit CCs don't show.
+package params
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Smoothing is the time constant of the smoothing, a change is 95% done after three times as long.
+const Smoothing = 60 * time.Millisecond
+
+// Param is a parameter with its range and how to set it.
+type Param struct {
+	Min, Max float64
+	// Log maps the scale exponentially, for ratios like the playback rate, so 0.5 is their geometric mean.
+	Log bool
+	Set func(float64) error
+}
+
+// Scale returns the value of the parameter at v, from 0 (Min) to 1 (Max).
+func (p Param) Scale(v float64) float64 {
+	v = min(max(v, 0), 1)
+
+	if p.Log {
+		return p.Min * math.Pow(p.Max/p.Min, v)
+	}
+
+	return p.Min + v*(p.Max-p.Min)
+}
+
+type entry struct {
+	Param
+
+	target, current float64
+	// set is false until the parameter has been set once, its first value isn't smoothed
+	set bool
+	// applied is false while current hasn't been passed to Param.Set
+	applied bool
+}
+
+// Registry holds the parameters by name. It is not safe for concurrent use.
+type Registry struct {
+	params map[string]*entry
+}
+
+func New() *Registry {
+	return &Registry{
+		params: map[string]*entry{},
+	}
+}
+
+func (r *Registry) Register(name string, p Param) {
+	r.params[name] = &entry{Param: p}
+}
+
+// Names returns the names of all parameters, sorted.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.params))
+
+	for name := range r.params {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// Set moves the parameter towards v, from 0 to 1. Tick applies it.
+func (r *Registry) Set(name string, v float64) error {
+	e, ok := r.params[name]
+	if !ok {
+		return fmt.Errorf("unknown parameter %q, want one of %s", name, strings.Join(r.Names(), ", "))
+	}
+
+	e.target = min(max(v, 0), 1)
+	e.applied = false
+
+	if !e.set {
+		e.current = e.target
+		e.set = true
+	}
+
+	return nil
+}
+
+// Tick moves the parameters elapsed closer to their targets and sets those that changed.
+func (r *Registry) Tick(elapsed time.Duration) error {
+	k := 1 - math.Exp(-elapsed.Seconds()/Smoothing.Seconds())
+
+	var errs []error
+
+	for name, e := range r.params {
+		if e.applied {
+			continue
+		}
+
+		e.current += (e.target - e.current) * k
+
+		// close enough for any parameter, and the smoothing ends
+		if math.Abs(e.target-e.current) < 1e-3 {
+			e.current = e.target
+			e.applied = true
+		}
+
+		err := e.Param.Set(e.Scale(e.current))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to set %s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,88 @@
+package params
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		p    Param
+		v    float64
+		want float64
+	}{
+		{Param{Min: -180, Max: 180}, 0.5, 0},
+		{Param{Min: 0, Max: 2}, 1.5, 2},
+		{Param{Min: 0.25, Max: 4, Log: true}, 0.5, 1},
+		{Param{Min: 0.25, Max: 4, Log: true}, 0, 0.25},
+	}
+
+	for _, tt := range tests {
+		if got := tt.p.Scale(tt.v); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%+v.Scale(%g) = %g, want %g", tt.p, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestSmoothing(t *testing.T) {
+	var got []float64
+
+	r := New()
+	r.Register("hue", Param{Min: 0, Max: 100, Set: func(v float64) error {
+		got = append(got, v)
+
+		return nil
+	}})
+
+	err := r.Set("hue", 0.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first value jumps
+	err = r.Tick(time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || math.Abs(got[0]-20) > 1e-9 {
+		t.Fatalf("first value %v, want 20", got)
+	}
+
+	err = r.Set("hue", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = r.Tick(Smoothing)
+
+	if v := got[len(got)-1]; v < 65 || v > 75 {
+		t.Errorf("after one time constant %g, want about 71", v)
+	}
+
+	for range 20 {
+		_ = r.Tick(Smoothing)
+	}
+
+	if v := got[len(got)-1]; v != 100 {
+		t.Errorf("settled at %g, want 100", v)
+	}
+
+	n := len(got)
+
+	_ = r.Tick(Smoothing)
+
+	if len(got) != n {
+		t.Error("set a settled parameter again")
+	}
+}
+
+func TestUnknown(t *testing.T) {
+	r := New()
+	r.Register("rate", Param{Min: 0.25, Max: 4, Log: true})
+
+	if err := r.Set("speed", 1); err == nil {
+		t.Error("set an unknown parameter")
+	}
+}
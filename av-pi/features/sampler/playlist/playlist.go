@@ -0,0 +1,327 @@
+// Package playlist reads XSPF, M3U, M3U8 and PLS playlists and folders of videos into lists of clips,
+// resolving relative paths and finding the clips that are missing.
+package playlist
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Order is the order clips are played in.
+type Order int
+
+const (
+	// Listed keeps the order of the playlist, folders are sorted by name.
+	Listed Order = iota
+	ByName
+	Shuffled
+	OrderMax
+)
+
+var orderNames = [OrderMax]string{"listed", "name", "shuffle"}
+
+func (o Order) String() string {
+	if o < 0 || o >= OrderMax {
+		return fmt.Sprintf("Order(%d)", int(o))
+	}
+
+	return orderNames[o]
+}
+
+func ParseOrder(s string) (Order, error) {
+	i := slices.Index(orderNames[:], s)
+	if i < 0 {
+		return 0, fmt.Errorf("unknown order %q, want one of %s", s, strings.Join(orderNames[:], ", "))
+	}
+
+	return Order(i), nil
+}
+
+// Extensions are the playlist formats, folders are playlists too.
+var Extensions = []string{".xspf", ".m3u", ".m3u8", ".pls"}
+
+// VideoExtensions are the files a folder plays.
+var VideoExtensions = []string{
+	".mp4", ".m4v", ".mkv", ".mov", ".avi", ".webm", ".mpg", ".mpeg", ".ts", ".flv", ".wmv", ".ogv", ".gif",
+}
+
+// Playlist is a list of clips.
+type Playlist struct {
+	Path string
+	// Clips are paths of local files and URLs of anything else, e.g. streams.
+	Clips []string
+	// Missing are the local files the playlist lists that don't exist, they aren't in Clips.
+	Missing []string
+}
+
+// Name returns the name of a playlist, its file name without the extension.
+func Name(path string) string {
+	base := filepath.Base(path)
+
+	if slices.Contains(Extensions, strings.ToLower(filepath.Ext(base))) {
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	return base
+}
+
+// Find returns the playlists in dir, the playlist files and the folders, sorted by name.
+// Hidden files and folders are left out.
+func Find(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var playlists []string
+
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		if e.IsDir() || slices.Contains(Extensions, strings.ToLower(filepath.Ext(e.Name()))) {
+			playlists = append(playlists, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	return playlists, nil
+}
+
+// Load reads the playlist at path, a playlist file or a folder, in order.
+func Load(path string, order Order) (Playlist, error) {
+	p := Playlist{Path: path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return p, fmt.Errorf("failed to open playlist: %w", err)
+	}
+
+	var entries []string
+
+	if info.IsDir() {
+		entries, err = folder(path)
+	} else {
+		entries, err = parseFile(path)
+	}
+
+	if err != nil {
+		return p, err
+	}
+
+	for _, e := range entries {
+		clip, local := e, true
+		if !info.IsDir() {
+			clip, local = resolve(filepath.Dir(path), e)
+		}
+
+		if local {
+			if _, err := os.Stat(clip); err != nil {
+				p.Missing = append(p.Missing, clip)
+
+				continue
+			}
+		}
+
+		p.Clips = append(p.Clips, clip)
+	}
+
+	switch order {
+	case ByName:
+		slices.SortFunc(p.Clips, func(a, b string) int {
+			return strings.Compare(strings.ToLower(filepath.Base(a)), strings.ToLower(filepath.Base(b)))
+		})
+	case Shuffled:
+		rand.Shuffle(len(p.Clips), func(i, j int) {
+			p.Clips[i], p.Clips[j] = p.Clips[j], p.Clips[i]
+		})
+	}
+
+	return p, nil
+}
+
+// folder returns the videos in dir, sorted by name.
+func folder(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read folder: %w", err)
+	}
+
+	var clips []string
+
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		if slices.Contains(VideoExtensions, strings.ToLower(filepath.Ext(e.Name()))) {
+			clips = append(clips, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	return clips, nil
+}
+
+func parseFile(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read playlist: %w", err)
+	}
+
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")) // byte order mark
+
+	var entries []string
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".xspf":
+		entries, err = parseXSPF(b)
+	case ".m3u", ".m3u8":
+		entries = parseM3U(b)
+	case ".pls":
+		entries, err = parsePLS(b)
+	default:
+		err = fmt.Errorf("unknown playlist format %q", ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse playlist %s: %w", path, err)
+	}
+
+	return entries, nil
+}
+
+func parseXSPF(b []byte) ([]string, error) {
+	var doc struct {
+		Tracks []struct {
+			Location string `xml:"location"`
+		} `xml:"trackList>track"`
+	}
+
+	err := xml.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []string
+
+	for _, t := range doc.Tracks {
+		loc := strings.TrimSpace(t.Location)
+		if loc == "" {
+			continue
+		}
+
+		// relative locations are URIs too, e.g. clips/my%20clip.mp4
+		if !strings.Contains(loc, "://") {
+			if p, err := url.PathUnescape(loc); err == nil {
+				loc = p
+			}
+		}
+
+		entries = append(entries, loc)
+	}
+
+	return entries, nil
+}
+
+func parseM3U(b []byte) []string {
+	var entries []string
+
+	for _, line := range lines(b) {
+		// #EXTM3U, #EXTINF and other comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entries = append(entries, line)
+	}
+
+	return entries
+}
+
+func parsePLS(b []byte) ([]string, error) {
+	files := map[int]string{}
+
+	for _, line := range lines(b) {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || !strings.HasPrefix(strings.ToLower(key), "file") {
+			continue
+		}
+
+		n, err := strconv.Atoi(key[len("file"):])
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry %q", key)
+		}
+
+		files[n] = strings.TrimSpace(value)
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("no File entries")
+	}
+
+	numbers := make([]int, 0, len(files))
+	for n := range files {
+		numbers = append(numbers, n)
+	}
+
+	slices.Sort(numbers)
+
+	entries := make([]string, len(numbers))
+	for i, n := range numbers {
+		entries[i] = files[n]
+	}
+
+	return entries, nil
+}
+
+// lines splits b into trimmed lines. Text that isn't UTF-8 is read as Latin-1, like the M3U files of old players.
+func lines(b []byte) []string {
+	text := string(b)
+
+	if !utf8.Valid(b) {
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+
+		text = string(runes)
+	}
+
+	// split rather than scanned, a bufio.Scanner stops at lines longer than 64 KiB, e.g. of data URIs
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+
+	return lines
+}
+
+// resolve returns the clip an entry of a playlist in dir refers to, and whether it is a local file.
+// Relative paths are relative to dir, with / or \ as separators.
+func resolve(dir, entry string) (string, bool) {
+	if u, err := url.Parse(entry); err == nil && len(u.Scheme) > 1 {
+		if u.Scheme != "file" {
+			return entry, false
+		}
+
+		entry = u.Path
+	}
+
+	entry = filepath.FromSlash(strings.ReplaceAll(entry, `\`, "/"))
+
+	if !filepath.IsAbs(entry) {
+		entry = filepath.Join(dir, entry)
+	}
+
+	return entry, true
+}
@@ -0,0 +1,169 @@
+package playlist
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestDir returns a playlist directory with clips/a.mp4, clips/b c.mp4 and clips/notes.txt.
+func newTestDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	err := os.Mkdir(filepath.Join(dir, "clips"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"b c.mp4", "a.mp4", "notes.txt"} {
+		err := os.WriteFile(filepath.Join(dir, "clips", name), nil, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func write(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+
+	err := os.WriteFile(path, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := newTestDir(t)
+	a, bc := filepath.Join(dir, "clips", "a.mp4"), filepath.Join(dir, "clips", "b c.mp4")
+
+	tests := []struct {
+		name, content string
+		want          []string
+	}{
+		{"set.xspf", `<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <trackList>
+    <track><location>file://` + bc + `</location></track>
+    <track><location>clips/a.mp4</location></track>
+    <track><location>clips/b%20c.mp4</location></track>
+  </trackList>
+</playlist>`, []string{bc, a, bc}},
+		{"set.m3u8", "\xef\xbb\xbf#EXTM3U\n#EXTINF:10,A\nclips/a.mp4\n\n" + bc + "\r\nhttp://cam.local/stream\n", []string{a, bc, "http://cam.local/stream"}},
+		{"set.m3u", "clips\\b c.mp4\n", []string{bc}},
+		{"long.m3u", "#EXTINF:10," + strings.Repeat("x", 100_000) + "\nclips/a.mp4\n", []string{a}},
+		{"set.pls", "[playlist]\nFile2=clips/a.mp4\nTitle2=A\nFile1=clips/b c.mp4\nNumberOfEntries=2\n", []string{bc, a}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Load(write(t, dir, tt.name, tt.content), Listed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(p.Clips, tt.want) {
+				t.Errorf("got %q, want %q", p.Clips, tt.want)
+			}
+
+			if len(p.Missing) > 0 {
+				t.Errorf("missing %q", p.Missing)
+			}
+		})
+	}
+}
+
+func TestLoadFolder(t *testing.T) {
+	dir := newTestDir(t)
+
+	p, err := Load(filepath.Join(dir, "clips"), Listed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{filepath.Join(dir, "clips", "a.mp4"), filepath.Join(dir, "clips", "b c.mp4")}
+	if !reflect.DeepEqual(p.Clips, want) {
+		t.Errorf("got %q, want %q", p.Clips, want)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	dir := newTestDir(t)
+
+	p, err := Load(write(t, dir, "set.m3u", "clips/a.mp4\nclips/gone.mp4\n"), Listed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Clips) != 1 || !reflect.DeepEqual(p.Missing, []string{filepath.Join(dir, "clips", "gone.mp4")}) {
+		t.Errorf("clips %q, missing %q", p.Clips, p.Missing)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	dir := newTestDir(t)
+	path := write(t, dir, "set.m3u", "clips/b c.mp4\nclips/a.mp4\n")
+
+	p, err := Load(path, ByName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Base(p.Clips[0]) != "a.mp4" {
+		t.Errorf("not sorted by name: %q", p.Clips)
+	}
+
+	p, err = Load(path, Shuffled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Contains(p.Clips, filepath.Join(dir, "clips", "a.mp4")) || len(p.Clips) != 2 {
+		t.Errorf("shuffling lost clips: %q", p.Clips)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := newTestDir(t)
+
+	for _, name := range []string{"live.xspf", "set.M3U", "radio.pls", "cues.json", ".hidden.m3u"} {
+		write(t, dir, name, "")
+	}
+
+	got, err := Find(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, p := range got {
+		names = append(names, Name(p))
+	}
+
+	if want := []string{"clips", "live", "radio", "set"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, want %q", names, want)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	dir := newTestDir(t)
+
+	for name, content := range map[string]string{
+		"bad.xspf": "<playlist><trackList>",
+		"bad.pls":  "[playlist]\nNumberOfEntries=0\n",
+	} {
+		if _, err := Load(write(t, dir, name, content), Listed); err == nil {
+			t.Errorf("loaded %s", name)
+		}
+	}
+}
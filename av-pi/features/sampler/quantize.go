@@ -0,0 +1,195 @@
+package sampler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/markus-wa/vlc-sampler/features/sampler/quantize"
+)
+
+// pending is a switch waiting for the next beat or bar.
+type pending struct {
+	name string
+	f    func() error
+}
+
+// quantized runs f now, or queues it for the next beat or bar while quantising.
+// The callers pick the deck before, so a switch stays on its deck if the other one is selected meanwhile.
+func (s *Sampler) quantized(name string, f func() error) error {
+	s.loopMu.Lock()
+
+	if s.quantize == quantize.Off {
+		s.loopMu.Unlock()
+
+		return f()
+	}
+
+	s.pending = append(s.pending, pending{name: name, f: f})
+	s.loopMu.Unlock()
+
+	return nil
+}
+
+func (s *Sampler) Previous() error {
+	d := s.selectedDeck()
+
+	return s.quantized("previous clip", func() error {
+		return s.previous(d)
+	})
+}
+
+func (s *Sampler) Next() error {
+	d := s.selectedDeck()
+
+	return s.quantized("next clip", func() error {
+		return s.next(d)
+	})
+}
+
+func (s *Sampler) PreviousPlaylist() error {
+	d := s.selectedDeck()
+
+	return s.quantized("previous playlist", func() error {
+		return s.previousPlaylist(d)
+	})
+}
+
+func (s *Sampler) NextPlaylist() error {
+	d := s.selectedDeck()
+
+	return s.quantized("next playlist", func() error {
+		return s.nextPlaylist(d)
+	})
+}
+
+// SelectClip plays clip n (from 1) of the selected deck's list.
+func (s *Sampler) SelectClip(n int) error {
+	d := s.selectedDeck()
+
+	return s.quantized(fmt.Sprintf("clip %d", n), func() error {
+		return s.selectClip(d, n)
+	})
+}
+
+// SelectPlaylist plays playlist n (from 1) on the selected deck.
+func (s *Sampler) SelectPlaylist(n int) error {
+	d := s.selectedDeck()
+
+	return s.quantized(fmt.Sprintf("playlist %d", n), func() error {
+		return s.selectPlaylist(d, n)
+	})
+}
+
+// SetQuantize sets what switches wait for, "off", "beat" or "bar". Switching it off runs the waiting switches.
+func (s *Sampler) SetQuantize(name string) error {
+	m, err := quantize.ParseMode(name)
+	if err != nil {
+		return err
+	}
+
+	s.setQuantize(func(quantize.Mode) quantize.Mode {
+		return m
+	})
+
+	return nil
+}
+
+// NextQuantize cycles through off, beat and bar and returns the new mode.
+func (s *Sampler) NextQuantize() quantize.Mode {
+	return s.setQuantize(quantize.Mode.Next)
+}
+
+// setQuantize changes the mode to what f returns for the current one and returns the new mode.
+func (s *Sampler) setQuantize(f func(quantize.Mode) quantize.Mode) quantize.Mode {
+	s.loopMu.Lock()
+
+	m := f(s.quantize)
+	s.quantize = m
+
+	var due []pending
+
+	if m == quantize.Off {
+		due, s.pending = s.pending, nil
+	}
+
+	s.loopMu.Unlock()
+
+	s.run(due)
+
+	return m
+}
+
+// TapTempo taps the tempo and returns it, ok is false for the first tap, which only starts a bar.
+func (s *Sampler) TapTempo() (bpm float64, ok bool) {
+	s.loopMu.Lock()
+	defer s.loopMu.Unlock()
+
+	return s.grid.Tap()
+}
+
+// ClockBeat locks the beat grid to a beat of an external clock.
+func (s *Sampler) ClockBeat() {
+	s.loopMu.Lock()
+	defer s.loopMu.Unlock()
+
+	s.grid.ExternalBeat()
+}
+
+// ClockStart starts a bar of the beat grid, when an external clock starts.
+func (s *Sampler) ClockStart() {
+	s.loopMu.Lock()
+	defer s.loopMu.Unlock()
+
+	s.grid.ExternalStart()
+}
+
+// runPending runs the waiting switches if the beat or bar was crossed between last and now.
+func (s *Sampler) runPending(last, now time.Time) {
+	var due []pending
+
+	s.loopMu.Lock()
+
+	if s.grid.Crossed(s.quantize, last, now) {
+		due, s.pending = s.pending, nil
+	}
+
+	s.loopMu.Unlock()
+
+	s.run(due)
+}
+
+func (s *Sampler) run(due []pending) {
+	for _, p := range due {
+		err := p.f()
+		if err == nil {
+			continue
+		}
+
+		zap.S().Errorw("failed to switch", "switch", p.name, "error", err)
+
+		s.loopMu.Lock()
+		failed := s.failed
+		s.loopMu.Unlock()
+
+		if failed != nil {
+			failed(fmt.Sprintf("%s failed: %v", p.name, err))
+		}
+	}
+}
+
+// pendingNames returns the names of the waiting switches, e.g. "next clip, next playlist".
+func (s *Sampler) pendingNames() string {
+	s.loopMu.Lock()
+	defer s.loopMu.Unlock()
+
+	names := make([]string, len(s.pending))
+
+	for i, p := range s.pending {
+		names[i] = p.name
+	}
+
+	return strings.Join(names, ", ")
+}
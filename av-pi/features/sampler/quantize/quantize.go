@@ -0,0 +1,171 @@
+// Package quantize keeps the beat grid of the sampler: its tempo, set or tapped, and its phase, which an external
+// clock can lock to. Quantised switches wait for the next beat or bar of the grid.
+package quantize
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Mode is what switches are quantised to.
+type Mode int
+
+const (
+	Off Mode = iota
+	Beat
+	Bar
+	ModeMax
+)
+
+var modeNames = [ModeMax]string{"off", "beat", "bar"}
+
+func (m Mode) String() string {
+	if m < 0 || m >= ModeMax {
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+
+	return modeNames[m]
+}
+
+// Next returns the mode after m, cycling through off, beat and bar.
+func (m Mode) Next() Mode {
+	return (m + 1) % ModeMax
+}
+
+func ParseMode(s string) (Mode, error) {
+	i := slices.Index(modeNames[:], s)
+	if i < 0 {
+		return 0, fmt.Errorf("unknown quantisation %q, want off, beat or bar", s)
+	}
+
+	return Mode(i), nil
+}
+
+const (
+	// BeatsPerBar is the length of a bar, the sampler plays in 4/4.
+	BeatsPerBar = 4
+
+	// Timeout is how long after a tap or an external beat the next one counts as belonging to it.
+	Timeout = 2 * time.Second
+
+	// taps is how many taps the tempo is averaged over
+	taps = 5
+)
+
+// Grid is the beat grid, counted from an anchor beat. It is not safe for concurrent use.
+type Grid struct {
+	clock func() time.Time
+
+	bpm float64
+	// anchor is when beat anchorBeat was, beat 0 starts a bar
+	anchor     time.Time
+	anchorBeat int
+
+	taps []time.Time
+	// external is when the last external beat was
+	external time.Time
+}
+
+// New returns a grid at bpm whose first bar starts now.
+func New(bpm float64) *Grid {
+	g := &Grid{
+		clock: time.Now,
+		bpm:   bpm,
+	}
+
+	g.anchor = g.clock()
+
+	return g
+}
+
+func (g *Grid) BPM() float64 {
+	return g.bpm
+}
+
+func (g *Grid) beatLength() time.Duration {
+	return time.Duration(float64(time.Minute) / g.bpm)
+}
+
+// Position returns how many beats are counted at t, e.g. 5.5 is halfway through the second beat of the second bar.
+func (g *Grid) Position(t time.Time) float64 {
+	return float64(g.anchorBeat) + float64(t.Sub(g.anchor))/float64(g.beatLength())
+}
+
+// SetTempo changes the tempo from the last beat on, so the beats keep their phase.
+func (g *Grid) SetTempo(bpm float64) {
+	g.reanchor(int(math.Floor(g.Position(g.clock()))))
+	g.bpm = bpm
+}
+
+// reanchor moves the anchor to beat, on the current grid.
+func (g *Grid) reanchor(beat int) {
+	g.anchor = g.anchor.Add(time.Duration(beat-g.anchorBeat) * g.beatLength())
+	g.anchorBeat = beat
+}
+
+// Tap counts a tap of the tempo. The first tap after a pause starts a bar, the following ones set the tempo to their
+// average and return it.
+func (g *Grid) Tap() (float64, bool) {
+	now := g.clock()
+
+	if len(g.taps) == 0 || now.Sub(g.taps[len(g.taps)-1]) > Timeout {
+		g.taps = []time.Time{now}
+		g.anchor, g.anchorBeat = now, 0
+
+		return g.bpm, false
+	}
+
+	g.taps = append(g.taps, now)
+	if len(g.taps) > taps {
+		g.taps = g.taps[1:]
+	}
+
+	avg := now.Sub(g.taps[0]) / time.Duration(len(g.taps)-1)
+
+	g.bpm = math.Round(float64(time.Minute)/float64(avg)*10) / 10
+	g.anchor = now
+	g.anchorBeat++
+
+	return g.bpm, true
+}
+
+// ExternalBeat locks the grid to a beat of an external clock, which also sets the tempo once it beats steadily.
+func (g *Grid) ExternalBeat() {
+	now := g.clock()
+
+	if !g.external.IsZero() && now.Sub(g.external) <= Timeout {
+		g.bpm = math.Round(float64(time.Minute)/float64(now.Sub(g.external))*10) / 10
+		g.anchorBeat++
+	} else {
+		g.anchorBeat = int(math.Round(g.Position(now)))
+	}
+
+	g.anchor = now
+	g.external = now
+}
+
+// ExternalStart starts a bar now, when an external clock starts.
+func (g *Grid) ExternalStart() {
+	now := g.clock()
+
+	g.anchor, g.anchorBeat = now, 0
+	g.external = now
+}
+
+// Crossed reports whether a boundary of m, a beat or a bar, lies after from and up to to.
+func (g *Grid) Crossed(m Mode, from, to time.Time) bool {
+	var unit float64
+
+	switch m {
+	case Beat:
+		unit = 1
+	case Bar:
+		unit = BeatsPerBar
+	default:
+		return false
+	}
+
+	return math.Floor(g.Position(from)/unit) < math.Floor(g.Position(to)/unit)
+}
@@ -0,0 +1,115 @@
+package quantize
+
+import (
+	"testing"
+	"time"
+
+	"github.com/markus-wa/vlc-sampler/internal/testutil"
+)
+
+func newTestGrid(bpm float64) (*Grid, *testutil.Clock) {
+	clock := testutil.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	g := New(bpm)
+	g.clock = clock.Now
+	g.anchor = clock.Now()
+
+	return g, clock
+}
+
+func TestCrossed(t *testing.T) {
+	g, clock := newTestGrid(120)
+
+	start := clock.Now()
+
+	tests := []struct {
+		m        Mode
+		from, to time.Duration
+		want     bool
+	}{
+		{Beat, 100 * time.Millisecond, 400 * time.Millisecond, false},
+		{Beat, 400 * time.Millisecond, 500 * time.Millisecond, true},
+		{Bar, 400 * time.Millisecond, 500 * time.Millisecond, false},
+		{Bar, 1900 * time.Millisecond, 2010 * time.Millisecond, true},
+		{Off, 0, time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		if got := g.Crossed(tt.m, start.Add(tt.from), start.Add(tt.to)); got != tt.want {
+			t.Errorf("Crossed(%s, %s, %s) = %t", tt.m, tt.from, tt.to, got)
+		}
+	}
+}
+
+func TestTap(t *testing.T) {
+	g, clock := newTestGrid(120)
+
+	if _, ok := g.Tap(); ok {
+		t.Error("the first tap set the tempo")
+	}
+
+	for range 3 {
+		clock.Advance(600 * time.Millisecond)
+		g.Tap()
+	}
+
+	if g.BPM() != 100 {
+		t.Errorf("tempo %g, want 100", g.BPM())
+	}
+
+	// the next bar starts a beat after the fourth tap
+	if p := g.Position(clock.Now()); p != 3 {
+		t.Errorf("at beat %g, want 3", p)
+	}
+
+	// after a pause, tapping starts over
+	clock.Advance(Timeout + time.Second)
+
+	if _, ok := g.Tap(); ok || g.Position(clock.Now()) != 0 {
+		t.Error("tapping didn't start over")
+	}
+}
+
+func TestSetTempoKeepsPhase(t *testing.T) {
+	g, clock := newTestGrid(120)
+
+	clock.Advance(2250 * time.Millisecond) // beat 4.5
+
+	g.SetTempo(60)
+
+	if p := g.Position(clock.Now()); p != 4.25 {
+		t.Errorf("at beat %g, want 4.25", p)
+	}
+}
+
+func TestExternalBeat(t *testing.T) {
+	g, clock := newTestGrid(120)
+
+	g.ExternalStart()
+
+	for range 4 {
+		clock.Advance(time.Second)
+		g.ExternalBeat()
+	}
+
+	if g.BPM() != 60 {
+		t.Errorf("tempo %g, want 60", g.BPM())
+	}
+
+	if p := g.Position(clock.Now()); p != 4 {
+		t.Errorf("at beat %g, want 4", p)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for m := range ModeMax {
+		got, err := ParseMode(m.String())
+		if err != nil || got != m {
+			t.Errorf("ParseMode(%q) = %s, %v", m, got, err)
+		}
+	}
+
+	if Bar.Next() != Off {
+		t.Error("modes don't cycle")
+	}
+}
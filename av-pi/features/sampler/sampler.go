@@ -3,16 +3,28 @@ package sampler
 import (
 	"errors"
 	"fmt"
+	"image"
+	"image/png"
 	"log"
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	vlc "github.com/adrg/libvlc-go/v3"
-	"github.com/kenshaw/evdev"
 	"github.com/vladimirvivien/go4vl/device"
 	"go.uber.org/zap"
+
+	"github.com/markus-wa/vlc-sampler/features/menu"
+	"github.com/markus-wa/vlc-sampler/features/sampler/cues"
+	"github.com/markus-wa/vlc-sampler/features/sampler/fx"
+	"github.com/markus-wa/vlc-sampler/features/sampler/loop"
+	"github.com/markus-wa/vlc-sampler/features/sampler/mixer"
+	"github.com/markus-wa/vlc-sampler/features/sampler/params"
+	"github.com/markus-wa/vlc-sampler/features/sampler/playlist"
+	"github.com/markus-wa/vlc-sampler/features/sampler/quantize"
+	"github.com/markus-wa/vlc-sampler/features/sampler/state"
 )
 
 type Mode int
@@ -23,55 +35,85 @@ const (
 	ModeMax
 )
 
+const recordingsDir = "/tmp/recs"
+
+func (m Mode) String() string {
+	switch m {
+	case ModeStream:
+		return "stream"
+	case ModePlaylists:
+		return "playlists"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// Sampler plays clips on two decks, A and B, and blends them with a crossfader.
 type Sampler struct {
-	listPlayer      *vlc.ListPlayer
+	decks           [2]*deck
 	recorder        *vlc.Player
 	streamMediaList *vlc.MediaList
-
-	isRecording      bool
-	currentListIndex int
-	playlists        []string
-	mode             Mode
+	order           playlist.Order
+
+	// mu guards the fields below, which are read by State concurrently to the controllers
+	mu             sync.Mutex
+	recordingSince time.Time // zero if not recording
+	playlists      []string
+	selected       mixer.Deck
+
+	// mixMu guards the crossfader. It is separate from mu, so fades don't wait for a playlist to be parsed.
+	mixMu sync.Mutex
+	fader *mixer.Crossfader
+
+	// done stops runDecks, wg waits for it to return before the decks are released
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	// loopMu guards the points of the clips, by location, the beat grid and the quantised switches
+	loopMu   sync.Mutex
+	loops    map[string]loop.Points
+	grid     *quantize.Grid
+	quantize quantize.Mode
+	pending  []pending
+	// failed shows the errors of the switches that waited, which no action returns, see NewController
+	failed func(string)
+
+	cues *cues.Store
+
+	// paramMu guards the parameter registry, which MIDI sets and runDecks applies
+	paramMu sync.Mutex
+	params  *params.Registry
 }
 
-func New(playlistDir string) (*Sampler, error) {
+// New plays the playlists in playlistDir, playlist files and folders of videos, with their clips in order.
+func New(playlistDir string, order playlist.Order) (*Sampler, error) {
 	err := os.MkdirAll(playlistDir, 0755)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	err = os.MkdirAll("/tmp/recs", 0755)
+	err = os.MkdirAll(recordingsDir, 0755)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	dir, err := os.ReadDir(playlistDir)
+	playlists, err := playlist.Find(playlistDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read directory: %w", err)
-	}
-
-	var playlists []string
-
-	for _, entry := range dir {
-		if entry.IsDir() {
-			continue
-		}
-
-		if !strings.HasSuffix(entry.Name(), ".xspf") {
-			continue
-		}
-
-		playlists = append(playlists, path.Join(playlistDir, entry.Name()))
+		return nil, fmt.Errorf("failed to find playlists: %w", err)
 	}
 
-	listPlayer, err := vlc.NewListPlayer()
+	cueStore, err := cues.Load(path.Join(playlistDir, cues.FileName))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create listPlayer: %w", err)
+		return nil, fmt.Errorf("failed to load hot cues: %w", err)
 	}
 
-	err = listPlayer.SetPlaybackMode(vlc.Loop)
-	if err != nil {
-		return nil, fmt.Errorf("failed to set playback mode: %w", err)
+	var decks [2]*deck
+
+	for i := range decks {
+		decks[i], err = newDeck()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create deck %s: %w", mixer.Deck(i), err)
+		}
 	}
 
 	recorder, err := vlc.NewPlayer()
@@ -153,24 +195,42 @@ func New(playlistDir string) (*Sampler, error) {
 	}
 
 	av := &Sampler{
-		listPlayer:      listPlayer,
+		decks:           decks,
 		recorder:        recorder,
 		playlists:       playlists,
+		order:           order,
 		streamMediaList: streamMediaList,
+		fader:           mixer.New(mixer.Fade),
+		done:            make(chan struct{}),
+		loops:           map[string]loop.Points{},
+		grid:            quantize.New(loop.DefaultBPM),
+		cues:            cueStore,
 	}
 
-	err = av.playStreamList()
+	av.params = av.newParams()
+
+	err = av.playStreamList(decks[mixer.A])
 	if err != nil {
 		return nil, fmt.Errorf("failed to play stream list: %w", err)
 	}
 
+	// deck B starts with the first playlist, the crossfader keeps it dark until it is taken
+	err = av.setMode(decks[mixer.B], ModePlaylists)
+	if err != nil {
+		zap.S().Warnw("failed to start deck B", "error", err)
+	}
+
+	av.wg.Add(1)
+
+	go av.runDecks()
+
 	return av, nil
 }
 
-func (s *Sampler) Previous() error {
-	pl, err := s.listPlayer.Player()
+func (s *Sampler) previous(d *deck) error {
+	pl, err := d.player()
 	if err != nil {
-		return fmt.Errorf("failed to get player: %w", err)
+		return err
 	}
 
 	m, err := pl.Media()
@@ -178,7 +238,7 @@ func (s *Sampler) Previous() error {
 		return fmt.Errorf("failed to get media: %w", err)
 	}
 
-	ml := s.listPlayer.MediaList()
+	ml := d.listPlayer.MediaList()
 
 	i, err := ml.IndexOfMedia(m)
 	if err != nil {
@@ -191,7 +251,7 @@ func (s *Sampler) Previous() error {
 	}
 
 	if i == 0 {
-		err := s.listPlayer.PlayAtIndex(uint(n - 1))
+		err := d.listPlayer.PlayAtIndex(uint(n - 1))
 		if err != nil {
 			return fmt.Errorf("failed to play last media: %w", err)
 		}
@@ -199,7 +259,7 @@ func (s *Sampler) Previous() error {
 		return nil
 	}
 
-	err = s.listPlayer.PlayPrevious()
+	err = d.listPlayer.PlayPrevious()
 	if err != nil {
 		return fmt.Errorf("failed to play previous media: %w", err)
 	}
@@ -207,10 +267,10 @@ func (s *Sampler) Previous() error {
 	return nil
 }
 
-func (s *Sampler) Next() error {
-	pl, err := s.listPlayer.Player()
+func (s *Sampler) next(d *deck) error {
+	pl, err := d.player()
 	if err != nil {
-		return fmt.Errorf("failed to get player: %w", err)
+		return err
 	}
 
 	m, err := pl.Media()
@@ -218,7 +278,7 @@ func (s *Sampler) Next() error {
 		return fmt.Errorf("failed to get media: %w", err)
 	}
 
-	ml := s.listPlayer.MediaList()
+	ml := d.listPlayer.MediaList()
 
 	i, err := ml.IndexOfMedia(m)
 	if err != nil {
@@ -231,7 +291,7 @@ func (s *Sampler) Next() error {
 	}
 
 	if i == n-1 {
-		err := s.listPlayer.PlayAtIndex(0)
+		err := d.listPlayer.PlayAtIndex(0)
 		if err != nil {
 			return fmt.Errorf("failed to play first media: %w", err)
 		}
@@ -239,7 +299,7 @@ func (s *Sampler) Next() error {
 		return nil
 	}
 
-	err = s.listPlayer.PlayNext()
+	err = d.listPlayer.PlayNext()
 	if err != nil {
 		return fmt.Errorf("failed to play next media: %w", err)
 	}
@@ -247,8 +307,8 @@ func (s *Sampler) Next() error {
 	return nil
 }
 
-func (s *Sampler) playStreamList() error {
-	err := s.listPlayer.SetMediaList(s.streamMediaList)
+func (s *Sampler) playStreamList(d *deck) error {
+	err := d.listPlayer.SetMediaList(s.streamMediaList)
 	if err != nil {
 		return fmt.Errorf("failed to set media list: %w", err)
 	}
@@ -259,16 +319,16 @@ func (s *Sampler) playStreamList() error {
 	}
 
 	for i := 0; i < n; i++ {
-		err = s.listPlayer.PlayAtIndex(uint(i))
+		err = d.listPlayer.PlayAtIndex(uint(i))
 		if err != nil {
 			zap.S().Errorw("failed to play media", "index", i, err)
 
 			continue
 		}
 
-		pl, err := s.listPlayer.Player()
+		pl, err := d.player()
 		if err != nil {
-			return fmt.Errorf("failed to get player: %w", err)
+			return err
 		}
 
 		err = pl.SetScale(0)
@@ -291,63 +351,72 @@ func (s *Sampler) playStreamList() error {
 	return nil
 }
 
-func (s *Sampler) playPlaylist(i int) error {
+func (s *Sampler) playPlaylist(d *deck, i int) error {
 	if i >= len(s.playlists) {
 		return fmt.Errorf("playlist %d doesn't exist", i)
 	}
 
 	log.Println("starting", s.playlists[i])
 
-	m, err := vlc.NewMediaFromPath(s.playlists[i])
+	p, err := playlist.Load(s.playlists[i], s.order)
 	if err != nil {
-		return fmt.Errorf("failed to create media from playlist: %w", err)
+		return fmt.Errorf("failed to load playlist: %w", err)
 	}
 
-	err = m.ParseWithOptions(-1, vlc.MediaFetchLocal|vlc.MediaParseNetwork)
-	if err != nil {
-		return fmt.Errorf("failed to parse media: %w", err)
+	for _, clip := range p.Missing {
+		zap.S().Warnw("missing clip", "playlist", s.playlists[i], "path", clip)
 	}
 
-	em, err := m.EventManager()
-	if err != nil {
-		return fmt.Errorf("failed to get event manager: %w", err)
-	}
+	d.missing = len(p.Missing)
 
-	done := make(chan struct{})
+	if len(p.Clips) == 0 {
+		return fmt.Errorf("playlist %q has no clips", playlist.Name(s.playlists[i]))
+	}
 
-	id, err := em.Attach(vlc.MediaParsedChanged, func(event vlc.Event, i interface{}) {
-		close(done)
-	}, nil)
+	ml, err := vlc.NewMediaList()
 	if err != nil {
-		return fmt.Errorf("failed to attach event: %w", err)
+		return fmt.Errorf("failed to create media list: %w", err)
 	}
 
-	defer em.Detach(id)
+	for _, clip := range p.Clips {
+		var m *vlc.Media
 
-	select {
-	case <-done:
+		if strings.Contains(clip, "://") {
+			m, err = vlc.NewMediaFromURL(clip)
+		} else {
+			m, err = vlc.NewMediaFromPath(clip)
+		}
 
-	case <-time.After(5 * time.Second):
-		return fmt.Errorf("%w: parsing media took too long", errTimeout)
-	}
+		if err != nil {
+			return fmt.Errorf("failed to create media from %q: %w", clip, err)
+		}
 
-	ml, err := m.SubItems()
-	if err != nil {
-		return fmt.Errorf("failed to get media list from playlist media: %w", err)
+		err = ml.AddMedia(m)
+		if err != nil {
+			return fmt.Errorf("failed to add media to list: %w", err)
+		}
 	}
 
-	err = s.listPlayer.SetMediaList(ml)
+	err = d.listPlayer.SetMediaList(ml)
 	if err != nil {
 		return fmt.Errorf("failed to set media: %w", err)
 	}
 
-	if !s.listPlayer.IsPlaying() {
-		err = s.listPlayer.Play()
+	clips := make([]string, 0, len(p.Clips))
+
+	for _, clip := range p.Clips {
+		clips = append(clips, path.Base(clip))
+	}
+
+	d.clips = clips
+
+	if !d.listPlayer.IsPlaying() {
+		err = d.listPlayer.Play()
 		if err != nil {
 			return fmt.Errorf("failed to play playlist %q: %w", s.playlists[i], err)
 		}
 	} else {
-		err = s.listPlayer.PlayAtIndex(0)
+		err = d.listPlayer.PlayAtIndex(0)
 		if err != nil {
 			return fmt.Errorf("failed to play media 0 in %q: %w", s.playlists[i], err)
 		}
@@ -356,47 +425,199 @@ func (s *Sampler) playPlaylist(i int) error {
 	return nil
 }
 
-func (s *Sampler) PreviousPlaylist() error {
-	s.currentListIndex--
+func (s *Sampler) previousPlaylist(d *deck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d.currentListIndex--
+
+	if d.currentListIndex < 0 {
+		d.currentListIndex = len(s.playlists) - 1
+	}
+
+	return s.playPlaylist(d, d.currentListIndex)
+}
+
+func (s *Sampler) nextPlaylist(d *deck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d.currentListIndex++
+
+	if d.currentListIndex >= len(s.playlists) {
+		d.currentListIndex = 0
+	}
+
+	return s.playPlaylist(d, d.currentListIndex)
+}
+
+// Players returns the players of both decks, A first.
+func (s *Sampler) Players() ([]*vlc.Player, error) {
+	players := make([]*vlc.Player, len(s.decks))
+
+	for i, d := range s.decks {
+		p, err := d.player()
+		if err != nil {
+			return nil, err
+		}
+
+		players[i] = p
+	}
+
+	return players, nil
+}
+
+// State returns a snapshot of what the sampler is doing, describing the selected deck.
+// It is safe to call concurrently to the controllers.
+func (s *Sampler) State() state.Sampler {
+	s.mu.Lock()
+
+	d := s.decks[s.selected]
+
+	st := state.Sampler{
+		Deck:           s.selected.String(),
+		Mode:           d.mode.String(),
+		Playing:        d.listPlayer.IsPlaying(),
+		RecordingSince: s.recordingSince,
+		PlaylistIndex:  -1,
+		ClipIndex:      -1,
+	}
+
+	for _, p := range s.playlists {
+		st.Playlists = append(st.Playlists, playlist.Name(p))
+	}
+
+	if d.mode == ModePlaylists && d.currentListIndex < len(s.playlists) {
+		st.Playlist = playlist.Name(s.playlists[d.currentListIndex])
+		st.PlaylistIndex = d.currentListIndex
+		st.Missing = d.missing
+		st.Clips = d.clips
+	}
+
+	s.mu.Unlock()
+
+	s.mixMu.Lock()
+	st.OnAir = s.fader.OnAir().String()
+	st.Crossfader = s.fader.Position()
+	st.Transition = s.fader.Transition().String()
+	s.mixMu.Unlock()
+
+	clip, err := s.Clip()
+	if err == nil {
+		st.Clip = clip
+	}
+
+	if st.Clips != nil {
+		i, err := d.clipIndex()
+		if err == nil && i < len(st.Clips) {
+			st.ClipIndex = i
+		}
+	}
+
+	loc, _, _, err := d.position()
+	if err == nil {
+		p := s.points(loc)
+
+		st.In, st.Out, st.Looping = p.In, p.Out, p.Looping
+
+		st.Cues = s.cues.IsSet(loc)
+	}
+
+	st.Frozen = d.frozen()
+
+	s.loopMu.Lock()
+	st.Quantize = s.quantize.String()
+	st.BPM = s.grid.BPM()
+	s.loopMu.Unlock()
 
-	if s.currentListIndex < 0 {
-		s.currentListIndex = len(s.playlists) - 1
+	st.Pending = s.pendingNames()
+
+	d.mu.Lock()
+	st.Filters = d.fx.Filters().String()
+	st.Zoom = d.fx.Values()[fx.Zoom]
+	d.mu.Unlock()
+
+	if p, err := d.player(); err == nil {
+		st.Rate = float64(p.PlaybackRate())
 	}
 
-	return s.playPlaylist(s.currentListIndex)
+	return st
+}
+
+// Clip returns the name of what the selected deck plays, its title or else its file name.
+func (s *Sampler) Clip() (string, error) {
+	return s.selectedDeck().clip()
 }
 
-func (s *Sampler) NextPlaylist() error {
+// Snapshot grabs the current frame of the selected deck, scaled to the given width, e.g. to cue it on a preview panel.
+func (s *Sampler) Snapshot(width uint) (image.Image, error) {
+	p, err := s.selectedDeck().player()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp("", "snapshot-*.png")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+
+	f.Close()
+
+	defer os.Remove(f.Name())
 
-	s.currentListIndex++
+	err = p.TakeSnapshot(f.Name(), width, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take snapshot: %w", err)
+	}
 
-	if s.currentListIndex >= len(s.playlists) {
-		s.currentListIndex = 0
+	f, err = os.Open(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
 	}
 
-	return s.playPlaylist(s.currentListIndex)
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	return img, nil
 }
 
 func (s *Sampler) Close() error {
-	err := s.listPlayer.Stop()
+	close(s.done)
+	s.wg.Wait()
+
+	var errs []error
+
+	err := s.recorder.Stop()
 	if err != nil {
-		return fmt.Errorf("failed to stop listPlayer: %w", err)
+		errs = append(errs, fmt.Errorf("failed to stop recorder: %w", err))
 	}
 
-	err = s.listPlayer.Release()
+	err = s.recorder.Release()
 	if err != nil {
-		return fmt.Errorf("failed to release listPlayer: %w", err)
+		errs = append(errs, fmt.Errorf("failed to release recorder: %w", err))
 	}
 
-	return nil
+	for i, d := range s.decks {
+		err := d.close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to close deck %s: %w", mixer.Deck(i), err))
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
 var errNotImplemented = errors.New("not implemented")
 
+// startRecording records the deck on air. Only streams can be recorded.
 func (s *Sampler) startRecording(target string) error {
-	p, err := s.listPlayer.Player()
+	p, err := s.onAir().player()
 	if err != nil {
-		return fmt.Errorf("failed to get player: %w", err)
+		return err
 	}
 
 	m, err := p.Media()
@@ -447,7 +668,10 @@ func (s *Sampler) stopRecording() error {
 }
 
 func (s *Sampler) ToggleRecording() error {
-	if s.isRecording {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.recordingSince.IsZero() {
 		err := s.stopRecording()
 		if err != nil {
 			return fmt.Errorf("failed to stop recording: %w", err)
@@ -455,31 +679,66 @@ func (s *Sampler) ToggleRecording() error {
 
 		log.Println("Recording stopped")
 
-		s.isRecording = false
+		s.recordingSince = time.Time{}
 
 		return nil
 	}
 
-	err := s.startRecording(fmt.Sprintf("/tmp/recs/%d.mp4", time.Now().Unix()))
+	err := s.startRecording(path.Join(recordingsDir, fmt.Sprintf("%d.mp4", time.Now().Unix())))
 	if err != nil {
 		return fmt.Errorf("failed to record media: %w", err)
 	}
 
 	log.Println("Recording started")
 
-	s.isRecording = true
+	s.recordingSince = time.Now()
+
+	return nil
+}
+
+// Recordings returns the file names of all recordings, oldest first.
+func (s *Sampler) Recordings() ([]string, error) {
+	dir, err := os.ReadDir(recordingsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recordings: %w", err)
+	}
+
+	var recs []string
+
+	for _, entry := range dir {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".mp4") {
+			continue
+		}
+
+		recs = append(recs, entry.Name())
+	}
+
+	return recs, nil
+}
+
+func (s *Sampler) DeleteRecording(name string) error {
+	if name != path.Base(name) {
+		return fmt.Errorf("invalid recording name %q", name)
+	}
+
+	err := os.Remove(path.Join(recordingsDir, name))
+	if err != nil {
+		return fmt.Errorf("failed to delete recording: %w", err)
+	}
 
 	return nil
 }
 
 func (s *Sampler) TogglePlayPause() error {
-	if s.listPlayer.IsPlaying() {
-		err := s.listPlayer.Stop()
+	d := s.selectedDeck()
+
+	if d.listPlayer.IsPlaying() {
+		err := d.listPlayer.Stop()
 		if err != nil {
 			return fmt.Errorf("failed to pause: %w", err)
 		}
 	} else {
-		err := s.listPlayer.Play()
+		err := d.listPlayer.Play()
 		if err != nil {
 			return fmt.Errorf("failed to play: %w", err)
 		}
@@ -489,101 +748,109 @@ func (s *Sampler) TogglePlayPause() error {
 }
 
 func (s *Sampler) ToggleMode() error {
-	s.mode++
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if s.mode >= ModeMax {
-		s.mode = 0
-	}
+	d := s.decks[s.selected]
+
+	return s.setMode(d, (d.mode+1)%ModeMax)
+}
+
+// SetMode switches the selected deck to mode m, e.g. to start in playlist mode.
+func (s *Sampler) SetMode(m Mode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.setMode(s.decks[s.selected], m)
+}
 
-	switch s.mode {
+func (s *Sampler) setMode(d *deck, m Mode) error {
+	switch m {
 	case ModeStream:
-		err := s.playStreamList()
+		err := s.playStreamList(d)
 		if err != nil {
 			return fmt.Errorf("failed to play stream list: %w", err)
 		}
 
 	case ModePlaylists:
-		err := s.playPlaylist(s.currentListIndex)
+		err := s.playPlaylist(d, d.currentListIndex)
 		if err != nil {
 			return fmt.Errorf("failed to play playlist: %w", err)
 		}
 
 	default:
-		panic(fmt.Sprintf("unknown mode %d", s.mode))
+		return fmt.Errorf("unknown mode %d", m)
 	}
 
+	d.mode = m
+
 	return nil
 }
 
-var errTimeout = errors.New("timeout")
-
 type UI interface {
 	SendText(string)
+	SendError(string)
 }
 
 type Controller struct {
 	sampler *Sampler
 	ui      UI
-
-	playlistModifier bool
+	menus   *menu.Navigator
 }
 
-func NewController(svc *Sampler, ui UI) (*Controller, error) {
+// NewController creates a controller for svc. Menus, e.g. for deleting recordings, are opened on menus.
+func NewController(svc *Sampler, ui UI, menus *menu.Navigator) (*Controller, error) {
 	c := &Controller{
 		sampler: svc,
 		ui:      ui,
+		menus:   menus,
 	}
 
+	svc.loopMu.Lock()
+	svc.failed = ui.SendError
+	svc.loopMu.Unlock()
+
 	return c, nil
 }
 
-func (c *Controller) HandleEvent(event *evdev.EventEnvelope) error {
-	if fmt.Sprint(event.Type) == "Report" {
+// openRecordingsMenu lists the recordings, choosing one asks whether to delete it.
+func (c *Controller) openRecordingsMenu() error {
+	recs, err := c.sampler.Recordings()
+	if err != nil {
+		return err
+	}
+
+	if len(recs) == 0 {
+		c.ui.SendText("no recordings")
+
 		return nil
 	}
 
-	if event.Type == evdev.BtnSelect && event.Value == 1 {
-		if c.playlistModifier {
-			err := c.sampler.PreviousPlaylist()
-			if err != nil {
-				return fmt.Errorf("failed to play previous playlist: %w", err)
-			}
-		} else {
-			err := c.sampler.Previous()
-			if err != nil {
-				return fmt.Errorf("failed to play previous media %w", err)
-			}
-		}
-	} else if event.Type == evdev.BtnStart && event.Value == 1 {
-		if c.playlistModifier {
-			err := c.sampler.NextPlaylist()
-			if err != nil {
-				return fmt.Errorf("failed to play next playlist: %w", err)
-			}
-		} else {
-			err := c.sampler.Next()
-			if err != nil {
-				return fmt.Errorf("failed to play next media: %w", err)
-			}
-		}
-	} else if event.Type == evdev.BtnStart && event.Value == 1 {
-		err := c.sampler.TogglePlayPause()
-		if err != nil {
-			return fmt.Errorf("failed to toggle play/pause: %w", err)
-		}
-	} else if event.Type == evdev.BtnSelect && event.Value == 1 {
-		err := c.sampler.ToggleRecording()
-		if err != nil {
-			return fmt.Errorf("failed to toggle recording: %w", err)
-		}
-	} else if event.Type == evdev.BtnZ {
-		c.playlistModifier = event.Value == 1
-	} else if event.Type == evdev.BtnMode && event.Value == 1 {
-		err := c.sampler.ToggleMode()
-		if err != nil {
-			return fmt.Errorf("failed to toggle mode: %w", err)
-		}
+	m := menu.Menu{
+		Title: "Recordings",
+	}
+
+	for _, rec := range recs {
+		m.Items = append(m.Items, menu.Item{
+			Label: rec,
+			Action: func() error {
+				c.menus.Open(menu.Confirm(fmt.Sprintf("Delete %s?", rec), func() error {
+					err := c.sampler.DeleteRecording(rec)
+					if err != nil {
+						return err
+					}
+
+					c.ui.SendText("deleted " + rec)
+
+					return nil
+				}))
+
+				return nil
+			},
+		})
 	}
 
+	c.menus.Open(m)
+
 	return nil
 }
@@ -0,0 +1,175 @@
+// Package state describes what the sampler is doing, for UIs that shouldn't depend on libvlc.
+package state
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markus-wa/vlc-sampler/features/sampler/cues"
+)
+
+// Sampler is a snapshot of the sampler that is published to the UI whenever it changes.
+type Sampler struct {
+	// Deck is the deck controlled by the clip, playlist and mode actions, which Mode, Playlist, Clip and Playing describe.
+	Deck     string
+	Mode     string
+	Playlist string
+	Clip     string
+	Playing  bool
+	// Missing is how many clips of the playlist don't exist.
+	Missing int
+	// Playlists are the names of all playlists and Clips those of the deck's playlist, for lists in a UI.
+	// PlaylistIndex and ClipIndex are the playing ones, -1 if none is, e.g. in stream mode.
+	Playlists     []string
+	PlaylistIndex int
+	Clips         []string
+	ClipIndex     int
+	OnAir         string
+	// Crossfader is from 0 (deck A) to 1 (deck B).
+	Crossfader float64
+	Transition string
+	// In and Out are the points of the clip, Out is zero for its end.
+	In, Out time.Duration
+	Looping bool
+	// Rate is the playback rate, 1 for normal speed.
+	Rate   float64
+	Frozen bool
+	// Cues are the hot cues of the clip that are set, cue n at index n-1.
+	Cues [cues.Count]bool
+	// Filters are the video filters that are on, e.g. "invert:mirror", and Zoom the zoom, 1 for none.
+	Filters string
+	Zoom    float64
+	// Quantize is what clip and playlist switches wait for, off, beat or bar, at the tempo BPM.
+	// Pending are the waiting switches, e.g. "next clip, playlist 2".
+	Quantize string
+	BPM      float64
+	Pending  string
+	// RecordingSince is when the current recording started, zero if nothing is recorded.
+	RecordingSince time.Time
+}
+
+func (s Sampler) Quantizing() bool {
+	return s.Quantize != "" && s.Quantize != "off"
+}
+
+func (s Sampler) Recording() bool {
+	return !s.RecordingSince.IsZero()
+}
+
+// Elapsed returns for how long the current recording has been running at now.
+func (s Sampler) Elapsed(now time.Time) time.Duration {
+	if !s.Recording() {
+		return 0
+	}
+
+	return now.Sub(s.RecordingSince)
+}
+
+// Status is a one line summary, e.g. for a status bar.
+func (s Sampler) Status(now time.Time) string {
+	var parts []string
+
+	if s.Deck != "" {
+		parts = append(parts, "deck "+s.Deck)
+	}
+
+	parts = append(parts, "mode "+s.Mode)
+
+	if s.Playlist != "" {
+		parts = append(parts, "playlist "+s.Playlist)
+	}
+
+	if s.Missing > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing", s.Missing))
+	}
+
+	if s.Clip != "" {
+		parts = append(parts, "clip "+s.Clip)
+	}
+
+	if !s.Playing {
+		parts = append(parts, "stopped")
+	}
+
+	if s.Looping {
+		parts = append(parts, "loop "+s.Loop())
+	}
+
+	if s.Rate != 0 && s.Rate != 1 {
+		parts = append(parts, fmt.Sprintf("rate %.2gx", s.Rate))
+	}
+
+	if s.Frozen {
+		parts = append(parts, "frozen")
+	}
+
+	if c := s.SetCues(); c != "" {
+		parts = append(parts, "cues "+c)
+	}
+
+	if s.Filters != "" {
+		parts = append(parts, "fx "+s.Filters)
+	}
+
+	if s.Zoom > 1 {
+		parts = append(parts, fmt.Sprintf("zoom %.1fx", s.Zoom))
+	}
+
+	if s.Quantizing() {
+		parts = append(parts, fmt.Sprintf("quantize %s %g BPM", s.Quantize, s.BPM))
+	}
+
+	if s.Pending != "" {
+		parts = append(parts, "pending "+s.Pending)
+	}
+
+	if s.Transition != "" {
+		parts = append(parts, fmt.Sprintf("on air %s xfade %d%% %s", s.OnAir, int(math.Round(s.Crossfader*100)), s.Transition))
+	}
+
+	if s.Recording() {
+		parts = append(parts, "REC "+FormatElapsed(s.Elapsed(now)))
+	}
+
+	return strings.Join(parts, " | ")
+}
+
+// SetCues lists the hot cues that are set, e.g. "1,3".
+func (s Sampler) SetCues() string {
+	var set []string
+
+	for i, ok := range s.Cues {
+		if ok {
+			set = append(set, strconv.Itoa(i+1))
+		}
+	}
+
+	return strings.Join(set, ",")
+}
+
+// Loop returns the loop region, e.g. "0:02.50-0:04.00" or "0:02.50-end".
+func (s Sampler) Loop() string {
+	out := "end"
+	if s.Out != 0 {
+		out = FormatPosition(s.Out)
+	}
+
+	return FormatPosition(s.In) + "-" + out
+}
+
+// FormatPosition formats a position in a clip as m:ss.cc.
+func FormatPosition(d time.Duration) string {
+	d = d.Truncate(10 * time.Millisecond)
+
+	return fmt.Sprintf("%d:%05.2f", int(d.Minutes()), math.Mod(d.Seconds(), 60))
+}
+
+// FormatElapsed formats d as hh:mm:ss.
+func FormatElapsed(d time.Duration) string {
+	d = d.Truncate(time.Second)
+
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
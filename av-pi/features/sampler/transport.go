@@ -0,0 +1,83 @@
+package sampler
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/markus-wa/vlc-sampler/features/sampler/transport"
+)
+
+// SetRate sets the playback rate of the selected deck, between transport.MinRate and transport.MaxRate.
+func (s *Sampler) SetRate(rate float64) error {
+	p, err := s.selectedDeck().player()
+	if err != nil {
+		return err
+	}
+
+	err = p.SetPlaybackRate(float32(transport.ClampRate(rate)))
+	if err != nil {
+		return fmt.Errorf("failed to set playback rate: %w", err)
+	}
+
+	return nil
+}
+
+// Jog scrubs through the selected deck's clip, speed is -32767 (backwards) to 32767 like a stick axis.
+// The other deck stops jogging, in case the deck was switched while jogging.
+func (s *Sampler) Jog(speed int32) {
+	selected := s.selectedDeck()
+
+	for _, d := range s.decks {
+		d.mu.Lock()
+
+		if d == selected {
+			d.jog = transport.Jog(speed)
+		} else {
+			d.jog = 0
+		}
+
+		d.mu.Unlock()
+	}
+}
+
+// ToggleFreeze holds the current frame of the selected deck, or continues playing it.
+func (s *Sampler) ToggleFreeze() error {
+	d := s.selectedDeck()
+
+	p, err := d.player()
+	if err != nil {
+		return err
+	}
+
+	err = p.SetPause(!d.frozen())
+	if err != nil {
+		return fmt.Errorf("failed to pause: %w", err)
+	}
+
+	return nil
+}
+
+// jogDecks moves the decks that are jogged by how far they went in elapsed.
+func (s *Sampler) jogDecks(elapsed time.Duration) {
+	for _, d := range s.decks {
+		d.mu.Lock()
+		speed := d.jog
+		d.mu.Unlock()
+
+		if speed == 0 {
+			continue
+		}
+
+		_, t, length, err := d.position()
+		if err != nil {
+			continue
+		}
+
+		err = d.seek(transport.Scrub(t, length, speed, elapsed))
+		if err != nil {
+			zap.S().Debugw("failed to jog", "error", err)
+		}
+	}
+}
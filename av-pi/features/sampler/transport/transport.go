@@ -0,0 +1,62 @@
+// Package transport maps stick axes to the playback rate of a clip and to how far jogging scrubs it.
+package transport
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	MinRate = 0.25
+	MaxRate = 4
+
+	// Deadzone is how far a stick can be off centre when released.
+	Deadzone = 4000
+
+	// JogRange is how far a fully pushed stick jogs per second.
+	JogRange = 4 * time.Second
+)
+
+// axis returns the stick's deflection from -1 to 1, 0 within the deadzone.
+func axis(v int32) float64 {
+	x := float64(v)
+
+	switch {
+	case x > Deadzone:
+		return min((x-Deadzone)/(32767-Deadzone), 1)
+	case x < -Deadzone:
+		return max((x+Deadzone)/(32767-Deadzone), -1)
+	default:
+		return 0
+	}
+}
+
+// Rate maps a stick axis to a playback rate: centred is normal speed, pushed up (negative) fast forward up to
+// MaxRate and pushed down slow motion down to MinRate. The rate is rounded to hundredths, so a resting stick
+// doesn't change it.
+func Rate(v int32) float64 {
+	r := math.Pow(2, -2*axis(v))
+
+	return ClampRate(math.Round(r*100) / 100)
+}
+
+func ClampRate(r float64) float64 {
+	return min(max(r, MinRate), MaxRate)
+}
+
+// Jog returns how many seconds per second a stick axis jogs, negative backwards.
+func Jog(v int32) float64 {
+	return axis(v) * JogRange.Seconds()
+}
+
+// Scrub returns where a clip of the given length at t is after jogging at speed for d.
+// The length of streams is unknown (zero), they aren't jogged.
+func Scrub(t, length time.Duration, speed float64, d time.Duration) time.Duration {
+	if length <= 0 {
+		return t
+	}
+
+	t += time.Duration(speed * float64(d))
+
+	return min(max(t, 0), length)
+}
@@ -0,0 +1,52 @@
+package transport
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRate(t *testing.T) {
+	for _, tt := range []struct {
+		v    int32
+		want float64
+	}{
+		{0, 1},
+		{Deadzone, 1},
+		{-Deadzone, 1},
+		{-32767, MaxRate},
+		{32767, MinRate},
+		{-(Deadzone + (32767-Deadzone)/2), 2},
+		{Deadzone + (32767-Deadzone)/2, 0.5},
+	} {
+		if got := Rate(tt.v); got != tt.want {
+			t.Errorf("Rate(%d) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestJog(t *testing.T) {
+	if got := Jog(100); got != 0 {
+		t.Errorf("jogged %v within the deadzone", got)
+	}
+
+	if got := Jog(-32767); got != -JogRange.Seconds() {
+		t.Errorf("Jog(-32767) = %v", got)
+	}
+}
+
+func TestScrub(t *testing.T) {
+	for _, tt := range []struct {
+		t, length time.Duration
+		speed     float64
+		want      time.Duration
+	}{
+		{2 * time.Second, 10 * time.Second, 4, 6 * time.Second},
+		{2 * time.Second, 10 * time.Second, -4, 0},
+		{8 * time.Second, 10 * time.Second, 4, 10 * time.Second},
+		{8 * time.Second, 0, 4, 8 * time.Second},
+	} {
+		if got := Scrub(tt.t, tt.length, tt.speed, time.Second); got != tt.want {
+			t.Errorf("Scrub(%s, %s, %v) = %s, want %s", tt.t, tt.length, tt.speed, got, tt.want)
+		}
+	}
+}
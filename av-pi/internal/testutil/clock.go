@@ -0,0 +1,22 @@
+// Package testutil has fixtures shared by the tests of the features.
+package testutil
+
+import "time"
+
+// Clock is a fake clock that only moves when the test advances it.
+// Its Now method stands in for time.Now in the clock fields of the code under test.
+type Clock struct {
+	now time.Time
+}
+
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	return c.now
+}
+
+func (c *Clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}